@@ -0,0 +1,145 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	airNowSource               = "airnow"
+	defaultAirNowDistanceMiles = 25
+)
+
+// airNowParameterFields maps AirNow's parameter names to the per-pollutant AQI fields
+// written for them.
+var airNowParameterFields = map[string]string{
+	"O3":    "aqi_us_o3",
+	"PM2.5": "aqi_us_pm25",
+	"PM10":  "aqi_us_pm10",
+}
+
+// AirNowConfig describes the configuration for the AirNow official AQI input.
+type AirNowConfig struct {
+	APIKey        string `json:"api_key"`
+	DistanceMiles int    `json:"distance_miles,omitempty"`
+}
+
+// Validate checks the AirNow configuration.
+func (c AirNowConfig) Validate() error {
+	if c.APIKey == "" {
+		return errors.New("api_key must be set")
+	}
+	if c.DistanceMiles < 0 {
+		return errors.New("distance_miles may not be negative")
+	}
+	return nil
+}
+
+// Distance returns how far (miles) from the location AirNow may look for a reporting area.
+func (c AirNowConfig) Distance() int {
+	if c.DistanceMiles == 0 {
+		return defaultAirNowDistanceMiles
+	}
+	return c.DistanceMiles
+}
+
+// AirNowObservation is the current official US EPA AQI for a reporting area.
+type AirNowObservation struct {
+	Time          time.Time
+	ReportingArea string
+	// AQI is the highest AQI among the reported pollutants, and Dominant names that pollutant.
+	AQI          int
+	CategoryName string
+	Dominant     string
+	// ByPollutant holds each reported pollutant's AQI, keyed by AirNow's parameter name
+	// (e.g. "PM2.5").
+	ByPollutant map[string]int
+}
+
+// Fields returns the observation's fields, as written to the pollution measurement.
+func (o *AirNowObservation) Fields() map[string]interface{} {
+	fields := map[string]interface{}{
+		"aqi_us":                float64(o.AQI),
+		"aqi_us_name":           o.CategoryName,
+		"dominant_pollutant":    o.Dominant,
+		"airnow_reporting_area": o.ReportingArea,
+	}
+	for param, aqi := range o.ByPollutant {
+		if field, ok := airNowParameterFields[param]; ok {
+			fields[field] = float64(aqi)
+		}
+	}
+	return fields
+}
+
+// FetchAirNow fetches the current official AQI for the reporting area nearest the given
+// location. AirNow reports observation times in the reporting area's local standard time
+// without an offset; they're interpreted in the configured timezone.
+// See https://docs.airnowapi.org/CurrentObservationsByLatLon/docs
+func FetchAirNow(c AirNowConfig, lat, lon float64) (*AirNowObservation, error) {
+	q := url.Values{}
+	q.Set("format", "application/json")
+	q.Set("latitude", strconv.FormatFloat(lat, 'f', 4, 64))
+	q.Set("longitude", strconv.FormatFloat(lon, 'f', 4, 64))
+	q.Set("distance", strconv.Itoa(c.Distance()))
+	q.Set("API_KEY", c.APIKey)
+	req, err := http.NewRequest(http.MethodGet, "https://www.airnowapi.org/aq/observation/latLong/current/?"+q.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := owmHTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("AirNow API returned %s", resp.Status)
+	}
+
+	var body []struct {
+		DateObserved  string `json:"DateObserved"`
+		HourObserved  int    `json:"HourObserved"`
+		ReportingArea string `json:"ReportingArea"`
+		ParameterName string `json:"ParameterName"`
+		AQI           int    `json:"AQI"`
+		Category      struct {
+			Name string `json:"Name"`
+		} `json:"Category"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("failed to decode AirNow response: %w", err)
+	}
+	if len(body) == 0 {
+		return nil, fmt.Errorf("AirNow didn't report any observations within %d miles", c.Distance())
+	}
+
+	o := &AirNowObservation{AQI: -1, ByPollutant: make(map[string]int)}
+	for _, r := range body {
+		// nb. AQI is -1 for pollutants the area doesn't currently report
+		if r.AQI < 0 {
+			continue
+		}
+		o.ByPollutant[r.ParameterName] = r.AQI
+		if r.AQI > o.AQI {
+			day, err := time.ParseInLocation("2006-01-02", strings.TrimSpace(r.DateObserved), localTZ)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse AirNow observation date '%s': %w", r.DateObserved, err)
+			}
+			o.Time = day.Add(time.Duration(r.HourObserved) * time.Hour)
+			o.ReportingArea = r.ReportingArea
+			o.AQI = r.AQI
+			o.CategoryName = r.Category.Name
+			o.Dominant = r.ParameterName
+		}
+	}
+	if o.AQI < 0 {
+		return nil, errors.New("AirNow didn't report a current AQI")
+	}
+	return o, nil
+}
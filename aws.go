@@ -0,0 +1,241 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch"
+	cwtypes "github.com/aws/aws-sdk-go-v2/service/cloudwatch/types"
+	"github.com/aws/aws-sdk-go-v2/service/timestreamwrite"
+	tstypes "github.com/aws/aws-sdk-go-v2/service/timestreamwrite/types"
+)
+
+const (
+	defaultTimestreamName = "timestream"
+	defaultCloudWatchName = "cloudwatch"
+	awsTimeout            = 15 * time.Second
+
+	// cloudWatchMaxDimensions is CloudWatch's limit on dimensions per metric.
+	cloudWatchMaxDimensions = 30
+	// cloudWatchMeasurementDimension is the dimension identifying a metric's measurement.
+	cloudWatchMeasurementDimension = "measurement"
+)
+
+// AWSConfig describes the configuration for the AWS outputs. Credentials are loaded from
+// the standard AWS credential chain (environment, shared config/credentials files, or an
+// IAM role).
+type AWSConfig struct {
+	Region     string               `json:"region,omitempty"`
+	Profile    string               `json:"profile,omitempty"`
+	Timestream *AWSTimestreamConfig `json:"timestream,omitempty"`
+	CloudWatch *AWSCloudWatchConfig `json:"cloudwatch,omitempty"`
+}
+
+// AWSTimestreamConfig describes the Amazon Timestream table points are written to.
+type AWSTimestreamConfig struct {
+	Name     string `json:"name,omitempty"`
+	Database string `json:"database"`
+	Table    string `json:"table"`
+}
+
+// AWSCloudWatchConfig describes the CloudWatch namespace metrics are published to.
+type AWSCloudWatchConfig struct {
+	Name      string `json:"name,omitempty"`
+	Namespace string `json:"namespace"`
+}
+
+// Validate checks the AWS configuration.
+func (c AWSConfig) Validate() error {
+	if c.Timestream == nil && c.CloudWatch == nil {
+		return errors.New("at least one of timestream or cloudwatch must be set")
+	}
+	if c.Timestream != nil && (c.Timestream.Database == "" || c.Timestream.Table == "") {
+		return errors.New("timestream.database and timestream.table must be set")
+	}
+	if c.CloudWatch != nil && c.CloudWatch.Namespace == "" {
+		return errors.New("cloudwatch.namespace must be set")
+	}
+	return nil
+}
+
+// OutputName returns the configured output name, or the default "timestream".
+func (c AWSTimestreamConfig) OutputName() string {
+	if c.Name == "" {
+		return defaultTimestreamName
+	}
+	return c.Name
+}
+
+// OutputName returns the configured output name, or the default "cloudwatch".
+func (c AWSCloudWatchConfig) OutputName() string {
+	if c.Name == "" {
+		return defaultCloudWatchName
+	}
+	return c.Name
+}
+
+// newAWSOutputs returns the configured AWS outputs.
+func newAWSOutputs(c AWSConfig) ([]pointOutput, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), awsTimeout)
+	defer cancel()
+	var opts []func(*awsconfig.LoadOptions) error
+	if c.Region != "" {
+		opts = append(opts, awsconfig.WithRegion(c.Region))
+	}
+	if c.Profile != "" {
+		opts = append(opts, awsconfig.WithSharedConfigProfile(c.Profile))
+	}
+	cfg, err := awsconfig.LoadDefaultConfig(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load AWS configuration: %w", err)
+	}
+
+	var outputs []pointOutput
+	if c.Timestream != nil {
+		outputs = append(outputs, &timestreamOutput{config: *c.Timestream, client: timestreamwrite.NewFromConfig(cfg)})
+	}
+	if c.CloudWatch != nil {
+		outputs = append(outputs, &cloudWatchOutput{config: *c.CloudWatch, client: cloudwatch.NewFromConfig(cfg)})
+	}
+	return outputs, nil
+}
+
+// timestreamOutput writes each point as a multi-measure record to Amazon Timestream. The
+// record's measure name is the point's measurement, and its tags become dimensions.
+type timestreamOutput struct {
+	config AWSTimestreamConfig
+	client *timestreamwrite.Client
+}
+
+// Name returns the output's name, for output routing.
+func (o *timestreamOutput) Name() string {
+	return o.config.OutputName()
+}
+
+// WritePoint writes a single point to Timestream.
+func (o *timestreamOutput) WritePoint(ctx context.Context, measurement string, tags map[string]string, fields map[string]interface{}, ts time.Time) error {
+	record := tstypes.Record{
+		MeasureName:      aws.String(measurement),
+		MeasureValueType: tstypes.MeasureValueTypeMulti,
+		Time:             aws.String(strconv.FormatInt(ts.UnixMilli(), 10)),
+		TimeUnit:         tstypes.TimeUnitMilliseconds,
+	}
+	for _, k := range sortedKeys(tags) {
+		record.Dimensions = append(record.Dimensions, tstypes.Dimension{Name: aws.String(k), Value: aws.String(tags[k])})
+	}
+	for _, k := range sortedKeys(fields) {
+		mv := tstypes.MeasureValue{Name: aws.String(k)}
+		switch v := fields[k].(type) {
+		case float64:
+			mv.Type, mv.Value = tstypes.MeasureValueTypeDouble, aws.String(strconv.FormatFloat(v, 'f', -1, 64))
+		case float32:
+			mv.Type, mv.Value = tstypes.MeasureValueTypeDouble, aws.String(strconv.FormatFloat(float64(v), 'f', -1, 32))
+		case int:
+			mv.Type, mv.Value = tstypes.MeasureValueTypeBigint, aws.String(strconv.Itoa(v))
+		case int64:
+			mv.Type, mv.Value = tstypes.MeasureValueTypeBigint, aws.String(strconv.FormatInt(v, 10))
+		case bool:
+			mv.Type, mv.Value = tstypes.MeasureValueTypeBoolean, aws.String(strconv.FormatBool(v))
+		case string:
+			if v == "" {
+				// nb. Timestream rejects empty measure values
+				continue
+			}
+			mv.Type, mv.Value = tstypes.MeasureValueTypeVarchar, aws.String(v)
+		default:
+			return fmt.Errorf("field '%s' has unsupported type %T", k, v)
+		}
+		record.MeasureValues = append(record.MeasureValues, mv)
+	}
+	if len(record.MeasureValues) == 0 {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, awsTimeout)
+	defer cancel()
+	_, err := o.client.WriteRecords(ctx, &timestreamwrite.WriteRecordsInput{
+		DatabaseName: aws.String(o.config.Database),
+		TableName:    aws.String(o.config.Table),
+		Records:      []tstypes.Record{record},
+	})
+	return err
+}
+
+// Close is a no-op; each point is written with its own request.
+func (o *timestreamOutput) Close() {}
+
+// cloudWatchOutput publishes each numeric field of a point as a CloudWatch custom metric
+// named for the field, with the point's measurement and tags as dimensions.
+type cloudWatchOutput struct {
+	config AWSCloudWatchConfig
+	client *cloudwatch.Client
+}
+
+// Name returns the output's name, for output routing.
+func (o *cloudWatchOutput) Name() string {
+	return o.config.OutputName()
+}
+
+// WritePoint publishes the point's numeric fields. Non-numeric fields are ignored.
+func (o *cloudWatchOutput) WritePoint(ctx context.Context, measurement string, tags map[string]string, fields map[string]interface{}, ts time.Time) error {
+	dims := []cwtypes.Dimension{{Name: aws.String(cloudWatchMeasurementDimension), Value: aws.String(measurement)}}
+	for _, k := range sortedKeys(tags) {
+		if len(dims) == cloudWatchMaxDimensions {
+			break
+		}
+		dims = append(dims, cwtypes.Dimension{Name: aws.String(k), Value: aws.String(tags[k])})
+	}
+
+	var data []cwtypes.MetricDatum
+	for _, k := range sortedKeys(fields) {
+		var v float64
+		switch n := fields[k].(type) {
+		case float64:
+			v = n
+		case float32:
+			v = float64(n)
+		case int:
+			v = float64(n)
+		case int64:
+			v = float64(n)
+		default:
+			continue
+		}
+		data = append(data, cwtypes.MetricDatum{
+			MetricName: aws.String(k),
+			Dimensions: dims,
+			Timestamp:  aws.Time(ts),
+			Value:      aws.Float64(v),
+		})
+	}
+	if len(data) == 0 {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, awsTimeout)
+	defer cancel()
+	_, err := o.client.PutMetricData(ctx, &cloudwatch.PutMetricDataInput{
+		Namespace:  aws.String(o.config.Namespace),
+		MetricData: data,
+	})
+	return err
+}
+
+// Close is a no-op; each point is published with its own request.
+func (o *cloudWatchOutput) Close() {}
+
+// sortedKeys returns the keys of m in sorted order.
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	owmHistoryURL = "https://history.openweathermap.org/data/2.5/history/city"
+	// owmHistoryMaxSpan is the longest period the History API returns in one request.
+	owmHistoryMaxSpan = 7 * 24 * time.Hour
+)
+
+// parseBackfillTime parses a -backfillFrom or -backfillTo value: an RFC 3339 timestamp,
+// or a YYYY-MM-DD date, which is midnight in the configured timezone.
+func parseBackfillTime(s string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	t, err := time.ParseInLocation("2006-01-02", s, localTZ)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("'%s' must be an RFC 3339 timestamp or a YYYY-MM-DD date", s)
+	}
+	return t, nil
+}
+
+// fetchHistory fetches hourly observations at the given location between start and end
+// from OpenWeatherMap's History API, in imperial units. The period must be no longer than
+// owmHistoryMaxSpan.
+// See https://openweathermap.org/history
+func fetchHistory(apiKey string, lat, lon float64, start, end time.Time) ([]bulkRecord, error) {
+	q := owmPaidQuery(apiKey, lat, lon)
+	q.Set("type", "hour")
+	q.Set("units", "imperial")
+	q.Set("start", strconv.FormatInt(start.Unix(), 10))
+	q.Set("end", strconv.FormatInt(end.Unix(), 10))
+	req, err := http.NewRequest(http.MethodGet, owmHistoryURL+"?"+q.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	var body struct {
+		List json.RawMessage `json:"list"`
+	}
+	if err := doOWMPaidRequest(req, "History", &body); err != nil {
+		return nil, err
+	}
+	if len(body.List) == 0 {
+		return nil, nil
+	}
+	// nb. History API records are in the same format as History Bulk JSON exports
+	return readBulkHistoryJSON(bytes.NewReader(body.List))
+}
+
+// runBackfill fetches hourly observations for the configured location from the History
+// API, between from and to (or now, if to is empty), and writes them to the weather
+// measurement (see writeBulkRecords).
+func runBackfill(config Config, w *influxWriter, from, to string) error {
+	if from == "" {
+		return errors.New("-backfillFrom is required")
+	}
+	start, err := parseBackfillTime(from)
+	if err != nil {
+		return fmt.Errorf("invalid -backfillFrom: %w", err)
+	}
+	end := now()
+	if to != "" {
+		if end, err = parseBackfillTime(to); err != nil {
+			return fmt.Errorf("invalid -backfillTo: %w", err)
+		}
+	}
+	if !start.Before(end) {
+		return errors.New("-backfillFrom must be before -backfillTo")
+	}
+
+	var counts bulkWriteCounts
+	for chunkStart := start; chunkStart.Before(end); chunkStart = chunkStart.Add(owmHistoryMaxSpan) {
+		chunkEnd := chunkStart.Add(owmHistoryMaxSpan)
+		if chunkEnd.After(end) {
+			chunkEnd = end
+		}
+		records, err := fetchHistory(config.APIKey, config.Latitude, config.Longitude, chunkStart, chunkEnd)
+		if err != nil {
+			return fmt.Errorf("failed to fetch history from %s to %s: %w", chunkStart.Format(time.RFC3339), chunkEnd.Format(time.RFC3339), err)
+		}
+		source := fmt.Sprintf("History API %s to %s", chunkStart.Format(time.RFC3339), chunkEnd.Format(time.RFC3339))
+		if err := writeBulkRecords(config, w, BulkUnitsImperial, source, records, &counts); err != nil {
+			return err
+		}
+		slog.Info("Backfilled history", "from", chunkStart, "to", chunkEnd, "records", len(records))
+	}
+	slog.Info("Backfill complete", "written", counts.written, "skipped", counts.skipped, "failed", counts.failed)
+	if counts.failed > 0 {
+		return fmt.Errorf("%d point(s) failed to write", counts.failed)
+	}
+	return nil
+}
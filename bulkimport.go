@@ -0,0 +1,270 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cdzombak/libwx"
+	"github.com/cdzombak/openweather-influxdb-connector/pkg/owmconnector"
+)
+
+// Units an OpenWeatherMap History Bulk export may use, as chosen when ordering it.
+const (
+	BulkUnitsStandard = "standard"
+	BulkUnitsMetric   = "metric"
+	BulkUnitsImperial = "imperial"
+)
+
+// bulkRecord is a single hourly record from an OpenWeatherMap History Bulk export, in the
+// export's units. Values missing from the export are nil.
+type bulkRecord struct {
+	Dt         int64
+	Temp       *float64
+	FeelsLike  *float64
+	Pressure   *float64
+	Humidity   *float64
+	WindSpeed  *float64
+	WindDeg    *float64
+	Visibility *float64
+	Clouds     *float64
+	WeatherID  *float64
+}
+
+// observation converts the record to an observation in the connector's units.
+func (r bulkRecord) observation(units string, lat, lon float64, elevationM *float64) (*owmconnector.Observation, error) {
+	if r.Temp == nil || r.Humidity == nil || r.Pressure == nil {
+		return nil, errors.New("record is missing temp, humidity, or pressure")
+	}
+	tempF := func(v float64) libwx.TempF {
+		switch units {
+		case BulkUnitsStandard:
+			return libwx.TempC(v - 273.15).F()
+		case BulkUnitsMetric:
+			return libwx.TempC(v).F()
+		default:
+			return libwx.TempF(v)
+		}
+	}
+	o := &owmconnector.Observation{
+		Time:              time.Unix(r.Dt, 0),
+		Latitude:          lat,
+		Longitude:         lon,
+		ElevationMeters:   elevationM,
+		Temp:              tempF(*r.Temp),
+		FeelsLike:         tempF(*r.Temp),
+		Pressure:          libwx.PressureMb(*r.Pressure),
+		Humidity:          libwx.ClampedRelHumidity(int(*r.Humidity)),
+		WindBearing:       valueOrZero(r.WindDeg),
+		VisibilityMiles:   libwx.Meter(valueOrZero(r.Visibility)).Miles(),
+		CloudCoverPercent: int(valueOrZero(r.Clouds)),
+		ConditionID:       int(valueOrZero(r.WeatherID)),
+	}
+	if r.FeelsLike != nil {
+		o.FeelsLike = tempF(*r.FeelsLike)
+	}
+	windSpeed := valueOrZero(r.WindSpeed)
+	if units == BulkUnitsImperial {
+		o.WindSpeed = libwx.SpeedMph(windSpeed)
+	} else {
+		o.WindSpeed = libwx.SpeedKmH(windSpeed * 3.6).Mph()
+	}
+	o.DewPoint = libwx.DewPointF(o.Temp, o.Humidity)
+	return o, nil
+}
+
+// readBulkHistory reads records from an OpenWeatherMap History Bulk export file, in its
+// JSON or CSV format as indicated by the file's extension.
+// See https://openweathermap.org/history-bulk
+func readBulkHistory(path string) ([]bulkRecord, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		return readBulkHistoryJSON(f)
+	case ".csv":
+		return readBulkHistoryCSV(f)
+	default:
+		return nil, fmt.Errorf("unsupported file type '%s' (must be .json or .csv)", filepath.Ext(path))
+	}
+}
+
+func readBulkHistoryJSON(r io.Reader) ([]bulkRecord, error) {
+	var body []struct {
+		Dt   int64 `json:"dt"`
+		Main struct {
+			Temp      *float64 `json:"temp"`
+			FeelsLike *float64 `json:"feels_like"`
+			Pressure  *float64 `json:"pressure"`
+			Humidity  *float64 `json:"humidity"`
+		} `json:"main"`
+		Wind struct {
+			Speed *float64 `json:"speed"`
+			Deg   *float64 `json:"deg"`
+		} `json:"wind"`
+		Clouds struct {
+			All *float64 `json:"all"`
+		} `json:"clouds"`
+		Visibility *float64 `json:"visibility"`
+		Weather    []struct {
+			ID float64 `json:"id"`
+		} `json:"weather"`
+	}
+	if err := json.NewDecoder(r).Decode(&body); err != nil {
+		return nil, fmt.Errorf("failed to parse JSON: %w", err)
+	}
+	records := make([]bulkRecord, 0, len(body))
+	for _, b := range body {
+		rec := bulkRecord{
+			Dt:         b.Dt,
+			Temp:       b.Main.Temp,
+			FeelsLike:  b.Main.FeelsLike,
+			Pressure:   b.Main.Pressure,
+			Humidity:   b.Main.Humidity,
+			WindSpeed:  b.Wind.Speed,
+			WindDeg:    b.Wind.Deg,
+			Visibility: b.Visibility,
+			Clouds:     b.Clouds.All,
+		}
+		if len(b.Weather) > 0 {
+			id := b.Weather[0].ID
+			rec.WeatherID = &id
+		}
+		records = append(records, rec)
+	}
+	return records, nil
+}
+
+func readBulkHistoryCSV(r io.Reader) ([]bulkRecord, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	header, err := cr.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CSV header: %w", err)
+	}
+	cols := make(map[string]int, len(header))
+	for i, h := range header {
+		cols[strings.TrimSpace(h)] = i
+	}
+	if _, ok := cols["dt"]; !ok {
+		return nil, errors.New("CSV has no 'dt' column")
+	}
+
+	var records []bulkRecord
+	for line := 2; ; line++ {
+		row, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CSV line %d: %w", line, err)
+		}
+		col := func(name string) *float64 {
+			i, ok := cols[name]
+			if !ok || i >= len(row) || row[i] == "" {
+				return nil
+			}
+			v, err := strconv.ParseFloat(row[i], 64)
+			if err != nil {
+				return nil
+			}
+			return &v
+		}
+		dt := col("dt")
+		if dt == nil {
+			return nil, fmt.Errorf("CSV line %d has an invalid dt", line)
+		}
+		records = append(records, bulkRecord{
+			Dt:         int64(*dt),
+			Temp:       col("temp"),
+			FeelsLike:  col("feels_like"),
+			Pressure:   col("pressure"),
+			Humidity:   col("humidity"),
+			WindSpeed:  col("wind_speed"),
+			WindDeg:    col("wind_deg"),
+			Visibility: col("visibility"),
+			Clouds:     col("clouds_all"),
+			WeatherID:  col("weather_id"),
+		})
+	}
+	return records, nil
+}
+
+// bulkWriteCounts counts the outcomes of writing bulk history records.
+type bulkWriteCounts struct {
+	written, skipped, failed int
+}
+
+// writeBulkRecords writes the given bulk history records, from the named source (a file
+// or API), to the weather measurement, using the same fields as observations fetched by a
+// normal run. Features that depend on state or on a series of runs (smoothing, pressure
+// trends, degree days, and so on) aren't applied.
+func writeBulkRecords(config Config, w *influxWriter, units, source string, records []bulkRecord, counts *bulkWriteCounts) error {
+	tags := config.locationTags(owmconnector.ProviderOpenWeatherMap)
+	for _, rec := range records {
+		obs, err := rec.observation(units, config.Latitude, config.Longitude, config.ElevationMeters)
+		if err != nil {
+			slog.Warn("Skipping bulk history record", "source", source, "dt", rec.Dt, "error", err)
+			counts.skipped++
+			continue
+		}
+		config.Calibration.ApplyObservation(obs)
+		fields := obs.Fields()
+		if rec.Visibility == nil {
+			delete(fields, "visibility_mi")
+		}
+		if config.ValidateOutput {
+			if err := ValidateFields(schemaWeather, fields); err != nil {
+				return fmt.Errorf("record at %d in '%s': %w", rec.Dt, source, err)
+			}
+		}
+		if err := w.WritePoint(config.WeatherMeasurementName, copyTags(tags), fields, inLocal(obs.Time)); err != nil {
+			slog.Error("Failed to write to influx", "measurement", config.WeatherMeasurementName, "source", source, "dt", rec.Dt, "error", err)
+			counts.failed++
+			continue
+		}
+		counts.written++
+	}
+	return nil
+}
+
+// runImport writes the records in the given OpenWeatherMap History Bulk export files to the
+// weather measurement (see writeBulkRecords).
+func runImport(config Config, w *influxWriter, units string, paths []string) error {
+	switch units {
+	case BulkUnitsStandard, BulkUnitsMetric, BulkUnitsImperial:
+	default:
+		return fmt.Errorf("-units must be '%s', '%s', or '%s'", BulkUnitsStandard, BulkUnitsMetric, BulkUnitsImperial)
+	}
+	if len(paths) == 0 {
+		return errors.New("no files given to import")
+	}
+
+	var counts bulkWriteCounts
+	for _, path := range paths {
+		records, err := readBulkHistory(path)
+		if err != nil {
+			return fmt.Errorf("failed to read '%s': %w", path, err)
+		}
+		if err := writeBulkRecords(config, w, units, path, records, &counts); err != nil {
+			return err
+		}
+		slog.Info("Imported bulk history file", "file", path, "records", len(records))
+	}
+	slog.Info("Bulk history import complete", "written", counts.written, "skipped", counts.skipped, "failed", counts.failed)
+	if counts.failed > 0 {
+		return fmt.Errorf("%d point(s) failed to write", counts.failed)
+	}
+	return nil
+}
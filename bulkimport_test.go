@@ -0,0 +1,89 @@
+package main
+
+import (
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReadBulkHistoryJSON(t *testing.T) {
+	const fixture = `[
+		{"city_name": "Ann Arbor", "lat": 42.28, "lon": -83.74, "main": {"temp": 272.15, "temp_min": 271.0, "temp_max": 273.0, "feels_like": 268.5, "pressure": 1021, "humidity": 86}, "wind": {"speed": 3.6, "deg": 250}, "clouds": {"all": 75}, "weather": [{"id": 803, "main": "Clouds", "description": "broken clouds", "icon": "04n"}], "dt": 1704067200, "dt_iso": "2024-01-01 00:00:00 +0000 UTC", "timezone": -18000},
+		{"city_name": "Ann Arbor", "lat": 42.28, "lon": -83.74, "main": {"temp": 271.5, "pressure": 1022}, "wind": {"speed": 2.1}, "clouds": {"all": 0}, "visibility": 10000, "weather": [], "dt": 1704070800}
+	]`
+	got, err := readBulkHistoryJSON(strings.NewReader(fixture))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []bulkRecord{
+		{Dt: 1704067200, Temp: ptr(272.15), FeelsLike: ptr(268.5), Pressure: ptr(1021.0), Humidity: ptr(86.0), WindSpeed: ptr(3.6), WindDeg: ptr(250.0), Clouds: ptr(75.0), WeatherID: ptr(803.0)},
+		{Dt: 1704070800, Temp: ptr(271.5), Pressure: ptr(1022.0), WindSpeed: ptr(2.1), Clouds: ptr(0.0), Visibility: ptr(10000.0)},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("readBulkHistoryJSON() = %+v; want %+v", got, want)
+	}
+
+	if _, err := readBulkHistoryJSON(strings.NewReader(`{"dt": 1}`)); err == nil {
+		t.Error("readBulkHistoryJSON() of a non-array succeeded; want an error")
+	}
+}
+
+func TestReadBulkHistoryCSV(t *testing.T) {
+	const fixture = `dt,dt_iso,timezone,city_name,lat,lon,temp,visibility,dew_point,feels_like,temp_min,temp_max,pressure,sea_level,grnd_level,humidity,wind_speed,wind_deg,wind_gust,rain_1h,rain_3h,snow_1h,snow_3h,clouds_all,weather_id,weather_main,weather_description,weather_icon
+1704067200,2024-01-01 00:00:00 +0000 UTC,-18000,Ann Arbor,42.28,-83.74,-1,,-3.1,-4.65,-2.15,0,1021,,,86,3.6,250,,,,,,75,803,Clouds,broken clouds,04n
+1704070800,2024-01-01 01:00:00 +0000 UTC,-18000,Ann Arbor,42.28,-83.74,-1.65,10000,-3.5,-4.2,-2.5,-1,1022,,,,2.1,n/a,,,,,,0,800,Clear,sky is clear,01n
+`
+	got, err := readBulkHistoryCSV(strings.NewReader(fixture))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []bulkRecord{
+		{Dt: 1704067200, Temp: ptr(-1.0), FeelsLike: ptr(-4.65), Pressure: ptr(1021.0), Humidity: ptr(86.0), WindSpeed: ptr(3.6), WindDeg: ptr(250.0), Clouds: ptr(75.0), WeatherID: ptr(803.0)},
+		{Dt: 1704070800, Temp: ptr(-1.65), FeelsLike: ptr(-4.2), Pressure: ptr(1022.0), WindSpeed: ptr(2.1), Visibility: ptr(10000.0), Clouds: ptr(0.0), WeatherID: ptr(800.0)},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("readBulkHistoryCSV() = %+v; want %+v", got, want)
+	}
+
+	for name, bad := range map[string]string{
+		"no dt column": "temp,humidity\n1,2\n",
+		"invalid dt":   "dt,temp\nyesterday,1\n",
+		"empty":        "",
+	} {
+		if _, err := readBulkHistoryCSV(strings.NewReader(bad)); err == nil {
+			t.Errorf("readBulkHistoryCSV() with %s succeeded; want an error", name)
+		}
+	}
+}
+
+func TestBulkRecordObservation(t *testing.T) {
+	tests := []struct {
+		units       string
+		temp        float64
+		windSpeed   float64
+		wantTempF   float64
+		wantWindMph float64
+	}{
+		{BulkUnitsStandard, 273.15, 10, 32, 22.369},
+		{BulkUnitsMetric, 100, 10, 212, 22.369},
+		{BulkUnitsImperial, 72.5, 10, 72.5, 10},
+	}
+	for _, tt := range tests {
+		rec := bulkRecord{Dt: 1704067200, Temp: ptr(tt.temp), Pressure: ptr(1021.0), Humidity: ptr(86.0), WindSpeed: ptr(tt.windSpeed)}
+		o, err := rec.observation(tt.units, 42.28, -83.74, nil)
+		if err != nil {
+			t.Fatalf("%s: observation() = %v", tt.units, err)
+		}
+		if math.Abs(o.Temp.Unwrap()-tt.wantTempF) > 1e-9 || math.Abs(o.FeelsLike.Unwrap()-tt.wantTempF) > 1e-9 {
+			t.Errorf("%s: temp, feels like = %v, %v; want %v", tt.units, o.Temp, o.FeelsLike, tt.wantTempF)
+		}
+		if math.Abs(o.WindSpeed.Unwrap()-tt.wantWindMph) > 0.001 {
+			t.Errorf("%s: wind speed = %v mph; want %v", tt.units, o.WindSpeed, tt.wantWindMph)
+		}
+	}
+
+	if _, err := (bulkRecord{Dt: 1704067200, Temp: ptr(272.15), Pressure: ptr(1021.0)}).observation(BulkUnitsStandard, 42.28, -83.74, nil); err == nil {
+		t.Error("observation() of a record without humidity succeeded; want an error")
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/cdzombak/libwx"
+
+	"github.com/cdzombak/openweather-influxdb-connector/pkg/owmconnector"
+)
+
+// calibratableFields lists the fields calibration may be configured for. Weather fields are
+// calibrated before any derived values (dew point, heat index, etc.) are calculated, and
+// pollutant concentrations are calibrated before any AQI is calculated.
+var calibratableFields = map[string]bool{
+	"temp_f":                 true,
+	"rel_humidity":           true,
+	"barometric_pressure_mb": true,
+	"wind_speed_mph":         true,
+	"co":                     true,
+	"no":                     true,
+	"no2":                    true,
+	"o3":                     true,
+	"so2":                    true,
+	"pm25":                   true,
+	"pm10":                   true,
+	"nh3":                    true,
+}
+
+// Calibration is a linear correction applied to a reported value: value*scale + offset.
+type Calibration struct {
+	Scale  *float64 `json:"scale,omitempty"`
+	Offset float64  `json:"offset,omitempty"`
+}
+
+// CalibrationConfig maps field names to the calibration applied to them.
+type CalibrationConfig map[string]Calibration
+
+// Validate checks that calibration is only configured for supported fields.
+func (c CalibrationConfig) Validate() error {
+	for field := range c {
+		if !calibratableFields[field] {
+			supported := sortedKeys(calibratableFields)
+			return fmt.Errorf("unsupported field '%s' (supported fields: %s)", field, strings.Join(supported, ", "))
+		}
+	}
+	return nil
+}
+
+// Apply returns the calibrated value for the given field. Values for fields without
+// configured calibration are returned unchanged.
+func (c CalibrationConfig) Apply(field string, v float64) float64 {
+	cal, ok := c[field]
+	if !ok {
+		return v
+	}
+	if cal.Scale != nil {
+		v *= *cal.Scale
+	}
+	return v + cal.Offset
+}
+
+// ApplyInt is like Apply, for integer values; the result is rounded to the nearest integer.
+func (c CalibrationConfig) ApplyInt(field string, v int) int {
+	if _, ok := c[field]; !ok {
+		return v
+	}
+	return int(math.Round(c.Apply(field, float64(v))))
+}
+
+// ApplyObservation calibrates the observation's reported values in place, and recalculates
+// its dew point from the calibrated temperature and humidity.
+func (c CalibrationConfig) ApplyObservation(o *owmconnector.Observation) {
+	o.Temp = libwx.TempF(c.Apply("temp_f", o.Temp.Unwrap()))
+	o.Humidity = libwx.ClampedRelHumidity(c.ApplyInt("rel_humidity", o.Humidity.Unwrap()))
+	o.Pressure = libwx.PressureMb(c.Apply("barometric_pressure_mb", o.Pressure.Unwrap()))
+	o.WindSpeed = libwx.SpeedMph(c.Apply("wind_speed_mph", o.WindSpeed.Unwrap()))
+	o.DewPoint = libwx.DewPointF(o.Temp, o.Humidity)
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Subcommands. Running the program without a subcommand is equivalent to "run".
+const (
+	cmdRun      = "run"
+	cmdPrint    = "print"
+	cmdValidate = "validate"
+	cmdInit     = "init"
+	cmdImport   = "import"
+	cmdBackfill = "backfill"
+	cmdStats    = "stats"
+	// nb. the service subcommands are two words
+	cmdServiceInstall = "service install"
+	cmdServiceShow    = "service show"
+	cmdVersion        = "version"
+)
+
+var subcommands = []struct {
+	name  string
+	usage string
+}{
+	{cmdRun, "Fetch current data and write it to the configured outputs (default)."},
+	{cmdPrint, "Fetch current data and print it, without writing it anywhere or updating state."},
+	{cmdValidate, "Check the config file and exit."},
+	{cmdImport, "Write the OpenWeatherMap History Bulk export files given as arguments (JSON or CSV) to the weather measurement."},
+	{cmdBackfill, "Fetch hourly history from -backfillFrom to -backfillTo from OpenWeatherMap's History API and write it to the weather measurement."},
+	{cmdStats, "Compute summary stats for the period given as an argument (YYYY-MM, YYYY, 'month', or 'year') from InfluxDB, print them, and exit."},
+	{cmdInit, "Write a starter config file to the -config path (from -importEcobeeConfig, if given)."},
+	{cmdServiceShow, "Print a systemd service and timer (or macOS launchd plist) that run this program with the -config file."},
+	{cmdServiceInstall, "Write the files printed by 'service show' to their standard locations."},
+	{cmdVersion, "Print version and exit."},
+}
+
+// options holds the command-line flags, and the subcommand they were given with.
+type options struct {
+	cmd                string
+	args               []string
+	configFile         string
+	printData          bool
+	printFormat        string
+	sendDigestEmail    bool
+	logLevel           string
+	logFormat          string
+	debug              bool
+	debugDir           string
+	importEcobeeConfig string
+	lineProtocol       bool
+	bulkUnits          string
+	backfillFrom       string
+	backfillTo         string
+	recordDir          string
+	replayDir          string
+	serviceType        string
+	serviceInterval    string
+	fakeNow            string
+	printVersion       bool
+}
+
+// dryRun reports whether nothing should be written. nb. the print subcommand writes
+// nothing, sends no notifications or e-mail, and doesn't save state.
+func (o options) dryRun() bool {
+	return o.cmd == cmdPrint
+}
+
+// sideEffects reports whether the run may do anything besides writing points. nb. a
+// replay rewrites points, but sends no notifications, e-mail, or uploads, doesn't take
+// the leader lock, and doesn't save state.
+func (o options) sideEffects() bool {
+	return !o.dryRun() && o.replayDir == ""
+}
+
+// parseFlags defines and parses the command-line flags for the given subcommand.
+func parseFlags(cmd string) options {
+	o := options{cmd: cmd}
+	flag.StringVar(&o.configFile, "config", "./config.json", "Configuration JSON file.")
+	flag.BoolVar(&o.printData, "printData", false, "Print weather/pollution data to stdout.")
+	flag.StringVar(&o.printFormat, "format", PrintFormatTable, "With -printData or the print subcommand, print data as a human-readable table, or print every point as json, lineprotocol, or csv.")
+	flag.BoolVar(&o.sendDigestEmail, "sendDigest", false, "Send the daily digest e-mail and exit (requires email.mode to be 'digest').")
+	flag.StringVar(&o.logLevel, "logLevel", "", "Minimum log level: debug, info, warn, or error. Overrides log_level in the config file. (default \"info\")")
+	flag.StringVar(&o.logFormat, "logFormat", "", "Log format: text or json. Overrides log_format in the config file. (default \"text\")")
+	flag.BoolVar(&o.debug, "debug", false, "Log the raw responses returned by OpenWeatherMap (implies -logLevel debug).")
+	flag.StringVar(&o.debugDir, "debugDir", "", "With -debug, also save each raw OpenWeatherMap response to a file in this directory.")
+	flag.StringVar(&o.importEcobeeConfig, "importEcobeeConfig", "", "Convert the given ecobee_influx_connector config file to a config for this program, print it, and exit.")
+	flag.BoolVar(&o.lineProtocol, "lineProtocol", false, "Write points as InfluxDB line protocol to stdout, and nowhere else (e.g. for use as a Telegraf exec input).")
+	flag.StringVar(&o.bulkUnits, "units", BulkUnitsStandard, "With the import subcommand, the units the History Bulk export uses: standard, metric, or imperial.")
+	flag.StringVar(&o.backfillFrom, "backfillFrom", "", "With the backfill subcommand, the start of the period to backfill: an RFC 3339 timestamp or YYYY-MM-DD date.")
+	flag.StringVar(&o.backfillTo, "backfillTo", "", "With the backfill subcommand, the end of the period to backfill: an RFC 3339 timestamp or YYYY-MM-DD date. (default now)")
+	flag.StringVar(&o.recordDir, "record", "", "Save this run's raw API responses to a new subdirectory of this directory, for later use with -replay.")
+	flag.StringVar(&o.replayDir, "replay", "", "Process the raw API responses recorded in this run directory (created by -record) instead of querying any APIs, and rewrite the resulting points.")
+	flag.StringVar(&o.serviceType, "serviceType", "", "With the service subcommands, the service manager to generate files for: systemd or launchd. (default launchd on macOS, systemd elsewhere)")
+	flag.StringVar(&o.serviceInterval, "serviceInterval", "10m", "With the service subcommands, how often to run.")
+	flag.StringVar(&o.fakeNow, "now", "", "Pretend the current time is this RFC 3339 timestamp, for testing day-boundary features like -sendDigest and the stats subcommand.")
+	flag.BoolVar(&o.printVersion, "version", false, "Print version and exit.")
+	flag.Parse()
+	o.args = flag.Args()
+	if o.dryRun() {
+		o.printData = true
+	}
+	return o
+}
+
+// parseSubcommand removes the subcommand, if any, from os.Args and returns it.
+func parseSubcommand() (string, error) {
+	if len(os.Args) < 2 || strings.HasPrefix(os.Args[1], "-") {
+		return cmdRun, nil
+	}
+	for _, sc := range subcommands {
+		words := strings.Fields(sc.name)
+		if len(os.Args) > len(words) && strings.Join(os.Args[1:1+len(words)], " ") == sc.name {
+			os.Args = append(os.Args[:1], os.Args[1+len(words):]...)
+			return sc.name, nil
+		}
+	}
+	return "", fmt.Errorf("unknown subcommand '%s'", os.Args[1])
+}
+
+// checkArgs returns an error if positional arguments remain after parsing flags, which
+// is only allowed for the import subcommand and the stats subcommand's period. This
+// catches a subcommand given after flags (e.g. "-config config.json print"), which would
+// otherwise be ignored and do a normal run.
+func checkArgs(cmd string, args []string) error {
+	if cmd == cmdStats {
+		if len(args) != 1 {
+			return errors.New("the stats subcommand takes exactly one period argument")
+		}
+		return nil
+	}
+	if cmd == cmdImport || len(args) == 0 {
+		return nil
+	}
+	for _, sc := range subcommands {
+		if args[0] == strings.Fields(sc.name)[0] {
+			return fmt.Errorf("the subcommand '%s' must be given before any flags", args[0])
+		}
+	}
+	return fmt.Errorf("unexpected argument '%s'", args[0])
+}
+
+// usage prints the program's usage, including subcommands and flags.
+func usage() {
+	out := flag.CommandLine.Output()
+	fmt.Fprintf(out, "Usage: %s [subcommand] [flags]\n\nSubcommands:\n", os.Args[0])
+	for _, sc := range subcommands {
+		fmt.Fprintf(out, "  %-17s%s\n", sc.name, sc.usage)
+	}
+	fmt.Fprintf(out, "\nFlags:\n")
+	flag.PrintDefaults()
+}
+
+// runInit writes a starter config file to path, refusing to overwrite an existing file.
+// If ecobeeConfig is set, the starter config is imported from that ecobee_influx_connector
+// config file.
+func runInit(path, ecobeeConfig string) error {
+	if _, err := os.Stat(path); err == nil {
+		return fmt.Errorf("'%s' already exists", path)
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	config := Config{
+		APIKey:                   "",
+		Latitude:                 42.2808,
+		Longitude:                -83.743,
+		InfluxServer:             "http://localhost:8086",
+		InfluxBucket:             "weather",
+		WeatherMeasurementName:   "weather",
+		PollutionMeasurementName: "pollution",
+	}
+	todo := []string{"api_key", "lat", "lon", "influx_server", "influx_bucket", "influx_token (or influx_user and influx_password)"}
+	if ecobeeConfig != "" {
+		var err error
+		if config, todo, err = ImportEcobeeConfig(ecobeeConfig); err != nil {
+			return fmt.Errorf("unable to import ecobee_influx_connector config file '%s': %w", ecobeeConfig, err)
+		}
+	}
+
+	b, err := json.MarshalIndent(config, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode config: %w", err)
+	}
+	if err := os.WriteFile(path, append(b, '\n'), 0o600); err != nil {
+		return err
+	}
+	fmt.Printf("Wrote %s.\nFill in these fields before using it: %s\n", path, strings.Join(todo, ", "))
+	return nil
+}
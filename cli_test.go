@@ -0,0 +1,107 @@
+package main
+
+import (
+	"os"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseSubcommand(t *testing.T) {
+	tests := []struct {
+		name     string
+		args     []string
+		wantCmd  string
+		wantArgs []string
+		wantErr  bool
+	}{
+		{"no args", []string{"prog"}, cmdRun, []string{"prog"}, false},
+		{"flags only", []string{"prog", "-config", "c.json"}, cmdRun, []string{"prog", "-config", "c.json"}, false},
+		{"run", []string{"prog", "run", "-config", "c.json"}, cmdRun, []string{"prog", "-config", "c.json"}, false},
+		{"print", []string{"prog", "print"}, cmdPrint, []string{"prog"}, false},
+		{"backfill", []string{"prog", "backfill", "-backfillFrom", "2024-01-01"}, cmdBackfill, []string{"prog", "-backfillFrom", "2024-01-01"}, false},
+		{"stats", []string{"prog", "stats", "-config", "c.json", "2024-05"}, cmdStats, []string{"prog", "-config", "c.json", "2024-05"}, false},
+		{"import with files", []string{"prog", "import", "-units", "metric", "a.csv"}, cmdImport, []string{"prog", "-units", "metric", "a.csv"}, false},
+		{"flags before subcommand", []string{"prog", "-config", "c.json", "print"}, cmdRun, []string{"prog", "-config", "c.json", "print"}, false},
+		{"service show", []string{"prog", "service", "show", "-serviceType", "launchd"}, cmdServiceShow, []string{"prog", "-serviceType", "launchd"}, false},
+		{"service install", []string{"prog", "service", "install"}, cmdServiceInstall, []string{"prog"}, false},
+		{"service without action", []string{"prog", "service"}, "", nil, true},
+		{"service with unknown action", []string{"prog", "service", "start"}, "", nil, true},
+		{"unknown subcommand", []string{"prog", "frobnicate"}, "", nil, true},
+	}
+	origArgs := os.Args
+	defer func() { os.Args = origArgs }()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.Args = append([]string(nil), tt.args...)
+			cmd, err := parseSubcommand()
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("parseSubcommand() = %q; want an error", cmd)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseSubcommand() returned error: %v", err)
+			}
+			if cmd != tt.wantCmd {
+				t.Errorf("parseSubcommand() = %q; want %q", cmd, tt.wantCmd)
+			}
+			if !reflect.DeepEqual(os.Args, tt.wantArgs) {
+				t.Errorf("os.Args = %q; want %q", os.Args, tt.wantArgs)
+			}
+		})
+	}
+}
+
+func TestCheckArgs(t *testing.T) {
+	tests := []struct {
+		cmd     string
+		args    []string
+		wantErr bool
+	}{
+		{cmdRun, nil, false},
+		{cmdImport, []string{"a.json", "b.csv"}, false},
+		{cmdRun, []string{"print"}, true},
+		{cmdRun, []string{"service", "show"}, true},
+		{cmdPrint, []string{"extra"}, true},
+		{cmdStats, []string{"2024-05"}, false},
+		{cmdStats, nil, true},
+		{cmdStats, []string{"2024-05", "2024-06"}, true},
+	}
+	for _, tt := range tests {
+		if err := checkArgs(tt.cmd, tt.args); (err != nil) != tt.wantErr {
+			t.Errorf("checkArgs(%q, %q) error = %v; want error: %t", tt.cmd, tt.args, err, tt.wantErr)
+		}
+	}
+}
+
+func TestParseBackfillTime(t *testing.T) {
+	origTZ := localTZ
+	defer func() { localTZ = origTZ }()
+	detroit, err := time.LoadLocation("America/Detroit")
+	if err != nil {
+		t.Skip("timezone database unavailable")
+	}
+	localTZ = detroit
+
+	tests := []struct {
+		in      string
+		want    time.Time
+		wantErr bool
+	}{
+		{"2024-03-01T12:00:00Z", time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC), false},
+		{"2024-03-01", time.Date(2024, 3, 1, 0, 0, 0, 0, detroit), false},
+		{"03/01/2024", time.Time{}, true},
+	}
+	for _, tt := range tests {
+		got, err := parseBackfillTime(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseBackfillTime(%q) error = %v; want error: %t", tt.in, err, tt.wantErr)
+			continue
+		}
+		if !got.Equal(tt.want) {
+			t.Errorf("parseBackfillTime(%q) = %s; want %s", tt.in, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"time"
+)
+
+const defaultClimateForecastMeasurementName = "climate_forecast"
+
+// ClimateForecastConfig describes the configuration for the 30-day climate forecast
+// measurement.
+type ClimateForecastConfig struct {
+	MeasurementName string `json:"measurement_name,omitempty"`
+}
+
+// Measurement returns the configured measurement name, or the default "climate_forecast".
+func (c ClimateForecastConfig) Measurement() string {
+	if c.MeasurementName == "" {
+		return defaultClimateForecastMeasurementName
+	}
+	return c.MeasurementName
+}
+
+// climateForecastDay is one day of the climate forecast.
+type climateForecastDay struct {
+	Start  time.Time
+	Fields map[string]interface{}
+}
+
+// fetchClimateForecast fetches OpenWeatherMap's 30-day climate forecast. Each day's
+// temp_anomaly_f is its mean temperature's departure from the mean over the whole
+// forecast period, which is also written as period_temp_mean_f.
+// See https://openweathermap.org/api/forecast30
+func fetchClimateForecast(apiKey string, lat, lon float64, loc *time.Location) ([]climateForecastDay, error) {
+	q := owmPaidQuery(apiKey, lat, lon)
+	q.Set("units", "imperial")
+	req, err := http.NewRequest(http.MethodGet, "https://pro.openweathermap.org/data/2.5/forecast/climate?"+q.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	var body struct {
+		List []struct {
+			Dt   int64 `json:"dt"`
+			Temp struct {
+				Day   float64 `json:"day"`
+				Min   float64 `json:"min"`
+				Max   float64 `json:"max"`
+				Night float64 `json:"night"`
+			} `json:"temp"`
+			Pressure float64  `json:"pressure"`
+			Humidity int      `json:"humidity"`
+			Speed    float64  `json:"speed"`
+			Deg      float64  `json:"deg"`
+			Clouds   int      `json:"clouds"`
+			Rain     *float64 `json:"rain"`
+			Snow     *float64 `json:"snow"`
+			Weather  []struct {
+				ID          int    `json:"id"`
+				Description string `json:"description"`
+			} `json:"weather"`
+		} `json:"list"`
+	}
+	if err := doOWMPaidRequest(req, "Climate Forecast", &body); err != nil {
+		return nil, err
+	}
+	if len(body.List) == 0 {
+		return nil, errors.New("OpenWeatherMap Climate Forecast API didn't return any days")
+	}
+
+	var periodSum float64
+	for _, d := range body.List {
+		periodSum += (d.Temp.Min + d.Temp.Max) / 2
+	}
+	periodMean := periodSum / float64(len(body.List))
+
+	days := make([]climateForecastDay, 0, len(body.List))
+	for _, d := range body.List {
+		t := time.Unix(d.Dt, 0).In(loc)
+		mean := (d.Temp.Min + d.Temp.Max) / 2
+		fields := map[string]interface{}{
+			"temp_max_f":         d.Temp.Max,
+			"temp_min_f":         d.Temp.Min,
+			"temp_day_f":         d.Temp.Day,
+			"temp_night_f":       d.Temp.Night,
+			"temp_mean_f":        mean,
+			"temp_anomaly_f":     mean - periodMean,
+			"period_temp_mean_f": periodMean,
+			"rel_humidity":       d.Humidity,
+			"pressure_mb":        d.Pressure,
+			"wind_speed_mph":     d.Speed,
+			"wind_bearing":       d.Deg,
+			"cloud_cover":        d.Clouds,
+			"rain_mm":            valueOrZero(d.Rain),
+			"snow_mm":            valueOrZero(d.Snow),
+		}
+		if len(d.Weather) > 0 {
+			fields["condition_id"] = d.Weather[0].ID
+			fields["condition"] = d.Weather[0].Description
+		}
+		days = append(days, climateForecastDay{
+			Start:  time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc),
+			Fields: fields,
+		})
+	}
+	return days, nil
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+const (
+	defaultClimatologyWindowDays  = 7
+	defaultClimatologyWindowHours = 1
+	defaultClimatologyMinSamples  = 50
+	climatologyQueryTimeout       = 30 * time.Second
+)
+
+// ClimatologyConfig describes the configuration for comparing current conditions to
+// the historical distribution of conditions previously written by this program.
+type ClimatologyConfig struct {
+	WindowDays  int `json:"window_days,omitempty"`
+	WindowHours int `json:"window_hours,omitempty"`
+	MinSamples  int `json:"min_samples,omitempty"`
+}
+
+func (c ClimatologyConfig) windowDays() int {
+	if c.WindowDays <= 0 {
+		return defaultClimatologyWindowDays
+	}
+	return c.WindowDays
+}
+
+func (c ClimatologyConfig) windowHours() int {
+	if c.WindowHours <= 0 {
+		return defaultClimatologyWindowHours
+	}
+	return c.WindowHours
+}
+
+func (c ClimatologyConfig) minSamples() int {
+	if c.MinSamples <= 0 {
+		return defaultClimatologyMinSamples
+	}
+	return c.MinSamples
+}
+
+// Climatology compares a current value to its historical distribution.
+type Climatology struct {
+	Normal     float64
+	Departure  float64
+	Percentile float64
+	Samples    int
+}
+
+// historicalTemps queries Influx for temp_f values written for this location in prior
+// years, within the configured window around the same day of year and time of day.
+func (c ClimatologyConfig) historicalTemps(ctx context.Context, w *influxWriter, measurement string, tags map[string]string, t time.Time) ([]float64, error) {
+	t = t.UTC()
+	stop := t.AddDate(0, 0, -(c.windowDays() + 1))
+
+	var tagFilters string
+	keys := sortedKeys(tags)
+	for _, k := range keys {
+		tagFilters += fmt.Sprintf("  |> filter(fn: (r) => r[%q] == %q)\n", k, tags[k])
+	}
+
+	q := fmt.Sprintf(`import "date"
+
+from(bucket: %q)
+  |> range(start: 0, stop: %s)
+  |> filter(fn: (r) => r._measurement == %q and r._field == "temp_f")
+%s  |> map(fn: (r) => ({r with yd: date.yearDay(t: r._time), hr: date.hour(t: r._time)}))
+  |> filter(fn: (r) => {
+      dd = if r.yd > %d then r.yd - %d else %d - r.yd
+      hd = if r.hr > %d then r.hr - %d else %d - r.hr
+      return (dd <= %d or dd >= 366 - %d) and (hd <= %d or hd >= 24 - %d)
+    })
+  |> group()
+  |> keep(columns: ["_value"])
+`, w.bucket, stop.Format(time.RFC3339), measurement, tagFilters,
+		t.YearDay(), t.YearDay(), t.YearDay(),
+		t.Hour(), t.Hour(), t.Hour(),
+		c.windowDays(), c.windowDays(), c.windowHours(), c.windowHours())
+
+	return w.QueryFloatValues(ctx, q)
+}
+
+// Compare compares the given temperature to the historical distribution for this date and
+// time of day. The boolean return value is false until enough history has accumulated.
+func (c ClimatologyConfig) Compare(w *influxWriter, measurement string, tags map[string]string, t time.Time, tempF float64) (Climatology, bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), climatologyQueryTimeout)
+	defer cancel()
+	history, err := c.historicalTemps(ctx, w, measurement, tags, t)
+	if err != nil {
+		return Climatology{}, false, err
+	}
+	if len(history) < c.minSamples() {
+		return Climatology{}, false, nil
+	}
+
+	sum := 0.0
+	below := 0
+	for _, v := range history {
+		sum += v
+		if v < tempF {
+			below++
+		}
+	}
+	normal := sum / float64(len(history))
+	return Climatology{
+		Normal:     normal,
+		Departure:  tempF - normal,
+		Percentile: 100.0 * float64(below) / float64(len(history)),
+		Samples:    len(history),
+	}, true, nil
+}
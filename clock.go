@@ -0,0 +1,45 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+var (
+	// nowFunc returns the current time. It's replaced when the -now option is used.
+	nowFunc = time.Now
+	// localTZ is the timezone used for day-boundary calculations (degree days, growing
+	// seasons, digests, and stats).
+	localTZ = time.Local
+)
+
+// now returns the current time, in the configured timezone.
+func now() time.Time {
+	return nowFunc().In(localTZ)
+}
+
+// inLocal returns t in the configured timezone.
+func inLocal(t time.Time) time.Time {
+	return t.In(localTZ)
+}
+
+// setupClock configures the timezone used for day-boundary calculations (an IANA name
+// like "America/Detroit"; empty for the system timezone) and, if fixedNow is set, pins the
+// current time to the given RFC 3339 timestamp.
+func setupClock(timezone, fixedNow string) error {
+	if timezone != "" {
+		loc, err := time.LoadLocation(timezone)
+		if err != nil {
+			return fmt.Errorf("invalid timezone '%s': %w", timezone, err)
+		}
+		localTZ = loc
+	}
+	if fixedNow != "" {
+		t, err := time.Parse(time.RFC3339, fixedNow)
+		if err != nil {
+			return fmt.Errorf("invalid -now timestamp '%s': %w", fixedNow, err)
+		}
+		nowFunc = func() time.Time { return t }
+	}
+	return nil
+}
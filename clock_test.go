@@ -0,0 +1,148 @@
+package main
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+	"time"
+
+	owm "github.com/briandowns/openweathermap"
+)
+
+// dstTransitions are the 2024 DST transition days in America/New_York.
+var dstTransitions = []struct {
+	name string
+	day  [3]int
+}{
+	{"spring forward", [3]int{2024, 3, 10}},
+	{"fall back", [3]int{2024, 11, 3}},
+}
+
+// pinClock sets the configured timezone and pins the current time for the duration of the
+// test. It returns the timezone and a function that sets the current time.
+func pinClock(t *testing.T, timezone string) (*time.Location, func(time.Time)) {
+	t.Helper()
+	loc, err := time.LoadLocation(timezone)
+	if err != nil {
+		t.Skip("timezone database unavailable")
+	}
+	origNow, origTZ := nowFunc, localTZ
+	t.Cleanup(func() { nowFunc, localTZ = origNow, origTZ })
+	localTZ = loc
+	current := time.Time{}
+	nowFunc = func() time.Time { return current }
+	return loc, func(ts time.Time) { current = ts.UTC() }
+}
+
+func TestRecordDailyTempAcrossDST(t *testing.T) {
+	for _, tt := range dstTransitions {
+		t.Run(tt.name, func(t *testing.T) {
+			loc, setNow := pinClock(t, "America/New_York")
+			day := time.Date(tt.day[0], time.Month(tt.day[1]), tt.day[2], 0, 0, 0, 0, loc)
+			s := &State{}
+			var completed []DailyTempSummary
+			// run every 10 minutes from noon the day before until noon the day after,
+			// recording the local day of month and hour (DDHH) as the temperature
+			for ts := day.Add(-12 * time.Hour); ts.Before(day.AddDate(0, 0, 1).Add(12 * time.Hour)); ts = ts.Add(10 * time.Minute) {
+				setNow(ts)
+				// nb. pass the UTC time, as an observation timestamp would be
+				if d := s.RecordDailyTemp(nowFunc(), float64(100*now().Day()+now().Hour())); d != nil {
+					completed = append(completed, *d)
+				}
+			}
+
+			if len(completed) != 2 {
+				t.Fatalf("got %d completed days; want 2", len(completed))
+			}
+			d := completed[1]
+			if want := day.Format(dailyTempSummaryDateFormat); d.Date != want {
+				t.Errorf("completed day is %s; want %s", d.Date, want)
+			}
+			if wantMin, wantMax := float64(100*day.Day()), float64(100*day.Day()+23); d.MinF != wantMin || d.MaxF != wantMax {
+				t.Errorf("completed day's min/max = %v/%v; want %v/%v", d.MinF, d.MaxF, wantMin, wantMax)
+			}
+			if start := d.Start(localTZ); !start.Equal(day) {
+				t.Errorf("completed day starts at %s; want %s", start, day)
+			}
+		})
+	}
+}
+
+func TestPressureTrendAcrossDST(t *testing.T) {
+	for _, tt := range dstTransitions {
+		t.Run(tt.name, func(t *testing.T) {
+			loc, setNow := pinClock(t, "America/New_York")
+			start := time.Date(tt.day[0], time.Month(tt.day[1]), tt.day[2], 0, 0, 0, 0, loc)
+			s := &State{}
+			// run every 10 minutes through the transition, with pressure rising 1 mb per
+			// elapsed hour, persisting state between runs
+			for ts := start; ts.Before(start.Add(6 * time.Hour)); ts = ts.Add(10 * time.Minute) {
+				setNow(ts)
+				p := 1000 + now().Sub(start).Hours()
+				trend, ok := s.PressureTrend3h(now(), p)
+				if now().Sub(start) >= pressureTrendWindow-pressureTrendTolerance {
+					if !ok {
+						t.Fatalf("no pressure trend at %s", now())
+					}
+					// at least 2.5 hours have elapsed, so the closest reading is 3 hours back or the first one
+					want := math.Min(3, now().Sub(start).Hours())
+					if math.Abs(trend-want) > 1e-9 {
+						t.Errorf("pressure trend at %s = %v; want %v", now(), trend, want)
+					}
+				}
+				s.RecordPressure(now(), p)
+
+				b, err := json.Marshal(s)
+				if err != nil {
+					t.Fatal(err)
+				}
+				s = &State{}
+				if err := json.Unmarshal(b, s); err != nil {
+					t.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestEcobeeForecastDaysAcrossDST(t *testing.T) {
+	for _, tt := range dstTransitions {
+		t.Run(tt.name, func(t *testing.T) {
+			loc, setNow := pinClock(t, "America/New_York")
+			day := time.Date(tt.day[0], time.Month(tt.day[1]), tt.day[2], 0, 0, 0, 0, loc)
+			setNow(day.Add(time.Hour))
+
+			// 3-hourly entries, aligned to UTC like OpenWeatherMap's; only the entry closest
+			// to local noon on the transition day is clear
+			noon := time.Date(tt.day[0], time.Month(tt.day[1]), tt.day[2], 12, 0, 0, 0, loc)
+			var closest time.Time
+			var entries []time.Time
+			for ts := day.UTC().Truncate(3 * time.Hour); ts.Before(day.AddDate(0, 0, 1)); ts = ts.Add(3 * time.Hour) {
+				entries = append(entries, ts)
+				if closest.IsZero() || ts.Sub(noon).Abs() < closest.Sub(noon).Abs() {
+					closest = ts
+				}
+			}
+			forecast := &owm.Forecast5WeatherData{}
+			for _, ts := range entries {
+				id := 500
+				if ts.Equal(closest) {
+					id = 800
+				}
+				forecast.List = append(forecast.List, owm.Forecast5WeatherList{
+					Dt:      int(ts.Unix()),
+					Weather: []owm.Weather{{ID: id}},
+				})
+			}
+
+			days := ecobeeForecastDays(forecast, now(), localTZ)
+			if len(days) == 0 || !days[0].Start.Equal(day) {
+				t.Fatalf("first forecast day doesn't start at %s", day)
+			}
+			_, want := ecobeeConditionFor(800, true)
+			if got := days[0].Fields["condition"]; got != want {
+				t.Errorf("condition = %v; want %v (from the entry at %s)", got, want, closest.In(loc))
+			}
+		})
+	}
+}
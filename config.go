@@ -0,0 +1,444 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"time"
+
+	owm "github.com/briandowns/openweathermap"
+
+	"github.com/cdzombak/openweather-influxdb-connector/pkg/owmconnector"
+)
+
+const (
+	influxTimeout    = 3 * time.Second
+	influxAttempts   = 3
+	influxRetryDelay = 1 * time.Second
+
+	sourceTag                     = "data_source"
+	thermostatNameTag             = "thermostat_name"
+	latTag                        = "latitude"
+	lonTag                        = "longitude"
+	staleTag                      = "stale"
+	ecobeeWeatherMeasurementName  = "ecobee_weather"
+	ecobeeForecastMeasurementName = "ecobee_forecast"
+
+	// sourceAttribution is the attribution OpenWeather requires when displaying its data.
+	// See https://openweathermap.org/full-price#licenses
+	sourceAttribution = "Weather data provided by OpenWeather (https://openweathermap.org/), licensed under CC BY-SA 4.0"
+)
+
+const (
+	// StaleDataActionSkip exits with an error, without writing, if the observation is stale.
+	StaleDataActionSkip = "skip"
+	// StaleDataActionTag writes a stale observation tagged stale=true, then exits with an error.
+	StaleDataActionTag = "tag"
+)
+
+// Config describes the configuration for the openweather-influxdb-connector program.
+type Config struct {
+	APIKey                        string                   `json:"api_key"`
+	Provider                      string                   `json:"provider,omitempty"`
+	NWSStation                    string                   `json:"nws_station,omitempty"`
+	TomorrowIO                    *TomorrowIOConfig        `json:"tomorrowio,omitempty"`
+	WeatherAPI                    *WeatherAPIConfig        `json:"weatherapi,omitempty"`
+	FallbackProviders             []string                 `json:"fallback_providers,omitempty"`
+	ComparisonProviders           []string                 `json:"comparison_providers,omitempty"`
+	Latitude                      float64                  `json:"lat"`
+	Longitude                     float64                  `json:"lon"`
+	InfluxName                    string                   `json:"influx_name,omitempty"`
+	InfluxServer                  string                   `json:"influx_server"`
+	InfluxOrg                     string                   `json:"influx_org,omitempty"`
+	InfluxUser                    string                   `json:"influx_user,omitempty"`
+	InfluxPass                    string                   `json:"influx_password,omitempty"`
+	InfluxToken                   string                   `json:"influx_token,omitempty"`
+	InfluxBucket                  string                   `json:"influx_bucket"`
+	InfluxHealthCheckDisabled     bool                     `json:"influx_health_check_disabled"`
+	InfluxProxyURL                string                   `json:"influx_proxy_url,omitempty"`
+	InfluxTLS                     *InfluxTLSConfig         `json:"influx_tls,omitempty"`
+	InfluxWritePrecision          string                   `json:"influx_write_precision,omitempty"`
+	InfluxBatchWrites             bool                     `json:"influx_batch_writes,omitempty"`
+	InfluxOutputs                 []InfluxTargetConfig     `json:"influx_outputs,omitempty"`
+	NATS                          *NATSConfig              `json:"nats,omitempty"`
+	Postgres                      *PostgresConfig          `json:"postgres,omitempty"`
+	QuestDB                       *QuestDBConfig           `json:"questdb,omitempty"`
+	Statsd                        *StatsdConfig            `json:"statsd,omitempty"`
+	OTLP                          *OTLPConfig              `json:"otlp,omitempty"`
+	AWS                           *AWSConfig               `json:"aws,omitempty"`
+	Exec                          *ExecConfig              `json:"exec,omitempty"`
+	OutputRoutes                  map[string][]string      `json:"output_routes,omitempty"`
+	OutputWriteTimeout            string                   `json:"output_write_timeout,omitempty"`
+	OutputFailureBudget           int                      `json:"output_failure_budget,omitempty"`
+	WeatherMeasurementName        string                   `json:"wx_measurement_name"`
+	WriteEcobeeWeatherMeasurement bool                     `json:"write_ecobee_weather_measurement"`
+	WriteEcobeeForecast           bool                     `json:"write_ecobee_forecast,omitempty"`
+	EcobeeThermostatName          string                   `json:"ecobee_thermostat_name"`
+	EcobeeMeasurementName         string                   `json:"ecobee_measurement_name,omitempty"`
+	EcobeeExtraTags               map[string]string        `json:"ecobee_extra_tags,omitempty"`
+	PollutionMeasurementName      string                   `json:"pollution_measurement_name"`
+	OWMTimeout                    string                   `json:"owm_timeout,omitempty"`
+	InfluxDuplicatePolicy         string                   `json:"influx_duplicate_policy,omitempty"`
+	SkipUnchangedObservations     bool                     `json:"skip_unchanged_observations,omitempty"`
+	MaxDataAge                    string                   `json:"max_data_age,omitempty"`
+	StaleDataAction               string                   `json:"stale_data_action,omitempty"`
+	WallClockTimestamps           []string                 `json:"wall_clock_timestamps,omitempty"`
+	ElevationMeters               *float64                 `json:"elevation_m,omitempty"`
+	Email                         *EmailConfig             `json:"email,omitempty"`
+	StateDir                      string                   `json:"state_dir,omitempty"`
+	ValidateOutput                bool                     `json:"validate_output,omitempty"`
+	DegreeDays                    *DegreeDaysConfig        `json:"degree_days,omitempty"`
+	GrowingDegreeDays             *GrowingDegreeDaysConfig `json:"growing_degree_days,omitempty"`
+	Climatology                   *ClimatologyConfig       `json:"climatology,omitempty"`
+	FreezeRisk                    *FreezeRiskConfig        `json:"freeze_risk,omitempty"`
+	EnergyPrices                  *EnergyPriceConfig       `json:"energy_prices,omitempty"`
+	PurpleAir                     *PurpleAirConfig         `json:"purpleair,omitempty"`
+	AirNow                        *AirNowConfig            `json:"airnow,omitempty"`
+	OpenAQ                        *OpenAQConfig            `json:"openaq,omitempty"`
+	Pollen                        *PollenConfig            `json:"pollen,omitempty"`
+	METAR                         *METARConfig             `json:"metar,omitempty"`
+	Marine                        *MarineConfig            `json:"marine,omitempty"`
+	OWMPaid                       *OWMPaidConfig           `json:"owm_paid_apis,omitempty"`
+	ClimateForecast               *ClimateForecastConfig   `json:"climate_forecast,omitempty"`
+	CWOP                          *CWOPConfig              `json:"cwop,omitempty"`
+	DynamicLocation               *DynamicLocationConfig   `json:"dynamic_location,omitempty"`
+	Windy                         *WindyConfig             `json:"windy,omitempty"`
+	Notifications                 *NotificationsConfig     `json:"notifications,omitempty"`
+	Calibration                   CalibrationConfig        `json:"calibration,omitempty"`
+	Smoothing                     SmoothingConfig          `json:"smoothing,omitempty"`
+	AQIStandards                  []string                 `json:"aqi_standards,omitempty"`
+	PollutionCategoryTags         bool                     `json:"pollution_category_tags,omitempty"`
+	AttributionDisabled           bool                     `json:"attribution_disabled,omitempty"`
+	StatsMeasurementName          string                   `json:"stats_measurement_name,omitempty"`
+	FieldTypes                    map[string]FieldType     `json:"field_types,omitempty"`
+	FieldTypeGuard                bool                     `json:"field_type_guard,omitempty"`
+	HeartbeatURL                  string                   `json:"heartbeat_url,omitempty"`
+	HeartbeatFailURL              string                   `json:"heartbeat_fail_url,omitempty"`
+	LeaderLock                    *LeaderLockConfig        `json:"leader_lock,omitempty"`
+	LogLevel                      string                   `json:"log_level,omitempty"`
+	LogFormat                     string                   `json:"log_format,omitempty"`
+	Timezone                      string                   `json:"timezone,omitempty"`
+}
+
+// ecobeeMeasurement returns the configured ecobee weather measurement name, or the default "ecobee_weather".
+func (c Config) ecobeeMeasurement() string {
+	if c.EcobeeMeasurementName == "" {
+		return ecobeeWeatherMeasurementName
+	}
+	return c.EcobeeMeasurementName
+}
+
+// ecobeeTags returns the tag set for ecobee-compatible points: the thermostat name and
+// data source, plus any configured extra tags.
+func (c Config) ecobeeTags(source string) map[string]string {
+	tags := map[string]string{
+		thermostatNameTag: c.EcobeeThermostatName,
+		sourceTag:         source,
+	}
+	for k, v := range c.EcobeeExtraTags {
+		tags[k] = v
+	}
+	return tags
+}
+
+// primaryInfluxTarget returns the InfluxDB target described by the top-level influx_* fields.
+func (c Config) primaryInfluxTarget() InfluxTargetConfig {
+	return InfluxTargetConfig{
+		Name:                      c.InfluxName,
+		InfluxServer:              c.InfluxServer,
+		InfluxOrg:                 c.InfluxOrg,
+		InfluxUser:                c.InfluxUser,
+		InfluxPass:                c.InfluxPass,
+		InfluxToken:               c.InfluxToken,
+		InfluxBucket:              c.InfluxBucket,
+		InfluxHealthCheckDisabled: c.InfluxHealthCheckDisabled,
+		InfluxProxyURL:            c.InfluxProxyURL,
+		InfluxTLS:                 c.InfluxTLS,
+	}
+}
+
+// locationTags returns the tag set for points about the configured location: the data
+// source and the location's coordinates.
+func (c Config) locationTags(source string) map[string]string {
+	tags := c.coordTags()
+	tags[sourceTag] = source
+	return tags
+}
+
+// coordTags returns the tags identifying the configured location's points, so queries
+// don't mix in data written for other locations sharing the bucket.
+func (c Config) coordTags() map[string]string {
+	return map[string]string{
+		latTag: strconv.FormatFloat(c.Latitude, 'f', 3, 64),
+		lonTag: strconv.FormatFloat(c.Longitude, 'f', 3, 64),
+	}
+}
+
+// coords returns the configured location.
+func (c Config) coords() owm.Coordinates {
+	return owm.Coordinates{
+		Longitude: c.Longitude,
+		Latitude:  c.Latitude,
+	}
+}
+
+// maxDataAge returns the configured max_data_age, or 0 if it's unset.
+func (c Config) maxDataAge() time.Duration {
+	d, _ := time.ParseDuration(c.MaxDataAge)
+	return d
+}
+
+// wallClock reports whether points for the given schema are timestamped with the run's
+// wall clock time, per wall_clock_timestamps.
+func (c Config) wallClock(schema string) bool {
+	return slices.Contains(c.WallClockTimestamps, schema)
+}
+
+// configuredOutput identifies an output by its config key and output name.
+type configuredOutput struct {
+	key  string
+	name string
+}
+
+// outputNames returns every configured output, InfluxDB targets first, for matching
+// against output_routes.
+func (c Config) outputNames() []configuredOutput {
+	outputs := []configuredOutput{{"influx_name", c.primaryInfluxTarget().TargetName()}}
+	for i, tc := range c.InfluxOutputs {
+		outputs = append(outputs, configuredOutput{fmt.Sprintf("influx_outputs[%d]", i), tc.TargetName()})
+	}
+	if c.NATS != nil {
+		outputs = append(outputs, configuredOutput{"nats", c.NATS.OutputName()})
+	}
+	if c.Postgres != nil {
+		outputs = append(outputs, configuredOutput{"postgres", c.Postgres.OutputName()})
+	}
+	if c.QuestDB != nil {
+		outputs = append(outputs, configuredOutput{"questdb", c.QuestDB.OutputName()})
+	}
+	if c.Statsd != nil {
+		outputs = append(outputs, configuredOutput{"statsd", c.Statsd.OutputName()})
+	}
+	if c.OTLP != nil {
+		outputs = append(outputs, configuredOutput{"otlp", c.OTLP.OutputName()})
+	}
+	if c.AWS != nil && c.AWS.Timestream != nil {
+		outputs = append(outputs, configuredOutput{"aws.timestream", c.AWS.Timestream.OutputName()})
+	}
+	if c.AWS != nil && c.AWS.CloudWatch != nil {
+		outputs = append(outputs, configuredOutput{"aws.cloudwatch", c.AWS.CloudWatch.OutputName()})
+	}
+	if c.Exec != nil {
+		outputs = append(outputs, configuredOutput{"exec", c.Exec.OutputName()})
+	}
+	return outputs
+}
+
+// loadConfig reads the config file at the given path, exiting with an error if it can't.
+func loadConfig(path string) Config {
+	config := Config{}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		fatalf("Unable to read config file '%s': %s", path, err)
+	}
+	if err := json.Unmarshal(b, &config); err != nil {
+		fatalf("Unable to parse config file '%s': %s", path, err)
+	}
+	return config
+}
+
+// validate checks the config for use with the given options, exiting with an error if
+// it's invalid.
+func (c *Config) validate(o options) {
+	if c.HeartbeatFailURL != "" && c.HeartbeatURL == "" {
+		fatal("heartbeat_url must be set in the config file if heartbeat_fail_url is set.")
+	}
+	if err := c.validateProvider(c.providerName()); err != nil {
+		fatalf("Invalid provider configuration: %s", err)
+	}
+	seenProviders := map[string]bool{c.providerName(): true}
+	for _, name := range c.FallbackProviders {
+		if seenProviders[name] {
+			fatalf("fallback_providers may not contain the provider '%s' more than once, or the primary provider.", name)
+		}
+		seenProviders[name] = true
+		if err := c.validateProvider(name); err != nil {
+			fatalf("Invalid fallback_providers configuration: %s", err)
+		}
+	}
+	for _, name := range c.ComparisonProviders {
+		if seenProviders[name] {
+			fatalf("comparison_providers may not contain the provider '%s' more than once, or the primary or a fallback provider.", name)
+		}
+		seenProviders[name] = true
+		if err := c.validateProvider(name); err != nil {
+			fatalf("Invalid comparison_providers configuration: %s", err)
+		}
+	}
+
+	if c.APIKey == "" {
+		switch {
+		case c.FreezeRisk != nil || c.WriteEcobeeForecast || (c.Email != nil && c.Email.Mode == EmailModeDigest):
+			fatal("api_key must be set in the config file to use freeze_risk, write_ecobee_forecast, or the digest e-mail, which use OpenWeatherMap's forecast.")
+		case o.cmd == cmdBackfill:
+			fatal("api_key must be set in the config file to use the backfill subcommand.")
+		case c.OWMPaid != nil:
+			fatal("api_key must be set in the config file if owm_paid_apis is set.")
+		case c.ClimateForecast != nil:
+			fatal("api_key must be set in the config file if climate_forecast is set.")
+		}
+	}
+	if c.WeatherMeasurementName == "" {
+		fatal("wx_measurement_name must be set in the config file.")
+	}
+	if c.WriteEcobeeForecast && !c.WriteEcobeeWeatherMeasurement {
+		fatal("write_ecobee_weather_measurement must be set in the config file if write_ecobee_forecast is set.")
+	}
+	if c.WriteEcobeeWeatherMeasurement && c.EcobeeThermostatName == "" {
+		fatal("ecobee_thermostat_name must be set in the config file if write_ecobee_wx_measurement is set.")
+	}
+	for k := range c.EcobeeExtraTags {
+		if k == "" || k == thermostatNameTag || k == sourceTag {
+			fatalf("ecobee_extra_tags may not contain the tag '%s'.", k)
+		}
+	}
+	switch c.InfluxDuplicatePolicy {
+	case "", DuplicatePolicyOverwrite, DuplicatePolicyRunID, DuplicatePolicySkip:
+	default:
+		fatalf("influx_duplicate_policy must be one of '%s', '%s', or '%s'.", DuplicatePolicyOverwrite, DuplicatePolicyRunID, DuplicatePolicySkip)
+	}
+	if o.sendDigestEmail && (c.Email == nil || c.Email.Mode != EmailModeDigest) {
+		fatal("-sendDigest requires email.mode to be 'digest' in the config file.")
+	}
+	if c.MaxDataAge != "" {
+		if d, err := time.ParseDuration(c.MaxDataAge); err != nil || d <= 0 {
+			fatalf("max_data_age must be a positive duration (e.g. '90m'); got '%s'.", c.MaxDataAge)
+		}
+	}
+	if c.StaleDataAction != "" && c.StaleDataAction != StaleDataActionSkip && c.StaleDataAction != StaleDataActionTag {
+		fatalf("stale_data_action must be '%s' or '%s'.", StaleDataActionSkip, StaleDataActionTag)
+	}
+	for _, m := range c.WallClockTimestamps {
+		switch m {
+		case schemaWeather, schemaPollution, schemaEcobee:
+		default:
+			fatalf("Unsupported wall_clock_timestamps measurement '%s' (expected '%s', '%s', or '%s').", m, schemaWeather, schemaPollution, schemaEcobee)
+		}
+	}
+
+	// features that keep state between runs
+	stateful := []struct {
+		key string
+		set bool
+	}{
+		{"skip_unchanged_observations", c.SkipUnchangedObservations},
+		{"degree_days", c.DegreeDays != nil},
+		{"growing_degree_days", c.GrowingDegreeDays != nil},
+		{"notifications", c.Notifications != nil},
+		{"smoothing", len(c.Smoothing) > 0},
+		{"field_type_guard", c.FieldTypeGuard},
+	}
+	for _, f := range stateful {
+		if f.set && c.StateDir == "" {
+			fatalf("state_dir must be set in the config file if %s is set.", f.key)
+		}
+	}
+
+	sections := []struct {
+		key      string
+		set      bool
+		validate func() error
+	}{
+		{"email", c.Email != nil, func() error { return c.Email.Validate() }},
+		{"growing_degree_days", c.GrowingDegreeDays != nil, func() error { return c.GrowingDegreeDays.Validate() }},
+		{"freeze_risk", c.FreezeRisk != nil, func() error { return c.FreezeRisk.Validate() }},
+		{"energy_prices", c.EnergyPrices != nil, func() error { return c.EnergyPrices.Validate() }},
+		{"purpleair", c.PurpleAir != nil, func() error { return c.PurpleAir.Validate() }},
+		{"airnow", c.AirNow != nil, func() error { return c.AirNow.Validate() }},
+		{"openaq", c.OpenAQ != nil, func() error { return c.OpenAQ.Validate() }},
+		{"pollen", c.Pollen != nil, func() error { return c.Pollen.Validate(c.TomorrowIO) }},
+		{"metar", c.METAR != nil, func() error { return c.METAR.Validate() }},
+		{"marine", c.Marine != nil, func() error { return c.Marine.Validate() }},
+		{"owm_paid_apis", c.OWMPaid != nil, func() error { return c.OWMPaid.Validate() }},
+		{"cwop", c.CWOP != nil, func() error {
+			if err := c.CWOP.Validate(); err != nil {
+				return err
+			}
+			return c.validateWeatherNetworkUpload(c.CWOP.LocalSensorData)
+		}},
+		{"dynamic_location", c.DynamicLocation != nil, func() error { return c.DynamicLocation.Validate() }},
+		{"windy", c.Windy != nil, func() error {
+			if err := c.Windy.Validate(); err != nil {
+				return err
+			}
+			return c.validateWeatherNetworkUpload(c.Windy.LocalSensorData)
+		}},
+		{"notifications", c.Notifications != nil, func() error { return c.Notifications.Validate() }},
+		{"smoothing", len(c.Smoothing) > 0, func() error { return c.Smoothing.Validate() }},
+		{"nats", c.NATS != nil, func() error { return c.NATS.Validate() }},
+		{"postgres", c.Postgres != nil, func() error { return c.Postgres.Validate() }},
+		{"questdb", c.QuestDB != nil, func() error { return c.QuestDB.Validate() }},
+		{"statsd", c.Statsd != nil, func() error { return c.Statsd.Validate() }},
+		{"otlp", c.OTLP != nil, func() error { return c.OTLP.Validate() }},
+		{"aws", c.AWS != nil, func() error { return c.AWS.Validate() }},
+		{"exec", c.Exec != nil, func() error { return c.Exec.Validate() }},
+		{"calibration", true, func() error { return c.Calibration.Validate() }},
+		{"leader_lock", c.LeaderLock != nil, func() error { return c.LeaderLock.Validate() }},
+	}
+	for _, s := range sections {
+		if !s.set {
+			continue
+		}
+		if err := s.validate(); err != nil {
+			fatalf("Invalid %s configuration: %s", s.key, err)
+		}
+	}
+
+	for i, tc := range c.InfluxOutputs {
+		if tc.InfluxServer == "" || tc.InfluxBucket == "" {
+			fatalf("influx_outputs[%d]: influx_server and influx_bucket must be set.", i)
+		}
+	}
+	outputNames := make(map[string]string)
+	for _, out := range c.outputNames() {
+		if key, ok := outputNames[out.name]; ok && len(c.OutputRoutes) > 0 {
+			fatalf("%s: output name '%s' is already used by %s; set a unique name for each output to use output_routes.", out.key, out.name, key)
+		}
+		outputNames[out.name] = out.key
+	}
+	for m, names := range c.OutputRoutes {
+		for _, name := range names {
+			if _, ok := outputNames[name]; !ok {
+				fatalf("output_routes: measurement '%s' is routed to unknown output '%s'.", m, name)
+			}
+		}
+	}
+	if c.OutputWriteTimeout != "" {
+		if d, err := time.ParseDuration(c.OutputWriteTimeout); err != nil || d <= 0 {
+			fatalf("output_write_timeout must be a positive duration (e.g. '15s'); got '%s'.", c.OutputWriteTimeout)
+		}
+	}
+	if c.OutputFailureBudget < 0 {
+		fatal("output_failure_budget must be positive.")
+	}
+
+	if len(c.AQIStandards) == 0 {
+		c.AQIStandards = owmconnector.DefaultAQIStandards
+	}
+	for _, std := range c.AQIStandards {
+		switch std {
+		case owmconnector.AQIStandardUS, owmconnector.AQIStandardEU, owmconnector.AQIStandardUK, owmconnector.AQIStandardCA:
+		default:
+			fatalf("aqi_standards: unknown standard '%s' (must be one of us, eu, uk, ca).", std)
+		}
+	}
+	for k, t := range c.FieldTypes {
+		switch t {
+		case FieldTypeFloat, FieldTypeInt, FieldTypeString, FieldTypeBool:
+		default:
+			fatalf("field_types: field '%s' has invalid type '%s' (must be float, int, string, or bool).", k, t)
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const (
+	defaultCWOPServer   = "cwop.aprs.net:14580"
+	defaultCWOPPasscode = "-1"
+	cwopTimeout         = 10 * time.Second
+)
+
+var aprsCallsignRegexp = regexp.MustCompile(`^[A-Z0-9]{3,7}(-[0-9A-Z]{1,2})?$`)
+
+// CWOPConfig describes the configuration for uploading observations to the Citizen Weather
+// Observer Program via APRS-IS.
+type CWOPConfig struct {
+	StationID string `json:"station_id"`
+	Passcode  string `json:"passcode,omitempty"`
+	Server    string `json:"server,omitempty"`
+	// LocalSensorData confirms the uploaded observations reflect the station's own sensors.
+	LocalSensorData bool `json:"local_sensor_data,omitempty"`
+}
+
+// Validate checks the CWOP configuration.
+func (c CWOPConfig) Validate() error {
+	if !aprsCallsignRegexp.MatchString(c.StationID) {
+		return errors.New("station_id must be a CWOP station ID (e.g. 'CW1234') or an amateur radio callsign, optionally with an SSID, in upper case")
+	}
+	if _, _, err := net.SplitHostPort(c.server()); err != nil {
+		return fmt.Errorf("server must be in host:port form: %w", err)
+	}
+	return nil
+}
+
+func (c CWOPConfig) server() string {
+	if c.Server == "" {
+		return defaultCWOPServer
+	}
+	return c.Server
+}
+
+func (c CWOPConfig) passcode() string {
+	if c.Passcode == "" {
+		return defaultCWOPPasscode
+	}
+	return c.Passcode
+}
+
+// aprsWeatherPacket formats the given observation as an APRS position report with
+// weather data. Values that aren't present are sent as unknown.
+// See http://www.aprs.org/doc/APRS101.PDF, chapter 12
+func aprsWeatherPacket(stationID string, lat, lon float64, o weatherNetworkObservation, ts time.Time) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s>APRS,TCPIP*:@%sz%s/%s_", stationID, ts.UTC().Format("021504"), aprsLatitude(lat), aprsLongitude(lon))
+	b.WriteString(aprsValue(o.WindBearing, 3, 1))
+	b.WriteByte('/')
+	b.WriteString(aprsValue(o.WindSpeedMph, 3, 1))
+	b.WriteString("g...")
+	b.WriteString("t" + aprsValue(o.TempF, 3, 1))
+	if o.Humidity != nil {
+		// nb. 100% humidity is sent as 00; calibrated values may fall outside 1-100%
+		h := min(max(int(math.Round(*o.Humidity)), 1), 100)
+		fmt.Fprintf(&b, "h%02d", h%100)
+	}
+	b.WriteString("b" + aprsValue(o.PressureMb, 5, 10))
+	b.WriteString("owm-influx-" + version)
+	return b.String()
+}
+
+// aprsLatitude formats a latitude in APRS's DDMM.mmN form.
+func aprsLatitude(lat float64) string {
+	hemi := "N"
+	if lat < 0 {
+		hemi, lat = "S", -lat
+	}
+	hm := int(math.Round(lat * 6000)) // hundredths of minutes
+	return fmt.Sprintf("%02d%02d.%02d%s", hm/6000, hm%6000/100, hm%100, hemi)
+}
+
+// aprsLongitude formats a longitude in APRS's DDDMM.mmW form.
+func aprsLongitude(lon float64) string {
+	hemi := "E"
+	if lon < 0 {
+		hemi, lon = "W", -lon
+	}
+	hm := int(math.Round(lon * 6000)) // hundredths of minutes
+	return fmt.Sprintf("%03d%02d.%02d%s", hm/6000, hm%6000/100, hm%100, hemi)
+}
+
+// aprsValue formats a value, multiplied by scale, as a fixed-width integer field, or as
+// dots if it's missing.
+func aprsValue(v *float64, width int, scale float64) string {
+	if v == nil {
+		return strings.Repeat(".", width)
+	}
+	i := int(math.Round(*v * scale))
+	if i < 0 {
+		// nb. negative values use the field's first character for the sign
+		return fmt.Sprintf("-%0*d", width-1, -i)
+	}
+	return fmt.Sprintf("%0*d", width, i)
+}
+
+// SubmitCWOP sends the given weather fields to CWOP as an APRS weather packet.
+func SubmitCWOP(c CWOPConfig, lat, lon float64, fields map[string]interface{}, ts time.Time) error {
+	conn, err := net.DialTimeout("tcp", c.server(), cwopTimeout)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if err := conn.SetDeadline(time.Now().Add(cwopTimeout)); err != nil {
+		return err
+	}
+
+	r := bufio.NewReader(conn)
+	// the server sends a banner on connect, and acknowledges the login
+	if _, err := r.ReadString('\n'); err != nil {
+		return fmt.Errorf("failed to read APRS-IS banner: %w", err)
+	}
+	if _, err := fmt.Fprintf(conn, "user %s pass %s vers owm-influx %s\r\n", c.StationID, c.passcode(), version); err != nil {
+		return err
+	}
+	if _, err := r.ReadString('\n'); err != nil {
+		return fmt.Errorf("failed to read APRS-IS login response: %w", err)
+	}
+	_, err = fmt.Fprintf(conn, "%s\r\n", aprsWeatherPacket(c.StationID, lat, lon, weatherNetworkObservationFromFields(fields), ts))
+	return err
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAPRSWeatherPacket(t *testing.T) {
+	ts := time.Date(2024, 1, 2, 3, 4, 59, 0, time.UTC)
+	tests := []struct {
+		name     string
+		lat, lon float64
+		o        weatherNetworkObservation
+		want     string
+	}{
+		{
+			name: "all values",
+			lat:  42.28, lon: -83.743,
+			o: weatherNetworkObservation{
+				TempF: ptr(72.4), Humidity: ptr(55.0), PressureMb: ptr(1013.2),
+				WindSpeedMph: ptr(5.0), WindBearing: ptr(220.0),
+			},
+			want: "CW1234>APRS,TCPIP*:@020304z4216.80N/08344.58W_220/005g...t072h55b10132owm-influx-<dev>",
+		},
+		{
+			name: "negative temperature",
+			lat:  64.8378, lon: -147.7164,
+			o:    weatherNetworkObservation{TempF: ptr(-5.4), Humidity: ptr(78.0), PressureMb: ptr(1030.06)},
+			want: "CW1234>APRS,TCPIP*:@020304z6450.27N/14742.98W_.../...g...t-05h78b10301owm-influx-<dev>",
+		},
+		{
+			name: "100% humidity",
+			lat:  -33.8688, lon: 151.2093,
+			o:    weatherNetworkObservation{TempF: ptr(60.0), Humidity: ptr(99.6), PressureMb: ptr(998.44)},
+			want: "CW1234>APRS,TCPIP*:@020304z3352.13S/15112.56E_.../...g...t060h00b09984owm-influx-<dev>",
+		},
+		{
+			name: "humidity above 100%",
+			lat:  42.28, lon: -83.743,
+			o:    weatherNetworkObservation{Humidity: ptr(101.2)},
+			want: "CW1234>APRS,TCPIP*:@020304z4216.80N/08344.58W_.../...g...t...h00b.....owm-influx-<dev>",
+		},
+		{
+			name: "minute boundary",
+			lat:  42.999999, lon: -83.9999999,
+			o:    weatherNetworkObservation{},
+			want: "CW1234>APRS,TCPIP*:@020304z4300.00N/08400.00W_.../...g...t...b.....owm-influx-<dev>",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := aprsWeatherPacket("CW1234", tt.lat, tt.lon, tt.o, ts); got != tt.want {
+				t.Errorf("aprsWeatherPacket() = %q; want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAPRSPosition(t *testing.T) {
+	tests := []struct {
+		lat, lon         float64
+		wantLat, wantLon string
+	}{
+		{0, 0, "0000.00N", "00000.00E"},
+		{42.28, -83.743, "4216.80N", "08344.58W"},
+		// 59.9999' rounds up to the next degree, not to 60.00'
+		{41.9999999, -83.9999999, "4200.00N", "08400.00W"},
+		{-41.9999999, 83.9999999, "4200.00S", "08400.00E"},
+		// 12.345' rounds to the nearest hundredth of a minute
+		{10.20575, -100.20575, "1012.35N", "10012.35W"},
+		{-90, 180, "9000.00S", "18000.00E"},
+	}
+	for _, tt := range tests {
+		if got := aprsLatitude(tt.lat); got != tt.wantLat {
+			t.Errorf("aprsLatitude(%v) = %q; want %q", tt.lat, got, tt.wantLat)
+		}
+		if got := aprsLongitude(tt.lon); got != tt.wantLon {
+			t.Errorf("aprsLongitude(%v) = %q; want %q", tt.lon, got, tt.wantLon)
+		}
+	}
+}
+
+func TestAPRSValue(t *testing.T) {
+	tests := []struct {
+		v     *float64
+		width int
+		scale float64
+		want  string
+	}{
+		{nil, 3, 1, "..."},
+		{nil, 5, 10, "....."},
+		{ptr(7.0), 3, 1, "007"},
+		{ptr(105.5), 3, 1, "106"},
+		{ptr(-5.0), 3, 1, "-05"},
+		{ptr(-12.6), 3, 1, "-13"},
+		{ptr(-0.4), 3, 1, "000"},
+		// pressure is in tenths of millibars
+		{ptr(1013.25), 5, 10, "10133"},
+		{ptr(987.6), 5, 10, "09876"},
+	}
+	for _, tt := range tests {
+		if got := aprsValue(tt.v, tt.width, tt.scale); got != tt.want {
+			t.Errorf("aprsValue(%v, %d, %v) = %q; want %q", fmtPtr(tt.v), tt.width, tt.scale, got, tt.want)
+		}
+	}
+}
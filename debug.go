@@ -0,0 +1,85 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// debugTransport is an http.RoundTripper that logs the raw body of every response, and
+// optionally saves each response body to a file in dir.
+type debugTransport struct {
+	next http.RoundTripper
+	dir  string
+	seq  atomic.Int32
+}
+
+func (t *debugTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		return resp, err
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	u := redactedURL(req)
+	slog.Debug("Raw API response", "url", u.String(), "status", resp.Status, "body", string(body))
+	if t.dir != "" {
+		name := fmt.Sprintf("%s-%02d-%s.json", time.Now().UTC().Format("20060102T150405Z"), t.seq.Add(1), strings.Trim(strings.ReplaceAll(u.Path, "/", "_"), "_"))
+		if err := os.WriteFile(filepath.Join(t.dir, name), body, 0o644); err != nil {
+			slog.Error("Failed to save raw API response", "url", u.String(), "error", err)
+		}
+	}
+	return resp, nil
+}
+
+// redactedURL returns the request's URL with any API key or credentials removed.
+func redactedURL(req *http.Request) url.URL {
+	u := *req.URL
+	// nb. e.g. an OwnTracks Recorder dynamic_location URL usually has basic auth credentials
+	u.User = nil
+	// nb. Windy takes the API key as the last path component
+	if strings.HasPrefix(u.Path, windyUpdatePath) {
+		u.Path, u.RawPath = windyUpdatePath+"REDACTED", ""
+	}
+	q := u.Query()
+	redacted := false
+	for _, k := range []string{"appid", "apikey", "key", "API_KEY", "api_key", "securityToken", "token", "access_token"} {
+		if q.Has(k) {
+			q.Set(k, "REDACTED")
+			redacted = true
+		}
+	}
+	if redacted {
+		u.RawQuery = q.Encode()
+	}
+	return u
+}
+
+// enableDebugResponses makes the OpenWeatherMap HTTP client log every raw response body,
+// and save each one to a file in dir if dir isn't empty.
+func enableDebugResponses(dir string) error {
+	if dir != "" {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return err
+		}
+	}
+	next := owmHTTPClient.Transport
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	owmHTTPClient.Transport = &debugTransport{next: next, dir: dir}
+	return nil
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+const (
+	defaultDegreeDaysBaseTempF       = 65.0
+	defaultDegreeDaysMeasurementName = "degree_days"
+	dailyTempSummaryDateFormat       = "2006-01-02"
+)
+
+// DegreeDaysConfig describes the configuration for heating/cooling degree-day accumulation.
+type DegreeDaysConfig struct {
+	BaseTempF       *float64 `json:"base_temp_f,omitempty"`
+	MeasurementName string   `json:"measurement_name,omitempty"`
+}
+
+// BaseTemp returns the configured base temperature (degF), or the conventional 65 degF default.
+func (c DegreeDaysConfig) BaseTemp() float64 {
+	if c.BaseTempF == nil {
+		return defaultDegreeDaysBaseTempF
+	}
+	return *c.BaseTempF
+}
+
+// Measurement returns the configured measurement name, or the default "degree_days".
+func (c DegreeDaysConfig) Measurement() string {
+	if c.MeasurementName == "" {
+		return defaultDegreeDaysMeasurementName
+	}
+	return c.MeasurementName
+}
+
+// DailyTempSummary tracks the temperature extremes observed during a single local day.
+type DailyTempSummary struct {
+	Date string  `json:"date"`
+	MinF float64 `json:"min_f"`
+	MaxF float64 `json:"max_f"`
+}
+
+// MeanF returns the day's mean temperature, per the conventional (min+max)/2 method.
+func (d DailyTempSummary) MeanF() float64 {
+	return (d.MinF + d.MaxF) / 2.0
+}
+
+// Start returns the start of the summarized day in the given location.
+func (d DailyTempSummary) Start(loc *time.Location) time.Time {
+	t, err := time.ParseInLocation(dailyTempSummaryDateFormat, d.Date, loc)
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
+
+// RecordDailyTemp updates today's temperature summary with the given observation.
+// If the observation falls on a later day (in the configured timezone) than the summary
+// in progress, the previous day's completed summary is returned and a new summary is started.
+func (s *State) RecordDailyTemp(t time.Time, tempF float64) *DailyTempSummary {
+	date := inLocal(t).Format(dailyTempSummaryDateFormat)
+	if s.DailyTemps == nil {
+		s.DailyTemps = &DailyTempSummary{Date: date, MinF: tempF, MaxF: tempF}
+		return nil
+	}
+
+	if date < s.DailyTemps.Date {
+		// observation from a day we've already completed; ignore it
+		return nil
+	}
+	if date > s.DailyTemps.Date {
+		completed := *s.DailyTemps
+		s.DailyTemps = &DailyTempSummary{Date: date, MinF: tempF, MaxF: tempF}
+		return &completed
+	}
+
+	s.DailyTemps.MinF = math.Min(s.DailyTemps.MinF, tempF)
+	s.DailyTemps.MaxF = math.Max(s.DailyTemps.MaxF, tempF)
+	return nil
+}
+
+// HeatingDegreeDays returns the heating degree days for a day with the given mean temperature.
+func HeatingDegreeDays(meanF, baseF float64) float64 {
+	return math.Max(0, baseF-meanF)
+}
+
+// CoolingDegreeDays returns the cooling degree days for a day with the given mean temperature.
+func CoolingDegreeDays(meanF, baseF float64) float64 {
+	return math.Max(0, meanF-baseF)
+}
+
+const (
+	defaultGDDBaseTempF       = 50.0
+	defaultGDDUpperTempF      = 86.0
+	defaultGDDSeasonStart     = "01-01"
+	defaultGDDMeasurementName = "growing_degree_days"
+	gddSeasonStartFormat      = "01-02"
+)
+
+// GrowingDegreeDaysConfig describes the configuration for growing degree-day accumulation.
+type GrowingDegreeDaysConfig struct {
+	BaseTempF       *float64 `json:"base_temp_f,omitempty"`
+	UpperTempF      *float64 `json:"upper_temp_f,omitempty"`
+	SeasonStart     string   `json:"season_start,omitempty"`
+	MeasurementName string   `json:"measurement_name,omitempty"`
+}
+
+// Validate checks the growing degree-day configuration.
+func (c GrowingDegreeDaysConfig) Validate() error {
+	if c.UpperTemp() <= c.BaseTemp() {
+		return fmt.Errorf("upper_temp_f (%.1f) must be greater than base_temp_f (%.1f)", c.UpperTemp(), c.BaseTemp())
+	}
+	if _, err := time.Parse(gddSeasonStartFormat, c.seasonStart()); err != nil {
+		return fmt.Errorf("season_start must be in MM-DD form: %w", err)
+	}
+	return nil
+}
+
+// BaseTemp returns the configured base temperature (degF), or the common 50 degF default.
+func (c GrowingDegreeDaysConfig) BaseTemp() float64 {
+	if c.BaseTempF == nil {
+		return defaultGDDBaseTempF
+	}
+	return *c.BaseTempF
+}
+
+// UpperTemp returns the configured upper threshold temperature (degF), or the common 86 degF default.
+func (c GrowingDegreeDaysConfig) UpperTemp() float64 {
+	if c.UpperTempF == nil {
+		return defaultGDDUpperTempF
+	}
+	return *c.UpperTempF
+}
+
+// Measurement returns the configured measurement name, or the default "growing_degree_days".
+func (c GrowingDegreeDaysConfig) Measurement() string {
+	if c.MeasurementName == "" {
+		return defaultGDDMeasurementName
+	}
+	return c.MeasurementName
+}
+
+func (c GrowingDegreeDaysConfig) seasonStart() string {
+	if c.SeasonStart == "" {
+		return defaultGDDSeasonStart
+	}
+	return c.SeasonStart
+}
+
+// SeasonStartFor returns the date (YYYY-MM-DD) of the growing season start on or before the given day.
+func (c GrowingDegreeDaysConfig) SeasonStartFor(day time.Time) string {
+	md, _ := time.Parse(gddSeasonStartFormat, c.seasonStart())
+	start := time.Date(day.Year(), md.Month(), md.Day(), 0, 0, 0, 0, day.Location())
+	if start.After(day) {
+		start = start.AddDate(-1, 0, 0)
+	}
+	return start.Format(dailyTempSummaryDateFormat)
+}
+
+// GrowingDegreeDays calculates growing degree days for a day using the modified average
+// method: the day's high is capped at the upper threshold and its low is raised to the base.
+func GrowingDegreeDays(minF, maxF, baseF, upperF float64) float64 {
+	hi := math.Max(math.Min(maxF, upperF), baseF)
+	lo := math.Min(math.Max(minF, baseF), upperF)
+	return math.Max(0, (hi+lo)/2.0-baseF)
+}
+
+// GrowingSeasonTotal is the season-to-date growing degree-day total.
+type GrowingSeasonTotal struct {
+	SeasonStart string  `json:"season_start"`
+	TotalGDD    float64 `json:"total_gdd"`
+}
+
+// AddGrowingDegreeDays adds a completed day's GDD to the season-to-date total, starting a
+// new total if the day falls in a new season. It returns the updated season total.
+func (s *State) AddGrowingDegreeDays(seasonStart string, gdd float64) float64 {
+	if s.GrowingSeason == nil || s.GrowingSeason.SeasonStart != seasonStart {
+		s.GrowingSeason = &GrowingSeasonTotal{SeasonStart: seasonStart}
+	}
+	s.GrowingSeason.TotalGDD += gdd
+	return s.GrowingSeason.TotalGDD
+}
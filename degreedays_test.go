@@ -0,0 +1,128 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDegreeDays(t *testing.T) {
+	tests := []struct {
+		meanF, baseF     float64
+		wantHDD, wantCDD float64
+	}{
+		{40, 65, 25, 0},
+		{65, 65, 0, 0},
+		{80.5, 65, 0, 15.5},
+		{55, 60, 5, 0},
+	}
+	for _, tt := range tests {
+		if got := HeatingDegreeDays(tt.meanF, tt.baseF); got != tt.wantHDD {
+			t.Errorf("HeatingDegreeDays(%v, %v) = %v; want %v", tt.meanF, tt.baseF, got, tt.wantHDD)
+		}
+		if got := CoolingDegreeDays(tt.meanF, tt.baseF); got != tt.wantCDD {
+			t.Errorf("CoolingDegreeDays(%v, %v) = %v; want %v", tt.meanF, tt.baseF, got, tt.wantCDD)
+		}
+	}
+}
+
+func TestRecordDailyTemp(t *testing.T) {
+	pinClock(t, "UTC")
+	day := time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC)
+	s := &State{}
+	readings := []struct {
+		t    time.Time
+		temp float64
+	}{
+		{day.Add(1 * time.Hour), 30},
+		{day.Add(6 * time.Hour), 22},
+		{day.Add(15 * time.Hour), 41},
+		{day.Add(23 * time.Hour), 35},
+	}
+	for _, r := range readings {
+		if d := s.RecordDailyTemp(r.t, r.temp); d != nil {
+			t.Fatalf("RecordDailyTemp(%s) completed a day early: %+v", r.t, d)
+		}
+	}
+	// a reading from a completed day is ignored
+	if d := s.RecordDailyTemp(day.Add(-time.Hour), 100); d != nil {
+		t.Fatalf("RecordDailyTemp() for the previous day completed a day: %+v", d)
+	}
+
+	d := s.RecordDailyTemp(day.AddDate(0, 0, 1).Add(time.Hour), 50)
+	if d == nil {
+		t.Fatal("RecordDailyTemp() on the next day didn't complete the day")
+	}
+	want := DailyTempSummary{Date: "2024-01-15", MinF: 22, MaxF: 41}
+	if *d != want {
+		t.Errorf("completed day = %+v; want %+v", *d, want)
+	}
+	if d.MeanF() != 31.5 {
+		t.Errorf("MeanF() = %v; want 31.5", d.MeanF())
+	}
+	if s.DailyTemps.Date != "2024-01-16" || s.DailyTemps.MinF != 50 || s.DailyTemps.MaxF != 50 {
+		t.Errorf("new day in progress = %+v; want 2024-01-16 starting at 50", *s.DailyTemps)
+	}
+}
+
+func TestGrowingDegreeDays(t *testing.T) {
+	tests := []struct {
+		minF, maxF float64
+		want       float64
+	}{
+		{60, 80, 20},
+		// the high is capped at the upper threshold
+		{70, 95, 28},
+		// the low is raised to the base
+		{40, 70, 10},
+		{30, 45, 0},
+		{90, 100, 36},
+	}
+	for _, tt := range tests {
+		if got := GrowingDegreeDays(tt.minF, tt.maxF, 50, 86); got != tt.want {
+			t.Errorf("GrowingDegreeDays(%v, %v, 50, 86) = %v; want %v", tt.minF, tt.maxF, got, tt.want)
+		}
+	}
+}
+
+func TestGrowingSeasonStart(t *testing.T) {
+	c := GrowingDegreeDaysConfig{SeasonStart: "04-01"}
+	tests := []struct {
+		day  time.Time
+		want string
+	}{
+		{time.Date(2024, 7, 4, 0, 0, 0, 0, time.UTC), "2024-04-01"},
+		{time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC), "2024-04-01"},
+		{time.Date(2024, 3, 31, 0, 0, 0, 0, time.UTC), "2023-04-01"},
+	}
+	for _, tt := range tests {
+		if got := c.SeasonStartFor(tt.day); got != tt.want {
+			t.Errorf("SeasonStartFor(%s) = %s; want %s", tt.day.Format(dailyTempSummaryDateFormat), got, tt.want)
+		}
+	}
+
+	s := &State{}
+	s.AddGrowingDegreeDays("2023-04-01", 10)
+	if total := s.AddGrowingDegreeDays("2023-04-01", 5.5); total != 15.5 {
+		t.Errorf("season total = %v; want 15.5", total)
+	}
+	if total := s.AddGrowingDegreeDays("2024-04-01", 2); total != 2 {
+		t.Errorf("new season total = %v; want 2", total)
+	}
+}
+
+func TestGrowingDegreeDaysConfigValidate(t *testing.T) {
+	tests := []struct {
+		c       GrowingDegreeDaysConfig
+		wantErr bool
+	}{
+		{GrowingDegreeDaysConfig{}, false},
+		{GrowingDegreeDaysConfig{SeasonStart: "03-15"}, false},
+		{GrowingDegreeDaysConfig{SeasonStart: "15-03"}, true},
+		{GrowingDegreeDaysConfig{BaseTempF: ptr(90.0)}, true},
+	}
+	for _, tt := range tests {
+		if err := tt.c.Validate(); (err != nil) != tt.wantErr {
+			t.Errorf("%+v.Validate() = %v; want error: %t", tt.c, err, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,233 @@
+package main
+
+import (
+	"math"
+	"time"
+
+	owm "github.com/briandowns/openweathermap"
+	"github.com/cdzombak/libwx"
+
+	"github.com/cdzombak/openweather-influxdb-connector/pkg/owmconnector"
+)
+
+// Ecobee sky cover codes, as reported in the ecobee API's weather forecast "sky" field.
+// See https://www.ecobee.com/home/developer/api/documentation/v1/objects/WeatherForecast.shtml
+const (
+	ecobeeSkySunny        = 1
+	ecobeeSkyClear        = 2
+	ecobeeSkyMostlySunny  = 3
+	ecobeeSkyMostlyClear  = 4
+	ecobeeSkyHaze         = 6
+	ecobeeSkyPartlyCloudy = 10
+	ecobeeSkyMostlyCloudy = 16
+	ecobeeSkyOvercast     = 18
+	ecobeeSkyLightFog     = 20
+	ecobeeSkyFog          = 21
+	ecobeeSkySandstorm    = 24
+	ecobeeSkyDuststorm    = 25
+	ecobeeSkySmoke        = 33
+)
+
+// ecobeeWeatherSymbolNone is the ecobee weather symbol code for "no symbol".
+const ecobeeWeatherSymbolNone = -2
+
+// ecobeeCondition is an ecobee weather symbol code and condition string.
+type ecobeeCondition struct {
+	symbol    int
+	condition string
+}
+
+// owmEcobeeConditions maps OpenWeatherMap condition codes to ecobee weather symbols and
+// condition strings. Codes 800-804 (clear and cloudy) are handled by ecobeeConditionFor.
+// See https://openweathermap.org/weather-conditions#Weather-Condition-Codes-2
+var owmEcobeeConditions = []struct {
+	min, max int
+	ecobeeCondition
+}{
+	{200, 299, ecobeeCondition{15, "Thunderstorms"}},
+	{300, 399, ecobeeCondition{5, "Drizzle"}},
+	{511, 511, ecobeeCondition{7, "Freezing Rain"}},
+	{520, 531, ecobeeCondition{8, "Showers"}},
+	{500, 599, ecobeeCondition{6, "Rain"}},
+	{611, 613, ecobeeCondition{14, "Ice Pellets"}},
+	{615, 616, ecobeeCondition{12, "Rain and Snow"}},
+	{620, 621, ecobeeCondition{11, "Flurries"}},
+	{602, 602, ecobeeCondition{13, "Heavy Snow"}},
+	{622, 622, ecobeeCondition{13, "Heavy Snow"}},
+	{600, 699, ecobeeCondition{10, "Snow"}},
+	{701, 701, ecobeeCondition{18, "Mist"}},
+	{711, 711, ecobeeCondition{20, "Smoke"}},
+	{721, 721, ecobeeCondition{19, "Haze"}},
+	{731, 731, ecobeeCondition{21, "Dust"}},
+	{741, 741, ecobeeCondition{18, "Fog"}},
+	{751, 751, ecobeeCondition{21, "Sand"}},
+	{761, 761, ecobeeCondition{21, "Dust"}},
+	{762, 762, ecobeeCondition{20, "Volcanic Ash"}},
+	{771, 771, ecobeeCondition{16, "Squalls"}},
+	{781, 781, ecobeeCondition{17, "Tornado"}},
+	{800, 800, ecobeeCondition{0, "Sunny"}},
+	{801, 801, ecobeeCondition{1, "Mostly Sunny"}},
+	{802, 802, ecobeeCondition{2, "Partly Cloudy"}},
+	{803, 803, ecobeeCondition{3, "Mostly Cloudy"}},
+	{804, 804, ecobeeCondition{4, "Overcast"}},
+}
+
+// ecobeeConditionFor returns the ecobee weather symbol and condition string for the given
+// OpenWeatherMap condition code. At night, clear-sky conditions are reported as "Clear"
+// and "Mostly Clear" rather than "Sunny" and "Mostly Sunny".
+func ecobeeConditionFor(owmID int, daytime bool) (symbol int, condition string) {
+	for _, c := range owmEcobeeConditions {
+		if owmID < c.min || owmID > c.max {
+			continue
+		}
+		if !daytime {
+			switch owmID {
+			case 800:
+				return c.symbol, "Clear"
+			case 801:
+				return c.symbol, "Mostly Clear"
+			}
+		}
+		return c.symbol, c.condition
+	}
+	return ecobeeWeatherSymbolNone, ""
+}
+
+// ecobeeSkyFor returns the ecobee sky cover code for the given OpenWeatherMap condition
+// code and cloud cover (%). Obscurations (fog, haze, smoke, dust) take precedence over
+// cloud cover.
+func ecobeeSkyFor(owmID, cloudCoverPercent int, daytime bool) int {
+	switch owmID {
+	case 701:
+		return ecobeeSkyLightFog
+	case 741:
+		return ecobeeSkyFog
+	case 711, 762:
+		return ecobeeSkySmoke
+	case 721:
+		return ecobeeSkyHaze
+	case 731, 761:
+		return ecobeeSkyDuststorm
+	case 751:
+		return ecobeeSkySandstorm
+	}
+	switch {
+	case cloudCoverPercent <= 10:
+		if daytime {
+			return ecobeeSkySunny
+		}
+		return ecobeeSkyClear
+	case cloudCoverPercent <= 30:
+		if daytime {
+			return ecobeeSkyMostlySunny
+		}
+		return ecobeeSkyMostlyClear
+	case cloudCoverPercent <= 60:
+		return ecobeeSkyPartlyCloudy
+	case cloudCoverPercent <= 90:
+		return ecobeeSkyMostlyCloudy
+	default:
+		return ecobeeSkyOvercast
+	}
+}
+
+// ecobeeFields returns the observation's fields, as written to the ecobee-compatible
+// weather measurement.
+func ecobeeFields(obs *owmconnector.Observation) map[string]interface{} {
+	windChillF, _ := obs.WindChill()
+	fields := map[string]interface{}{
+		"outdoor_temp":                    obs.Temp.Unwrap(),
+		"outdoor_humidity":                obs.Humidity.Unwrap(),
+		"barometric_pressure_mb":          obs.Pressure.Unwrap(),
+		"barometric_pressure_inHg":        obs.Pressure.InHg().Unwrap(),
+		"dew_point":                       obs.DewPoint.Unwrap(),
+		"wind_speed":                      obs.WindSpeed.Unwrap(),
+		"wind_bearing":                    obs.WindBearing,
+		"visibility_mi":                   obs.VisibilityMiles.Unwrap(),
+		"recommended_max_indoor_humidity": libwx.IndoorHumidityRecommendationF(obs.Temp).Unwrap(),
+		"wind_chill_f":                    windChillF.Unwrap(),
+	}
+	if obs.ConditionID != 0 {
+		solarElevation, _ := owmconnector.SolarPosition(obs.Time, obs.Latitude, obs.Longitude)
+		daytime := solarElevation > 0
+		symbol, condition := ecobeeConditionFor(obs.ConditionID, daytime)
+		fields["weather_symbol"] = symbol
+		fields["condition"] = condition
+		fields["sky"] = ecobeeSkyFor(obs.ConditionID, obs.CloudCoverPercent, daytime)
+	}
+	return fields
+}
+
+// ecobeeForecastDay is one day of the forecast, summarized from the 3-hourly forecast
+// entries falling on that day.
+type ecobeeForecastDay struct {
+	Start  time.Time
+	Fields map[string]interface{}
+}
+
+// ecobeeForecastDays summarizes the 3-hourly forecast into one ecobee-style forecast row
+// per day, for days starting at or after today (in loc). The condition and sky fields are
+// taken from the entry nearest local noon.
+func ecobeeForecastDays(forecast *owm.Forecast5WeatherData, now time.Time, loc *time.Location) []ecobeeForecastDay {
+	todayStart := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+	type accum struct {
+		start                   time.Time
+		high, low               float64
+		humiditySum, pressSum   float64
+		maxWind, maxWindBearing float64
+		count                   int
+		noonTime                time.Time
+		noon                    *owm.Forecast5WeatherList
+		noonOffset              time.Duration
+	}
+	var days []*accum
+	byDay := make(map[time.Time]*accum)
+	for i := range forecast.List {
+		item := &forecast.List[i]
+		t := time.Unix(int64(item.Dt), 0).In(loc)
+		dayStart := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc)
+		if dayStart.Before(todayStart) {
+			continue
+		}
+		d, ok := byDay[dayStart]
+		if !ok {
+			// nb. local noon isn't always 12 hours after midnight, on days with a DST transition
+			noonTime := time.Date(t.Year(), t.Month(), t.Day(), 12, 0, 0, 0, loc)
+			d = &accum{start: dayStart, noonTime: noonTime, high: item.Main.TempMax, low: item.Main.TempMin}
+			byDay[dayStart] = d
+			days = append(days, d)
+		}
+		d.high = math.Max(d.high, item.Main.TempMax)
+		d.low = math.Min(d.low, item.Main.TempMin)
+		d.humiditySum += float64(item.Main.Humidity)
+		d.pressSum += item.Main.Pressure
+		if item.Wind.Speed >= d.maxWind {
+			d.maxWind, d.maxWindBearing = item.Wind.Speed, item.Wind.Deg
+		}
+		d.count++
+		noonOffset := t.Sub(d.noonTime).Abs()
+		if d.noon == nil || noonOffset < d.noonOffset {
+			d.noon, d.noonOffset = item, noonOffset
+		}
+	}
+
+	result := make([]ecobeeForecastDay, 0, len(days))
+	for _, d := range days {
+		fields := map[string]interface{}{
+			"temp_high":              d.high,
+			"temp_low":               d.low,
+			"relative_humidity":      int(math.Round(d.humiditySum / float64(d.count))),
+			"barometric_pressure_mb": d.pressSum / float64(d.count),
+			"wind_speed":             d.maxWind,
+			"wind_bearing":           d.maxWindBearing,
+		}
+		if len(d.noon.Weather) > 0 {
+			symbol, condition := ecobeeConditionFor(d.noon.Weather[0].ID, true)
+			fields["weather_symbol"] = symbol
+			fields["condition"] = condition
+			fields["sky"] = ecobeeSkyFor(d.noon.Weather[0].ID, d.noon.Clouds.All, true)
+		}
+		result = append(result, ecobeeForecastDay{Start: d.start, Fields: fields})
+	}
+	return result
+}
@@ -0,0 +1,69 @@
+package main
+
+import "testing"
+
+func TestEcobeeConditionFor(t *testing.T) {
+	tests := []struct {
+		owmID         int
+		daytime       bool
+		wantSymbol    int
+		wantCondition string
+	}{
+		{211, true, 15, "Thunderstorms"},
+		{301, true, 5, "Drizzle"},
+		{500, true, 6, "Rain"},
+		{511, true, 7, "Freezing Rain"},
+		{521, true, 8, "Showers"},
+		{600, true, 10, "Snow"},
+		{602, true, 13, "Heavy Snow"},
+		{611, true, 14, "Ice Pellets"},
+		{616, true, 12, "Rain and Snow"},
+		{620, true, 11, "Flurries"},
+		{741, true, 18, "Fog"},
+		{781, true, 17, "Tornado"},
+		{800, true, 0, "Sunny"},
+		{800, false, 0, "Clear"},
+		{801, true, 1, "Mostly Sunny"},
+		{801, false, 1, "Mostly Clear"},
+		{802, false, 2, "Partly Cloudy"},
+		{804, true, 4, "Overcast"},
+		{0, true, ecobeeWeatherSymbolNone, ""},
+		{999, true, ecobeeWeatherSymbolNone, ""},
+	}
+	for _, tt := range tests {
+		symbol, condition := ecobeeConditionFor(tt.owmID, tt.daytime)
+		if symbol != tt.wantSymbol || condition != tt.wantCondition {
+			t.Errorf("ecobeeConditionFor(%d, %t) = %d, %q; want %d, %q", tt.owmID, tt.daytime, symbol, condition, tt.wantSymbol, tt.wantCondition)
+		}
+	}
+}
+
+func TestEcobeeSkyFor(t *testing.T) {
+	tests := []struct {
+		owmID             int
+		cloudCoverPercent int
+		daytime           bool
+		want              int
+	}{
+		{800, 0, true, ecobeeSkySunny},
+		{800, 10, false, ecobeeSkyClear},
+		{801, 20, true, ecobeeSkyMostlySunny},
+		{801, 30, false, ecobeeSkyMostlyClear},
+		{802, 45, true, ecobeeSkyPartlyCloudy},
+		{803, 75, false, ecobeeSkyMostlyCloudy},
+		{804, 100, true, ecobeeSkyOvercast},
+		{500, 95, true, ecobeeSkyOvercast},
+		// obscurations take precedence over cloud cover
+		{741, 100, true, ecobeeSkyFog},
+		{701, 0, true, ecobeeSkyLightFog},
+		{711, 50, false, ecobeeSkySmoke},
+		{721, 0, true, ecobeeSkyHaze},
+		{761, 0, true, ecobeeSkyDuststorm},
+		{751, 0, true, ecobeeSkySandstorm},
+	}
+	for _, tt := range tests {
+		if got := ecobeeSkyFor(tt.owmID, tt.cloudCoverPercent, tt.daytime); got != tt.want {
+			t.Errorf("ecobeeSkyFor(%d, %d, %t) = %d; want %d", tt.owmID, tt.cloudCoverPercent, tt.daytime, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// ecobeeConnectorConfig is the subset of the ecobee_influx_connector configuration that
+// has an equivalent in this program's configuration.
+// See https://github.com/cdzombak/ecobee_influx_connector#configuration
+type ecobeeConnectorConfig struct {
+	InfluxServer              string `json:"influx_server"`
+	InfluxOrg                 string `json:"influx_org,omitempty"`
+	InfluxUser                string `json:"influx_user,omitempty"`
+	InfluxPass                string `json:"influx_password,omitempty"`
+	InfluxToken               string `json:"influx_token,omitempty"`
+	InfluxBucket              string `json:"influx_bucket"`
+	InfluxHealthCheckDisabled bool   `json:"influx_health_check_disabled"`
+}
+
+// ecobeeImportPlaceholder is written for config values that can't be derived from an
+// ecobee_influx_connector config and must be filled in by hand.
+const ecobeeImportPlaceholder = "FILL_ME_IN"
+
+// ImportEcobeeConfig reads the ecobee_influx_connector config file at path and returns an
+// equivalent config for this program, writing the ecobee_weather measurement in place of
+// ecobee_influx_connector. It also returns the names of the fields that must be filled in
+// by hand.
+func ImportEcobeeConfig(path string) (Config, []string, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return Config{}, nil, err
+	}
+	var ec ecobeeConnectorConfig
+	if err := json.Unmarshal(b, &ec); err != nil {
+		return Config{}, nil, fmt.Errorf("failed to parse ecobee_influx_connector config: %w", err)
+	}
+
+	// nb. ecobee_influx_connector's config identifies the thermostat by ID; the thermostat
+	// name it tags points with comes from the ecobee API, so it can't be imported.
+	return Config{
+		APIKey:                        ecobeeImportPlaceholder,
+		InfluxServer:                  ec.InfluxServer,
+		InfluxOrg:                     ec.InfluxOrg,
+		InfluxUser:                    ec.InfluxUser,
+		InfluxPass:                    ec.InfluxPass,
+		InfluxToken:                   ec.InfluxToken,
+		InfluxBucket:                  ec.InfluxBucket,
+		InfluxHealthCheckDisabled:     ec.InfluxHealthCheckDisabled,
+		WeatherMeasurementName:        "weather",
+		PollutionMeasurementName:      "pollution",
+		WriteEcobeeWeatherMeasurement: true,
+		EcobeeThermostatName:          ecobeeImportPlaceholder,
+	}, []string{"api_key", "lat", "lon", "ecobee_thermostat_name"}, nil
+}
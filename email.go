@@ -0,0 +1,220 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/smtp"
+	"sort"
+	"strings"
+	"time"
+
+	owm "github.com/briandowns/openweathermap"
+)
+
+const (
+	// EmailModePerRun sends an e-mail containing the current data after every run.
+	EmailModePerRun = "per_run"
+	// EmailModeDigest sends a daily digest e-mail when the program is run with -sendDigest.
+	EmailModeDigest = "digest"
+
+	emailTimeout = 30 * time.Second
+)
+
+// EmailConfig describes the configuration for the SMTP e-mail output.
+type EmailConfig struct {
+	SMTPServer string   `json:"smtp_server"`
+	SMTPUser   string   `json:"smtp_user,omitempty"`
+	SMTPPass   string   `json:"smtp_password,omitempty"`
+	From       string   `json:"from"`
+	To         []string `json:"to"`
+	Mode       string   `json:"mode"`
+}
+
+// Validate checks the e-mail configuration for required fields.
+func (c EmailConfig) Validate() error {
+	if c.SMTPServer == "" {
+		return errors.New("email.smtp_server must be set")
+	}
+	if _, _, err := net.SplitHostPort(c.SMTPServer); err != nil {
+		return fmt.Errorf("email.smtp_server must be in host:port form: %w", err)
+	}
+	if c.From == "" {
+		return errors.New("email.from must be set")
+	}
+	if len(c.To) == 0 {
+		return errors.New("email.to must contain at least one address")
+	}
+	if c.Mode != EmailModePerRun && c.Mode != EmailModeDigest {
+		return fmt.Errorf("email.mode must be '%s' or '%s'", EmailModePerRun, EmailModeDigest)
+	}
+	return nil
+}
+
+// sendEmail sends a plain-text e-mail with the given subject and body. Nothing is sent
+// during a replay.
+func sendEmail(c EmailConfig, subject, body string) error {
+	if replaying {
+		slog.Info("Not sending e-mail during replay", "subject", subject)
+		return nil
+	}
+	host, _, _ := net.SplitHostPort(c.SMTPServer)
+	var auth smtp.Auth
+	if c.SMTPUser != "" || c.SMTPPass != "" {
+		auth = smtp.PlainAuth("", c.SMTPUser, c.SMTPPass, host)
+	}
+
+	var msg strings.Builder
+	fmt.Fprintf(&msg, "From: %s\r\n", c.From)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(c.To, ", "))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", subject)
+	fmt.Fprintf(&msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	msg.WriteString("\r\n")
+	msg.WriteString(strings.ReplaceAll(body, "\n", "\r\n"))
+
+	return sendMail(c.SMTPServer, host, auth, c.From, c.To, []byte(msg.String()))
+}
+
+// sendMail is equivalent to smtp.SendMail, but the whole exchange with the server must
+// complete within emailTimeout, so a hung SMTP server can't stall the run.
+func sendMail(addr, host string, auth smtp.Auth, from string, to []string, msg []byte) error {
+	conn, err := net.DialTimeout("tcp", addr, emailTimeout)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if err := conn.SetDeadline(time.Now().Add(emailTimeout)); err != nil {
+		return err
+	}
+
+	c, err := smtp.NewClient(conn, host)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err := c.StartTLS(&tls.Config{ServerName: host}); err != nil {
+			return err
+		}
+	}
+	if auth != nil {
+		if ok, _ := c.Extension("AUTH"); ok {
+			if err := c.Auth(auth); err != nil {
+				return err
+			}
+		}
+	}
+	if err := c.Mail(from); err != nil {
+		return err
+	}
+	for _, addr := range to {
+		if err := c.Rcpt(addr); err != nil {
+			return err
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(msg); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
+
+// sendDigest sends a daily digest e-mail containing yesterday's stats (queried from
+// Influx) and today's forecast (fetched from OpenWeatherMap).
+func sendDigest(config Config, writer *influxWriter, coords owm.Coordinates) error {
+	now := now()
+	todayStart := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	yesterdayStart := todayStart.AddDate(0, 0, -1)
+
+	var body strings.Builder
+	fmt.Fprintf(&body, "Yesterday (%s):\n", yesterdayStart.Format("Mon Jan 2"))
+
+	stats := []struct {
+		label       string
+		measurement string
+		field       string
+		fn          string
+		format      string
+	}{
+		{"high", config.WeatherMeasurementName, "temp_f", "max", "%.1f degF"},
+		{"low", config.WeatherMeasurementName, "temp_f", "min", "%.1f degF"},
+		{"average", config.WeatherMeasurementName, "temp_f", "mean", "%.1f degF"},
+		{"average humidity", config.WeatherMeasurementName, "rel_humidity", "mean", "%.0f%%"},
+		{"peak wind", config.WeatherMeasurementName, "wind_speed_mph", "max", "%.1f mph"},
+		{"worst AQI (US EPA)", config.PollutionMeasurementName, "aqi_us", "max", "%.0f"},
+	}
+	for _, s := range stats {
+		ctx, cancel := context.WithTimeout(context.Background(), influxTimeout)
+		v, ok, err := writer.QueryAggregate(ctx, s.measurement, s.field, s.fn, config.primarySources(), config.coordTags(), yesterdayStart, todayStart)
+		cancel()
+		if err != nil {
+			return fmt.Errorf("failed to query %s %s: %w", s.measurement, s.field, err)
+		}
+		if !ok {
+			fmt.Fprintf(&body, "\t%s: no data\n", s.label)
+			continue
+		}
+		fmt.Fprintf(&body, "\t%s: "+s.format+"\n", s.label, v)
+	}
+
+	forecast, err := fetchForecast5(config.APIKey, coords)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(&body, "\nToday (%s):\n", todayStart.Format("Mon Jan 2"))
+	body.WriteString(todayForecastSummary(forecast, todayStart))
+
+	return sendEmail(*config.Email, fmt.Sprintf("Weather digest for %s", todayStart.Format("Mon Jan 2")), body.String())
+}
+
+// todayForecastSummary summarizes the 3-hourly forecast entries falling on the day starting at dayStart.
+func todayForecastSummary(forecast *owm.Forecast5WeatherData, dayStart time.Time) string {
+	dayEnd := dayStart.AddDate(0, 0, 1)
+	high, low := 0.0, 0.0
+	conditions := make(map[string]int)
+	count := 0
+	for _, item := range forecast.List {
+		t := time.Unix(int64(item.Dt), 0)
+		if t.Before(dayStart) || !t.Before(dayEnd) {
+			continue
+		}
+		if count == 0 || item.Main.TempMax > high {
+			high = item.Main.TempMax
+		}
+		if count == 0 || item.Main.TempMin < low {
+			low = item.Main.TempMin
+		}
+		for _, w := range item.Weather {
+			conditions[w.Description]++
+		}
+		count++
+	}
+	if count == 0 {
+		return "\tno forecast data\n"
+	}
+
+	condNames := make([]string, 0, len(conditions))
+	for c := range conditions {
+		condNames = append(condNames, c)
+	}
+	sort.SliceStable(condNames, func(i, j int) bool {
+		if conditions[condNames[i]] != conditions[condNames[j]] {
+			return conditions[condNames[i]] > conditions[condNames[j]]
+		}
+		return condNames[i] < condNames[j]
+	})
+
+	return fmt.Sprintf("\thigh: %.1f degF\n\tlow: %.1f degF\n\tconditions: %s\n", high, low, strings.Join(condNames, ", "))
+}
@@ -0,0 +1,215 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+const (
+	// EnergyProviderComEd fetches ComEd Hourly Pricing program prices (northern Illinois).
+	EnergyProviderComEd = "comed"
+	// EnergyProviderENTSOE fetches day-ahead prices from the ENTSO-E Transparency Platform (Europe).
+	EnergyProviderENTSOE = "entsoe"
+
+	defaultEnergyPriceMeasurementName = "energy_price"
+	energyPriceTimeout                = 10 * time.Second
+
+	energyProviderTag = "provider"
+	energyAreaTag     = "area"
+	energyUnitTag     = "unit"
+)
+
+// EnergyPriceConfig describes the configuration for electricity price ingestion.
+type EnergyPriceConfig struct {
+	Provider        string `json:"provider"`
+	ENTSOEToken     string `json:"entsoe_token,omitempty"`
+	ENTSOEArea      string `json:"entsoe_area,omitempty"`
+	MeasurementName string `json:"measurement_name,omitempty"`
+}
+
+// Validate checks the energy price configuration.
+func (c EnergyPriceConfig) Validate() error {
+	switch c.Provider {
+	case EnergyProviderComEd:
+	case EnergyProviderENTSOE:
+		if c.ENTSOEToken == "" || c.ENTSOEArea == "" {
+			return errors.New("entsoe_token and entsoe_area must be set for the entsoe provider")
+		}
+	default:
+		return fmt.Errorf("provider must be '%s' or '%s'", EnergyProviderComEd, EnergyProviderENTSOE)
+	}
+	return nil
+}
+
+// Measurement returns the configured measurement name, or the default "energy_price".
+func (c EnergyPriceConfig) Measurement() string {
+	if c.MeasurementName == "" {
+		return defaultEnergyPriceMeasurementName
+	}
+	return c.MeasurementName
+}
+
+// EnergyPrice is the electricity price for the period beginning at Time.
+type EnergyPrice struct {
+	Time  time.Time
+	Price float64
+}
+
+// CurrentEnergyPrice returns the price in effect at t: that of the latest period starting
+// at or before t, if it started less than an hour before t. The boolean return value is
+// false if there's no such price.
+func CurrentEnergyPrice(prices []EnergyPrice, t time.Time) (float64, bool) {
+	var current *EnergyPrice
+	for i, p := range prices {
+		if p.Time.After(t) || t.Sub(p.Time) >= time.Hour {
+			continue
+		}
+		if current == nil || p.Time.After(current.Time) {
+			current = &prices[i]
+		}
+	}
+	if current == nil {
+		return 0, false
+	}
+	return current.Price, true
+}
+
+// FetchEnergyPrices fetches electricity prices from the configured provider. It returns
+// the prices along with the area and unit they're reported in.
+func FetchEnergyPrices(ctx context.Context, c EnergyPriceConfig) (prices []EnergyPrice, area, unit string, err error) {
+	switch c.Provider {
+	case EnergyProviderComEd:
+		prices, err = fetchComEdPrices(ctx)
+		return prices, "comed", "cents/kWh", err
+	case EnergyProviderENTSOE:
+		prices, err = fetchENTSOEDayAheadPrices(ctx, c.ENTSOEToken, c.ENTSOEArea)
+		return prices, c.ENTSOEArea, "EUR/MWh", err
+	default:
+		return nil, "", "", fmt.Errorf("unknown energy price provider '%s'", c.Provider)
+	}
+}
+
+// fetchComEdPrices fetches the current hour's average real-time price from ComEd.
+// See https://hourlypricing.comed.com/hp-api/
+func fetchComEdPrices(ctx context.Context) ([]EnergyPrice, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "https://hourlypricing.comed.com/api?type=currenthouraverage", nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := owmHTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("ComEd API returned %s", resp.Status)
+	}
+
+	var body []struct {
+		MillisUTC string `json:"millisUTC"`
+		Price     string `json:"price"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("failed to decode ComEd response: %w", err)
+	}
+
+	prices := make([]EnergyPrice, 0, len(body))
+	for _, p := range body {
+		millis, err := strconv.ParseInt(p.MillisUTC, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse ComEd timestamp '%s': %w", p.MillisUTC, err)
+		}
+		price, err := strconv.ParseFloat(p.Price, 64)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse ComEd price '%s': %w", p.Price, err)
+		}
+		// nb. the timestamp is that of the latest 5-minute price in the hour; report it for the hour
+		prices = append(prices, EnergyPrice{Time: time.UnixMilli(millis).Truncate(time.Hour), Price: price})
+	}
+	return prices, nil
+}
+
+type entsoePublicationMarketDocument struct {
+	TimeSeries []struct {
+		Period []struct {
+			TimeInterval struct {
+				Start string `xml:"start"`
+			} `xml:"timeInterval"`
+			Resolution string `xml:"resolution"`
+			Points     []struct {
+				Position int     `xml:"position"`
+				Price    float64 `xml:"price.amount"`
+			} `xml:"Point"`
+		} `xml:"Period"`
+	} `xml:"TimeSeries"`
+}
+
+// fetchENTSOEDayAheadPrices fetches today's and tomorrow's day-ahead prices for the given
+// bidding zone (EIC code) from the ENTSO-E Transparency Platform.
+// See https://transparency.entsoe.eu/content/static_content/Static%20content/web%20api/Guide.html
+func fetchENTSOEDayAheadPrices(ctx context.Context, token, area string) ([]EnergyPrice, error) {
+	now := now().UTC()
+	start := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	end := start.AddDate(0, 0, 2)
+
+	q := url.Values{}
+	q.Set("securityToken", token)
+	q.Set("documentType", "A44")
+	q.Set("in_Domain", area)
+	q.Set("out_Domain", area)
+	q.Set("periodStart", start.Format("200601021504"))
+	q.Set("periodEnd", end.Format("200601021504"))
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "https://web-api.tp.entsoe.eu/api?"+q.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := owmHTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("ENTSO-E API returned %s", resp.Status)
+	}
+
+	var doc entsoePublicationMarketDocument
+	if err := xml.NewDecoder(resp.Body).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("failed to decode ENTSO-E response: %w", err)
+	}
+
+	var prices []EnergyPrice
+	for _, ts := range doc.TimeSeries {
+		for _, period := range ts.Period {
+			periodStart, err := time.Parse("2006-01-02T15:04Z", period.TimeInterval.Start)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse ENTSO-E period start '%s': %w", period.TimeInterval.Start, err)
+			}
+			var resolution time.Duration
+			switch period.Resolution {
+			case "PT60M":
+				resolution = time.Hour
+			case "PT30M":
+				resolution = 30 * time.Minute
+			case "PT15M":
+				resolution = 15 * time.Minute
+			default:
+				return nil, fmt.Errorf("unsupported ENTSO-E resolution '%s'", period.Resolution)
+			}
+			for _, p := range period.Points {
+				prices = append(prices, EnergyPrice{
+					Time:  periodStart.Add(time.Duration(p.Position-1) * resolution),
+					Price: p.Price,
+				})
+			}
+		}
+	}
+	return prices, nil
+}
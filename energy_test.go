@@ -0,0 +1,41 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCurrentEnergyPrice(t *testing.T) {
+	hour := time.Date(2024, 6, 1, 14, 0, 0, 0, time.UTC)
+	hourly := []EnergyPrice{
+		{Time: hour.Add(time.Hour), Price: 3},
+		{Time: hour, Price: 2},
+		{Time: hour.Add(-time.Hour), Price: 1},
+	}
+	quarterHourly := []EnergyPrice{
+		{Time: hour, Price: 10},
+		{Time: hour.Add(15 * time.Minute), Price: 11},
+		{Time: hour.Add(30 * time.Minute), Price: 12},
+	}
+	tests := []struct {
+		name   string
+		prices []EnergyPrice
+		t      time.Time
+		want   float64
+		wantOK bool
+	}{
+		{"start of hour", hourly, hour, 2, true},
+		{"within hour", hourly, hour.Add(59 * time.Minute), 2, true},
+		{"last price", hourly, hour.Add(90 * time.Minute), 3, true},
+		{"after last price's hour", hourly, hour.Add(2 * time.Hour), 0, false},
+		{"before first price", hourly, hour.Add(-2 * time.Hour), 0, false},
+		{"quarter-hourly", quarterHourly, hour.Add(20 * time.Minute), 11, true},
+		{"no prices", nil, hour, 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := CurrentEnergyPrice(tt.prices, tt.t)
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("%s: CurrentEnergyPrice() = %v, %t; want %v, %t", tt.name, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+const (
+	defaultExecName    = "exec"
+	defaultExecTimeout = 10 * time.Second
+)
+
+// ExecConfig describes the configuration for the external command output.
+type ExecConfig struct {
+	Name    string   `json:"name,omitempty"`
+	Command []string `json:"command"`
+	Timeout string   `json:"timeout,omitempty"`
+}
+
+// Validate checks the exec configuration.
+func (c ExecConfig) Validate() error {
+	if len(c.Command) == 0 || c.Command[0] == "" {
+		return errors.New("command must be set")
+	}
+	if c.Timeout != "" {
+		if d, err := time.ParseDuration(c.Timeout); err != nil || d <= 0 {
+			return fmt.Errorf("invalid timeout '%s'", c.Timeout)
+		}
+	}
+	return nil
+}
+
+// OutputName returns the configured output name, or the default "exec".
+func (c ExecConfig) OutputName() string {
+	if c.Name == "" {
+		return defaultExecName
+	}
+	return c.Name
+}
+
+// CommandTimeout returns how long each invocation of the command may run.
+func (c ExecConfig) CommandTimeout() time.Duration {
+	if c.Timeout == "" {
+		return defaultExecTimeout
+	}
+	d, _ := time.ParseDuration(c.Timeout)
+	return d
+}
+
+// execOutput runs an external command for each point, with the point's JSON encoding on
+// the command's stdin.
+type execOutput struct {
+	config ExecConfig
+}
+
+// Name returns the output's name, for output routing.
+func (o *execOutput) Name() string {
+	return o.config.OutputName()
+}
+
+// WritePoint runs the command with the point's JSON on stdin. The point fails to write if
+// the command exits with a nonzero status or doesn't finish within the timeout.
+func (o *execOutput) WritePoint(ctx context.Context, measurement string, tags map[string]string, fields map[string]interface{}, ts time.Time) error {
+	payload, err := marshalPoint(measurement, tags, fields, ts)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, o.config.CommandTimeout())
+	defer cancel()
+	cmd := exec.CommandContext(ctx, o.config.Command[0], o.config.Command[1:]...)
+	cmd.Stdin = bytes.NewReader(payload)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return fmt.Errorf("%w: %s", err, msg)
+		}
+		return err
+	}
+	return nil
+}
+
+// Close is a no-op; the command is run separately for each point.
+func (o *execOutput) Close() {}
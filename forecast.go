@@ -0,0 +1,28 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	owm "github.com/briandowns/openweathermap"
+)
+
+// forecast5MaxCount is the maximum number of 3-hourly entries returned by the 5-day forecast API.
+const forecast5MaxCount = 40
+
+// fetchForecast5 fetches the 5 day / 3 hour forecast for the given location, in imperial units.
+// See https://openweathermap.org/forecast5
+func fetchForecast5(apiKey string, coords owm.Coordinates) (*owm.Forecast5WeatherData, error) {
+	fc, err := owm.NewForecast("5", "F", "EN", apiKey, owm.WithHttpClient(owmHTTPClient))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create OpenWeatherMap forecast client: %w", err)
+	}
+	if err := fc.DailyByCoordinates(&coords, forecast5MaxCount); err != nil {
+		return nil, fmt.Errorf("failed to get forecast from OpenWeatherMap: %w", err)
+	}
+	forecast, ok := fc.ForecastWeatherJson.(*owm.Forecast5WeatherData)
+	if !ok {
+		return nil, errors.New("unexpected forecast response type")
+	}
+	return forecast, nil
+}
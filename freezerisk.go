@@ -0,0 +1,95 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	owm "github.com/briandowns/openweathermap"
+)
+
+const (
+	defaultFreezeRiskHours           = 48
+	maxFreezeRiskHours               = 120
+	defaultFreezeRiskThreshold       = 100.0
+	defaultFreezeRiskMeasurementName = "freeze_risk"
+
+	// pipeFreezeTempF is the temperature below which exposed pipes lose heat toward freezing.
+	pipeFreezeTempF = 32.0
+	// pipeFreezeWindFactor is the effective temperature reduction (degF) per mph of wind,
+	// a rough heuristic for increased convective heat loss from exposed or poorly insulated pipes.
+	pipeFreezeWindFactor = 0.5
+)
+
+// FreezeRiskConfig describes the configuration for pipe-freeze risk alerting.
+type FreezeRiskConfig struct {
+	Hours           int      `json:"hours,omitempty"`
+	Threshold       *float64 `json:"threshold,omitempty"`
+	MeasurementName string   `json:"measurement_name,omitempty"`
+}
+
+// Validate checks the freeze risk configuration.
+func (c FreezeRiskConfig) Validate() error {
+	if c.Hours < 0 || c.Hours > maxFreezeRiskHours {
+		return fmt.Errorf("hours must be between 1 and %d", maxFreezeRiskHours)
+	}
+	return nil
+}
+
+// Horizon returns how far into the forecast to look for freeze risk.
+func (c FreezeRiskConfig) Horizon() time.Duration {
+	if c.Hours == 0 {
+		return defaultFreezeRiskHours * time.Hour
+	}
+	return time.Duration(c.Hours) * time.Hour
+}
+
+// ThresholdScore returns the risk score at or above which the freeze alert is raised.
+func (c FreezeRiskConfig) ThresholdScore() float64 {
+	if c.Threshold == nil {
+		return defaultFreezeRiskThreshold
+	}
+	return *c.Threshold
+}
+
+// Measurement returns the configured measurement name, or the default "freeze_risk".
+func (c FreezeRiskConfig) Measurement() string {
+	if c.MeasurementName == "" {
+		return defaultFreezeRiskMeasurementName
+	}
+	return c.MeasurementName
+}
+
+// FreezeRisk summarizes the pipe-freeze risk over a forecast horizon.
+type FreezeRisk struct {
+	// Score is the forecast freezing degree-hours: the sum over the horizon of how far the
+	// wind-adjusted temperature falls below freezing (degF), times the duration (hours).
+	Score       float64
+	MinTempF    float64
+	MinTempTime time.Time
+	MaxWindMph  float64
+}
+
+// CalculateFreezeRisk calculates the pipe-freeze risk from the 3-hourly forecast entries
+// between now and now+horizon.
+func CalculateFreezeRisk(forecast *owm.Forecast5WeatherData, now time.Time, horizon time.Duration) (FreezeRisk, bool) {
+	const stepHours = 3.0
+	end := now.Add(horizon)
+	risk := FreezeRisk{MinTempF: math.Inf(1)}
+	found := false
+	for _, item := range forecast.List {
+		t := time.Unix(int64(item.Dt), 0)
+		if t.Before(now) || t.After(end) {
+			continue
+		}
+		found = true
+		effective := item.Main.Temp - pipeFreezeWindFactor*item.Wind.Speed
+		risk.Score += math.Max(0, pipeFreezeTempF-effective) * stepHours
+		if item.Main.TempMin < risk.MinTempF {
+			risk.MinTempF = item.Main.TempMin
+			risk.MinTempTime = t
+		}
+		risk.MaxWindMph = math.Max(risk.MaxWindMph, item.Wind.Speed)
+	}
+	return risk, found
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+const heartbeatTimeout = 10 * time.Second
+
+// heartbeatURL is the configured monitoring URL to ping when the program completes
+// (or fails), or "" if heartbeat pings are disabled.
+var heartbeatURL string
+
+// heartbeatFailURL is the configured monitoring URL to ping when the program fails, or ""
+// to use heartbeatURL's failure endpoint.
+var heartbeatFailURL string
+
+// heartbeatFailEndpoint returns the failure endpoint for the given heartbeat URL. For an
+// Uptime Kuma push URL (one under /api/push/), that's the same URL with status=down and
+// without its (success) msg; otherwise it's the Healthchecks.io-style /fail endpoint,
+// keeping any query string intact.
+func heartbeatFailEndpoint(heartbeat string) (string, error) {
+	u, err := url.Parse(heartbeat)
+	if err != nil {
+		return "", err
+	}
+	if strings.Contains(u.Path, "/api/push/") {
+		q := u.Query()
+		q.Set("status", "down")
+		q.Del("msg")
+		u.RawQuery = q.Encode()
+		return u.String(), nil
+	}
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/fail"
+	u.RawPath = ""
+	return u.String(), nil
+}
+
+// pingHeartbeat pings the heartbeat URL; if fail is true, it pings the heartbeat failure
+// URL, or the heartbeat URL's failure endpoint (see heartbeatFailEndpoint) if no failure
+// URL is configured. The message is sent as the request body.
+func pingHeartbeat(fail bool, msg string) {
+	if heartbeatURL == "" {
+		return
+	}
+	u := heartbeatURL
+	if fail {
+		u = heartbeatFailURL
+		if u == "" {
+			var err error
+			if u, err = heartbeatFailEndpoint(heartbeatURL); err != nil {
+				slog.Error("Failed to ping heartbeat URL", "error", err)
+				return
+			}
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), heartbeatTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u, strings.NewReader(msg))
+	if err != nil {
+		slog.Error("Failed to ping heartbeat URL", "error", err)
+		return
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		slog.Error("Failed to ping heartbeat URL", "error", err)
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		slog.Error("Heartbeat URL returned an error", "status", resp.Status)
+	}
+}
+
+// closeOnFatal, if set, is called by fatal before exiting, to flush points already
+// written to batched or buffered outputs.
+var closeOnFatal func()
+
+// fatal logs the given message at error level, closes outputs, pings the heartbeat failure
+// URL, and exits.
+func fatal(v ...interface{}) {
+	msg := fmt.Sprint(v...)
+	slog.Error(msg)
+	if closeOnFatal != nil {
+		closeOnFatal()
+	}
+	pingHeartbeat(true, msg)
+	os.Exit(1)
+}
+
+// fatalf is like fatal, with fmt.Sprintf-style formatting.
+func fatalf(format string, v ...interface{}) {
+	fatal(fmt.Sprintf(format, v...))
+}
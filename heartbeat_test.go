@@ -0,0 +1,28 @@
+package main
+
+import "testing"
+
+func TestHeartbeatFailEndpoint(t *testing.T) {
+	tests := []struct {
+		heartbeat string
+		want      string
+	}{
+		{"https://hc-ping.com/abc123", "https://hc-ping.com/abc123/fail"},
+		{"https://hc-ping.com/abc123/", "https://hc-ping.com/abc123/fail"},
+		{"https://hc-ping.com/abc123?rid=1", "https://hc-ping.com/abc123/fail?rid=1"},
+		{"https://kuma.example.com/api/push/xyz?status=up&msg=OK", "https://kuma.example.com/api/push/xyz?status=down"},
+		{"https://kuma.example.com/api/push/xyz?status=up&msg=OK&ping=", "https://kuma.example.com/api/push/xyz?ping=&status=down"},
+		{"https://kuma.example.com/api/push/xyz", "https://kuma.example.com/api/push/xyz?status=down"},
+		{"https://example.com", "https://example.com/fail"},
+	}
+	for _, tt := range tests {
+		got, err := heartbeatFailEndpoint(tt.heartbeat)
+		if err != nil {
+			t.Errorf("heartbeatFailEndpoint(%q) returned error: %v", tt.heartbeat, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("heartbeatFailEndpoint(%q) = %q; want %q", tt.heartbeat, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,587 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/avast/retry-go"
+	influxdb2 "github.com/influxdata/influxdb-client-go/v2"
+	"github.com/influxdata/influxdb-client-go/v2/api"
+)
+
+const (
+	// DuplicatePolicyOverwrite writes every point as-is; a point with identical
+	// measurement, tags, and timestamp overwrites the existing one in Influx.
+	DuplicatePolicyOverwrite = "overwrite"
+	// DuplicatePolicyRunID tags every point with a per-run run_id, so re-runs
+	// over the same timestamps are kept as distinct series.
+	DuplicatePolicyRunID = "run_id"
+	// DuplicatePolicySkip skips writing a point if Influx already has a point
+	// for the same measurement, tags, and timestamp.
+	DuplicatePolicySkip = "skip"
+
+	runIDTag = "run_id"
+)
+
+// errNoQueryTarget is returned by queries when points aren't being written to InfluxDB.
+var errNoQueryTarget = errors.New("no InfluxDB target to query")
+
+// InfluxTargetConfig describes an InfluxDB server and bucket to write to.
+type InfluxTargetConfig struct {
+	Name                      string           `json:"name,omitempty"`
+	InfluxServer              string           `json:"influx_server"`
+	InfluxOrg                 string           `json:"influx_org,omitempty"`
+	InfluxUser                string           `json:"influx_user,omitempty"`
+	InfluxPass                string           `json:"influx_password,omitempty"`
+	InfluxToken               string           `json:"influx_token,omitempty"`
+	InfluxBucket              string           `json:"influx_bucket"`
+	InfluxHealthCheckDisabled bool             `json:"influx_health_check_disabled,omitempty"`
+	InfluxProxyURL            string           `json:"influx_proxy_url,omitempty"`
+	InfluxTLS                 *InfluxTLSConfig `json:"influx_tls,omitempty"`
+}
+
+// TargetName returns the target's configured name, or its server URL if no name is set.
+func (c InfluxTargetConfig) TargetName() string {
+	if c.Name == "" {
+		return c.InfluxServer
+	}
+	return c.Name
+}
+
+// influxTarget is a single InfluxDB server and bucket that points are written to.
+type influxTarget struct {
+	name     string
+	server   string
+	policy   string
+	client   influxdb2.Client
+	writeAPI api.WriteAPIBlocking
+	// asyncAPI, if set, is used to write points in batches in the background instead of writeAPI.
+	asyncAPI      api.WriteAPI
+	asyncDone     chan struct{}
+	asyncFailures atomic.Int32
+	queryAPI      api.QueryAPI
+	bucket        string
+}
+
+// influxWriter writes points to one or more InfluxDB targets with retries, applying the
+// configured duplicate policy, and to any other configured outputs. Each output has its
+// own write deadline and failure budget (see sink). Queries are run against the first
+// (primary) InfluxDB target.
+type influxWriter struct {
+	targets []*influxTarget
+	outputs []*sink
+	// printer, if set, is given every point, regardless of routing, for -printData.
+	printer  pointOutput
+	queryAPI api.QueryAPI
+	bucket   string
+	policy   string
+	runID    string
+
+	fieldTypes map[string]FieldType
+	typeGuard  *State
+	// routes maps measurement names to the names of the outputs they're written to;
+	// measurements not listed are written to every output.
+	routes    map[string][]string
+	writes    int
+	failures  int
+	closeOnce sync.Once
+}
+
+// InfluxTLSConfig describes TLS options for the Influx connection.
+type InfluxTLSConfig struct {
+	CAFile             string `json:"ca_file,omitempty"`
+	CertFile           string `json:"cert_file,omitempty"`
+	KeyFile            string `json:"key_file,omitempty"`
+	InsecureSkipVerify bool   `json:"insecure_skip_verify,omitempty"`
+}
+
+// TLSConfig builds a tls.Config from the Influx TLS options.
+func (c InfluxTLSConfig) TLSConfig() (*tls.Config, error) {
+	tlsConfig := &tls.Config{InsecureSkipVerify: c.InsecureSkipVerify}
+	if c.CAFile != "" {
+		pem, err := os.ReadFile(c.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in '%s'", c.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	if c.CertFile != "" || c.KeyFile != "" {
+		if c.CertFile == "" || c.KeyFile == "" {
+			return nil, errors.New("cert_file and key_file must be set together")
+		}
+		cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	return tlsConfig, nil
+}
+
+// influxClientOptions returns the Influx client options for the given target.
+func influxClientOptions(target InfluxTargetConfig, config Config) (*influxdb2.Options, error) {
+	opts := influxdb2.DefaultOptions()
+	if target.InfluxTLS != nil {
+		tlsConfig, err := target.InfluxTLS.TLSConfig()
+		if err != nil {
+			return nil, fmt.Errorf("invalid influx_tls configuration: %w", err)
+		}
+		opts.SetTLSConfig(tlsConfig)
+	}
+	switch config.InfluxWritePrecision {
+	case "", "ns":
+	case "s":
+		opts.SetPrecision(time.Second)
+	case "ms":
+		opts.SetPrecision(time.Millisecond)
+	case "us":
+		opts.SetPrecision(time.Microsecond)
+	default:
+		return nil, fmt.Errorf("influx_write_precision must be 's', 'ms', 'us', or 'ns'; got '%s'", config.InfluxWritePrecision)
+	}
+	if target.InfluxProxyURL != "" {
+		proxyURL, err := url.Parse(target.InfluxProxyURL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid influx_proxy_url: %w", err)
+		}
+		transport, ok := opts.HTTPClient().Transport.(*http.Transport)
+		if !ok {
+			return nil, fmt.Errorf("unexpected Influx HTTP transport type %T", opts.HTTPClient().Transport)
+		}
+		transport.Proxy = http.ProxyURL(proxyURL)
+	}
+	return opts, nil
+}
+
+// newInfluxTarget connects to the given InfluxDB target, checking its health unless
+// the health check is disabled.
+func newInfluxTarget(tc InfluxTargetConfig, config Config) (*influxTarget, error) {
+	authString := ""
+	if tc.InfluxUser != "" || tc.InfluxPass != "" {
+		authString = fmt.Sprintf("%s:%s", tc.InfluxUser, tc.InfluxPass)
+	} else if tc.InfluxToken != "" {
+		authString = tc.InfluxToken
+	}
+	opts, err := influxClientOptions(tc, config)
+	if err != nil {
+		return nil, err
+	}
+	client := influxdb2.NewClientWithOptions(tc.InfluxServer, authString, opts)
+	if !tc.InfluxHealthCheckDisabled {
+		ctx, cancel := context.WithTimeout(context.Background(), influxTimeout)
+		defer cancel()
+		health, err := client.Health(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to check InfluxDB health: %w", err)
+		}
+		if health.Status != "pass" {
+			msg := ""
+			if health.Message != nil {
+				msg = *health.Message
+			}
+			return nil, fmt.Errorf("InfluxDB did not pass health check: status %s; message '%s'", health.Status, msg)
+		}
+	}
+
+	t := &influxTarget{
+		name:     tc.TargetName(),
+		server:   tc.InfluxServer,
+		policy:   duplicatePolicy(config),
+		client:   client,
+		writeAPI: client.WriteAPIBlocking(tc.InfluxOrg, tc.InfluxBucket),
+		queryAPI: client.QueryAPI(tc.InfluxOrg),
+		bucket:   tc.InfluxBucket,
+	}
+	if config.InfluxBatchWrites {
+		t.asyncAPI = client.WriteAPI(tc.InfluxOrg, tc.InfluxBucket)
+		t.asyncDone = make(chan struct{})
+		errs := t.asyncAPI.Errors()
+		go func() {
+			for err := range errs {
+				slog.Error("Failed to write batch to influx", "server", t.server, "error", err)
+				t.asyncFailures.Add(1)
+			}
+			close(t.asyncDone)
+		}()
+	}
+	return t, nil
+}
+
+// newInfluxTargets connects to the primary InfluxDB target and any additional targets. A
+// target that can't be connected to is logged and skipped; an error is returned only if
+// none of them can be. If the primary target is skipped, the first available additional
+// target takes its place (as the target queries are run against).
+func newInfluxTargets(config Config) ([]*influxTarget, error) {
+	configs := append([]InfluxTargetConfig{config.primaryInfluxTarget()}, config.InfluxOutputs...)
+	var targets []*influxTarget
+	var errs []error
+	for i, tc := range configs {
+		t, err := newInfluxTarget(tc, config)
+		if err != nil {
+			slog.Error("Failed to connect to InfluxDB target; not writing to it", "server", tc.InfluxServer, "primary", i == 0, "error", err)
+			errs = append(errs, fmt.Errorf("%s: %w", tc.TargetName(), err))
+			continue
+		}
+		targets = append(targets, t)
+	}
+	if len(targets) == 0 {
+		return nil, fmt.Errorf("failed to connect to any InfluxDB target: %w", errors.Join(errs...))
+	}
+	return targets, nil
+}
+
+// Name returns the target's name, for output routing.
+func (t *influxTarget) Name() string {
+	return t.name
+}
+
+// Close flushes any points queued for batched writing.
+func (t *influxTarget) Close() {
+	if t.asyncAPI == nil {
+		return
+	}
+	t.client.Close()
+	<-t.asyncDone
+}
+
+// WritePoint writes a single point to the target, retrying on failure until ctx is done.
+func (t *influxTarget) WritePoint(ctx context.Context, measurement string, tags map[string]string, fields map[string]interface{}, ts time.Time) error {
+	return retry.Do(func() error {
+		ctx, cancel := context.WithTimeout(ctx, influxTimeout)
+		defer cancel()
+
+		if t.policy == DuplicatePolicySkip {
+			exists, err := t.pointExists(ctx, measurement, tags, ts)
+			if err != nil {
+				return fmt.Errorf("failed to check for existing point: %w", err)
+			}
+			if exists {
+				slog.Debug("Skipping point that already exists", "server", t.server, "measurement", measurement, "time", ts)
+				return nil
+			}
+		}
+
+		p := influxdb2.NewPoint(measurement, tags, fields, ts)
+		if t.asyncAPI != nil {
+			t.asyncAPI.WritePoint(p)
+			return nil
+		}
+		return t.writeAPI.WritePoint(ctx, p)
+	}, retry.Attempts(influxAttempts), retry.Delay(influxRetryDelay), retry.Context(ctx))
+}
+
+// duplicatePolicy returns the configured duplicate policy, or the default "overwrite".
+func duplicatePolicy(config Config) string {
+	if config.InfluxDuplicatePolicy == "" {
+		return DuplicatePolicyOverwrite
+	}
+	return config.InfluxDuplicatePolicy
+}
+
+// newInfluxWriter returns an influxWriter for the given InfluxDB targets, the first of
+// which is the primary target, and other outputs. If there are no InfluxDB targets,
+// queries return errNoQueryTarget.
+func newInfluxWriter(targets []*influxTarget, others []pointOutput, config Config, state *State) *influxWriter {
+	outputs := make([]*sink, 0, len(targets)+len(others))
+	for _, t := range targets {
+		outputs = append(outputs, newSink(t, config))
+	}
+	for _, o := range others {
+		outputs = append(outputs, newSink(o, config))
+	}
+	w := &influxWriter{
+		targets:    targets,
+		outputs:    outputs,
+		policy:     duplicatePolicy(config),
+		runID:      now().UTC().Format("20060102T150405Z"),
+		fieldTypes: config.FieldTypes,
+		routes:     config.OutputRoutes,
+	}
+	if len(targets) > 0 {
+		w.queryAPI = targets[0].queryAPI
+		w.bucket = targets[0].bucket
+	}
+	if config.FieldTypeGuard {
+		w.typeGuard = state
+	}
+	return w
+}
+
+// Close flushes any points queued for batched writing and closes all outputs. It must be
+// called before exiting; calls after the first have no effect.
+func (w *influxWriter) Close() {
+	w.closeOnce.Do(func() {
+		for _, o := range w.outputs {
+			o.Close()
+		}
+		if w.printer != nil {
+			w.printer.Close()
+		}
+	})
+}
+
+// WritePoint writes a single point to Influx and any other outputs, retrying on failure.
+// Fields are first coerced to any configured field types; if the field type guard is
+// enabled, the point is not written if any field's type differs from the type previously
+// written for that field.
+func (w *influxWriter) WritePoint(measurement string, tags map[string]string, fields map[string]interface{}, ts time.Time) error {
+	outputs := w.outputsFor(measurement)
+	if err := w.preparePoint(measurement, tags, fields); err != nil {
+		w.failures += len(outputs)
+		return err
+	}
+	if w.printer != nil {
+		if err := w.printer.WritePoint(context.Background(), measurement, tags, fields, ts); err != nil {
+			slog.Error("Failed to print point", "measurement", measurement, "error", err)
+		}
+	}
+	if len(outputs) == 0 {
+		slog.Warn("No available output for measurement; not writing", "measurement", measurement)
+		return nil
+	}
+
+	// nb. outputs are written concurrently, so a slow or unavailable output delays the others
+	// by at most its write timeout. A write that times out may still be running after this
+	// returns, so it's given its own copy of the point.
+	tags, fields = copyTags(tags), copyFields(fields)
+	errs := make([]error, len(outputs))
+	var wg sync.WaitGroup
+	for i, o := range outputs {
+		wg.Add(1)
+		go func(i int, o *sink) {
+			defer wg.Done()
+			if err := o.WritePoint(measurement, tags, fields, ts); err != nil {
+				errs[i] = fmt.Errorf("%s: %w", o.Name(), err)
+			}
+		}(i, o)
+	}
+	wg.Wait()
+
+	ok := false
+	for _, err := range errs {
+		if err != nil {
+			w.failures++
+		} else {
+			w.writes++
+			ok = true
+		}
+	}
+	if ok {
+		slog.Debug("Wrote point", "measurement", measurement, "time", ts, "fields", len(fields))
+		if w.typeGuard != nil {
+			w.typeGuard.RecordFieldTypes(measurement, fields)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// outputsFor returns the outputs the given measurement is routed to.
+func (w *influxWriter) outputsFor(measurement string) []*sink {
+	names, ok := w.routes[measurement]
+	if !ok {
+		return w.outputs
+	}
+	var outputs []*sink
+	for _, o := range w.outputs {
+		for _, name := range names {
+			if o.Name() == name {
+				outputs = append(outputs, o)
+				break
+			}
+		}
+	}
+	return outputs
+}
+
+// Writes returns the number of points written (or queued for batched writing, or skipped
+// as duplicates) successfully, counting each output separately.
+func (w *influxWriter) Writes() int {
+	return w.writes
+}
+
+// Failures returns the number of points that failed to write, counting each output
+// separately. With batched writes, each failed batch counts as one failure; failures are
+// only fully known after Close.
+func (w *influxWriter) Failures() int {
+	n := w.failures
+	for _, t := range w.targets {
+		n += int(t.asyncFailures.Load())
+	}
+	return n
+}
+
+// DisabledOutputs returns the names of the outputs disabled after repeated failures.
+func (w *influxWriter) DisabledOutputs() []string {
+	var names []string
+	for _, o := range w.outputs {
+		if o.disabled {
+			names = append(names, o.Name())
+		}
+	}
+	return names
+}
+
+// preparePoint applies the duplicate policy's tags and the configured field types to a
+// point and, if the field type guard is enabled, checks its field types.
+func (w *influxWriter) preparePoint(measurement string, tags map[string]string, fields map[string]interface{}) error {
+	if w.policy == DuplicatePolicyRunID {
+		tags[runIDTag] = w.runID
+	}
+
+	for k, t := range w.fieldTypes {
+		v, ok := fields[k]
+		if !ok {
+			continue
+		}
+		coerced, err := CoerceField(v, t)
+		if err != nil {
+			return fmt.Errorf("failed to coerce field '%s' to %s: %w", k, t, err)
+		}
+		fields[k] = coerced
+	}
+	if w.typeGuard != nil {
+		if err := w.typeGuard.CheckFieldTypes(measurement, fields); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// pointExists queries Influx for any point in the given series at exactly the given timestamp.
+func (t *influxTarget) pointExists(ctx context.Context, measurement string, tags map[string]string, ts time.Time) (bool, error) {
+	tagKeys := sortedKeys(tags)
+
+	var q strings.Builder
+	fmt.Fprintf(&q, "from(bucket: %q)\n", t.bucket)
+	fmt.Fprintf(&q, "  |> range(start: %s, stop: %s)\n",
+		ts.UTC().Format(time.RFC3339Nano), ts.Add(time.Second).UTC().Format(time.RFC3339Nano))
+	fmt.Fprintf(&q, "  |> filter(fn: (r) => r._measurement == %q)\n", measurement)
+	for _, k := range tagKeys {
+		fmt.Fprintf(&q, "  |> filter(fn: (r) => r[%q] == %q)\n", k, tags[k])
+	}
+	q.WriteString("  |> limit(n: 1)\n")
+
+	result, err := t.queryAPI.Query(ctx, q.String())
+	if err != nil {
+		return false, err
+	}
+	defer result.Close()
+	exists := result.Next()
+	return exists, result.Err()
+}
+
+// aggregateHourlyTotal is the QueryAggregate function that totals a field holding the
+// accumulation over the past hour (e.g. precip_1h_mm), by summing its hourly means.
+const aggregateHourlyTotal = "hourly_total"
+
+// QueryAggregate runs the given Flux aggregate function (e.g. "min", "max", "mean", or
+// aggregateHourlyTotal) over a single field for the given time range, using only points
+// with the given tags whose data_source tag is one of sources, so other locations',
+// comparison providers', and other sources' points aren't mixed in.
+// The boolean return value is false if no data was found in the range.
+func (w *influxWriter) QueryAggregate(ctx context.Context, measurement, field, fn string, sources []string, tags map[string]string, start, stop time.Time) (float64, bool, error) {
+	if w.queryAPI == nil {
+		return 0, false, errNoQueryTarget
+	}
+	result, err := w.queryAPI.Query(ctx, aggregateQuery(w.bucket, measurement, field, fn, sources, tags, start, stop))
+	if err != nil {
+		return 0, false, err
+	}
+	defer result.Close()
+	if !result.Next() {
+		return 0, false, result.Err()
+	}
+	switch v := result.Record().Value().(type) {
+	case float64:
+		return v, true, nil
+	case int64:
+		return float64(v), true, nil
+	default:
+		return 0, false, fmt.Errorf("unexpected value type %T for %s.%s", v, measurement, field)
+	}
+}
+
+// aggregateQuery returns the Flux query run by QueryAggregate.
+func aggregateQuery(bucket, measurement, field, fn string, sources []string, tags map[string]string, start, stop time.Time) string {
+	quoted := make([]string, len(sources))
+	for i, s := range sources {
+		quoted[i] = fmt.Sprintf("%q", s)
+	}
+	tagKeys := sortedKeys(tags)
+
+	var q strings.Builder
+	fmt.Fprintf(&q, "from(bucket: %q)\n", bucket)
+	fmt.Fprintf(&q, "  |> range(start: %s, stop: %s)\n", start.UTC().Format(time.RFC3339), stop.UTC().Format(time.RFC3339))
+	fmt.Fprintf(&q, "  |> filter(fn: (r) => r._measurement == %q and r._field == %q)\n", measurement, field)
+	fmt.Fprintf(&q, "  |> filter(fn: (r) => contains(value: r[%q], set: [%s]))\n", sourceTag, strings.Join(quoted, ", "))
+	for _, k := range tagKeys {
+		fmt.Fprintf(&q, "  |> filter(fn: (r) => r[%q] == %q)\n", k, tags[k])
+	}
+	q.WriteString("  |> group()\n")
+	if fn == aggregateHourlyTotal {
+		q.WriteString("  |> aggregateWindow(every: 1h, fn: mean, createEmpty: false)\n")
+		fn = "sum"
+	}
+	fmt.Fprintf(&q, "  |> %s()\n", fn)
+	return q.String()
+}
+
+// copyTags returns a copy of the given tag set, so a shared tag set can be passed to
+// WritePoint (which may add tags) for multiple points.
+func copyTags(tags map[string]string) map[string]string {
+	c := make(map[string]string, len(tags))
+	for k, v := range tags {
+		c[k] = v
+	}
+	return c
+}
+
+// copyFields returns a copy of the given field set.
+func copyFields(fields map[string]interface{}) map[string]interface{} {
+	c := make(map[string]interface{}, len(fields))
+	for k, v := range fields {
+		c[k] = v
+	}
+	return c
+}
+
+// QueryFloatValues runs the given Flux query and returns every numeric _value in the result.
+func (w *influxWriter) QueryFloatValues(ctx context.Context, query string) ([]float64, error) {
+	if w.queryAPI == nil {
+		return nil, errNoQueryTarget
+	}
+	result, err := w.queryAPI.Query(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer result.Close()
+
+	var values []float64
+	for result.Next() {
+		switch v := result.Record().Value().(type) {
+		case float64:
+			values = append(values, v)
+		case int64:
+			values = append(values, float64(v))
+		}
+	}
+	return values, result.Err()
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+const (
+	defaultLeaderLockTimeout         = 15 * time.Minute
+	defaultLeaderLockMeasurementName = "connector_leader"
+)
+
+// LeaderLockConfig describes the configuration for coordinating redundant instances via a
+// heartbeat in Influx, so that only one instance writes data at a time.
+type LeaderLockConfig struct {
+	NodeID          string `json:"node_id,omitempty"`
+	Timeout         string `json:"timeout,omitempty"`
+	MeasurementName string `json:"measurement_name,omitempty"`
+
+	timeout time.Duration
+}
+
+// Validate checks the leader lock configuration, filling in the default node ID (the hostname).
+func (c *LeaderLockConfig) Validate() error {
+	c.timeout = defaultLeaderLockTimeout
+	if c.Timeout != "" {
+		d, err := time.ParseDuration(c.Timeout)
+		if err != nil || d <= 0 {
+			return fmt.Errorf("timeout must be a positive duration (e.g. '15m'); got '%s'", c.Timeout)
+		}
+		c.timeout = d
+	}
+	if c.NodeID == "" {
+		h, err := os.Hostname()
+		if err != nil {
+			return fmt.Errorf("node_id is not set and the hostname is unavailable: %w", err)
+		}
+		c.NodeID = h
+	}
+	return nil
+}
+
+// Measurement returns the configured measurement name, or the default "connector_leader".
+func (c LeaderLockConfig) Measurement() string {
+	if c.MeasurementName == "" {
+		return defaultLeaderLockMeasurementName
+	}
+	return c.MeasurementName
+}
+
+// Acquire determines whether this node is the leader: it is, unless another node has
+// written a heartbeat within the timeout. If this node is the leader, it writes a heartbeat.
+// The returned string is the current leader's node ID.
+func (c LeaderLockConfig) Acquire(config Config, w *influxWriter, now time.Time) (bool, string, error) {
+	tags := config.coordTags()
+
+	q := fmt.Sprintf(`from(bucket: %q)
+  |> range(start: %s)
+  |> filter(fn: (r) => r._measurement == %q and r._field == "node")
+  |> filter(fn: (r) => r[%q] == %q and r[%q] == %q)
+  |> group()
+  |> last()
+`, w.bucket, now.Add(-c.timeout).UTC().Format(time.RFC3339), c.Measurement(), latTag, tags[latTag], lonTag, tags[lonTag])
+
+	if w.queryAPI == nil {
+		return false, "", errNoQueryTarget
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), influxTimeout)
+	defer cancel()
+	result, err := w.queryAPI.Query(ctx, q)
+	if err != nil {
+		return false, "", fmt.Errorf("failed to query leader heartbeat: %w", err)
+	}
+	defer result.Close()
+	if result.Next() {
+		if leader, ok := result.Record().Value().(string); ok && leader != c.NodeID {
+			return false, leader, nil
+		}
+	} else if result.Err() != nil {
+		return false, "", fmt.Errorf("failed to query leader heartbeat: %w", result.Err())
+	}
+
+	fields := map[string]interface{}{"node": c.NodeID}
+	if config.ValidateOutput {
+		if err := ValidateFields(schemaLeader, fields); err != nil {
+			return false, "", err
+		}
+	}
+	if err := w.WritePoint(c.Measurement(), tags, fields, now); err != nil {
+		return false, "", errors.Join(errors.New("failed to write leader heartbeat"), err)
+	}
+	return true, c.NodeID, nil
+}
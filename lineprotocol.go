@@ -0,0 +1,51 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"sync"
+	"time"
+
+	influxdb2 "github.com/influxdata/influxdb-client-go/v2"
+	"github.com/influxdata/influxdb-client-go/v2/api/write"
+)
+
+// lineProtocolOutputName is the name of the output used with -lineProtocol.
+const lineProtocolOutputName = "line_protocol"
+
+// lineProtocolOutput writes points in InfluxDB line protocol, with nanosecond timestamps,
+// to a writer (normally stdout, for use as a Telegraf exec input).
+type lineProtocolOutput struct {
+	mu sync.Mutex
+	w  *bufio.Writer
+}
+
+// newLineProtocolOutput returns a line protocol output writing to w.
+func newLineProtocolOutput(w io.Writer) *lineProtocolOutput {
+	return &lineProtocolOutput{w: bufio.NewWriter(w)}
+}
+
+// Name returns the output's name, for output routing.
+func (o *lineProtocolOutput) Name() string {
+	return lineProtocolOutputName
+}
+
+// WritePoint writes a single point as one line of line protocol. Each line is flushed
+// as it's written, so points written before a fatal error aren't lost.
+func (o *lineProtocolOutput) WritePoint(_ context.Context, measurement string, tags map[string]string, fields map[string]interface{}, ts time.Time) error {
+	line := write.PointToLineProtocol(influxdb2.NewPoint(measurement, tags, fields, ts), time.Nanosecond)
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if _, err := o.w.WriteString(line); err != nil {
+		return err
+	}
+	return o.w.Flush()
+}
+
+// Close flushes any buffered lines.
+func (o *lineProtocolOutput) Close() {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	_ = o.w.Flush()
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+const (
+	defaultLocationLatField  = "lat"
+	defaultLocationLonField  = "lon"
+	defaultLocationTimeField = "tst"
+)
+
+// DynamicLocationConfig describes where to get the current location from, for a
+// connector that moves around (e.g. in an RV or boat).
+type DynamicLocationConfig struct {
+	// URL returns the latest position as a JSON object, or an array whose first element is
+	// the latest position, like the OwnTracks Recorder's /api/0/last endpoint.
+	URL       string `json:"url"`
+	LatField  string `json:"lat_field,omitempty"`
+	LonField  string `json:"lon_field,omitempty"`
+	TimeField string `json:"time_field,omitempty"`
+	MaxAge    string `json:"max_age,omitempty"`
+}
+
+// Validate checks the dynamic location configuration.
+func (c DynamicLocationConfig) Validate() error {
+	if c.URL == "" {
+		return errors.New("url must be set")
+	}
+	if c.MaxAge != "" {
+		if d, err := time.ParseDuration(c.MaxAge); err != nil || d <= 0 {
+			return fmt.Errorf("max_age must be a positive duration (e.g. '6h'); got '%s'", c.MaxAge)
+		}
+	}
+	return nil
+}
+
+func (c DynamicLocationConfig) field(v, def string) string {
+	if v == "" {
+		return def
+	}
+	return v
+}
+
+// FetchLocation fetches the latest position. If max_age is set, a position reported
+// longer ago than that is an error.
+// See https://github.com/owntracks/recorder#api
+func (c DynamicLocationConfig) FetchLocation(now time.Time) (lat, lon float64, err error) {
+	resp, err := owmHTTPClient.Get(c.URL)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return 0, 0, fmt.Errorf("location URL returned %s", resp.Status)
+	}
+	var body interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return 0, 0, fmt.Errorf("failed to decode location response: %w", err)
+	}
+	if list, ok := body.([]interface{}); ok {
+		if len(list) == 0 {
+			return 0, 0, errors.New("location URL didn't return any positions")
+		}
+		body = list[0]
+	}
+	pos, ok := body.(map[string]interface{})
+	if !ok {
+		return 0, 0, errors.New("location response isn't a JSON object")
+	}
+
+	latField, lonField := c.field(c.LatField, defaultLocationLatField), c.field(c.LonField, defaultLocationLonField)
+	lat, latOK := pos[latField].(float64)
+	lon, lonOK := pos[lonField].(float64)
+	if !latOK || !lonOK {
+		return 0, 0, fmt.Errorf("location response doesn't have numeric '%s' and '%s' fields", latField, lonField)
+	}
+	if lat < -90 || lat > 90 || lon < -180 || lon > 180 {
+		return 0, 0, fmt.Errorf("location %f, %f is out of range", lat, lon)
+	}
+	if c.MaxAge != "" {
+		maxAge, _ := time.ParseDuration(c.MaxAge)
+		timeField := c.field(c.TimeField, defaultLocationTimeField)
+		tst, ok := pos[timeField].(float64)
+		if !ok {
+			return 0, 0, fmt.Errorf("location response doesn't have a numeric '%s' field, but max_age is set", timeField)
+		}
+		if age := now.Sub(time.Unix(int64(tst), 0)); age > maxAge {
+			return 0, 0, fmt.Errorf("latest position is %s old, older than max_age", age.Round(time.Second))
+		}
+	}
+	return lat, lon, nil
+}
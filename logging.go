@@ -0,0 +1,38 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+const (
+	logFormatText = "text"
+	logFormatJSON = "json"
+)
+
+// setupLogging configures the default slog logger (which the standard log package also
+// writes through) with the given minimum level and output format. Empty values select
+// the defaults: info level and text format.
+func setupLogging(level, format string) error {
+	var l slog.Level
+	if level != "" {
+		if err := l.UnmarshalText([]byte(level)); err != nil {
+			return fmt.Errorf("invalid log level '%s' (expected debug, info, warn, or error)", level)
+		}
+	}
+
+	opts := &slog.HandlerOptions{Level: l}
+	var h slog.Handler
+	switch strings.ToLower(format) {
+	case "", logFormatText:
+		h = slog.NewTextHandler(os.Stderr, opts)
+	case logFormatJSON:
+		h = slog.NewJSONHandler(os.Stderr, opts)
+	default:
+		return fmt.Errorf("invalid log format '%s' (expected '%s' or '%s')", format, logFormatText, logFormatJSON)
+	}
+	slog.SetDefault(slog.New(h))
+	return nil
+}
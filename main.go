@@ -1,318 +1,234 @@
 package main
 
 import (
-	"context"
 	"encoding/json"
 	"flag"
 	"fmt"
-	"log"
+	"log/slog"
 	"os"
-	"strconv"
+	"strings"
 	"time"
-
-	"github.com/avast/retry-go"
-	owm "github.com/briandowns/openweathermap"
-	"github.com/cdzombak/libwx"
-	influxdb2 "github.com/influxdata/influxdb-client-go/v2"
-	"github.com/mrflynn/go-aqi"
 )
 
 var version = "<dev>"
 
-const (
-	influxTimeout    = 3 * time.Second
-	influxAttempts   = 3
-	influxRetryDelay = 1 * time.Second
-
-	source                       = "openweathermap"
-	sourceTag                    = "data_source"
-	thermostatNameTag            = "thermostat_name"
-	latTag                       = "latitude"
-	lonTag                       = "longitude"
-	ecobeeWeatherMeasurementName = "ecobee_weather"
-)
-
-// Config describes the configuration for the openweather-influxdb-connector program.
-type Config struct {
-	APIKey                        string  `json:"api_key"`
-	Latitude                      float64 `json:"lat"`
-	Longitude                     float64 `json:"lon"`
-	InfluxServer                  string  `json:"influx_server"`
-	InfluxOrg                     string  `json:"influx_org,omitempty"`
-	InfluxUser                    string  `json:"influx_user,omitempty"`
-	InfluxPass                    string  `json:"influx_password,omitempty"`
-	InfluxToken                   string  `json:"influx_token,omitempty"`
-	InfluxBucket                  string  `json:"influx_bucket"`
-	InfluxHealthCheckDisabled     bool    `json:"influx_health_check_disabled"`
-	WeatherMeasurementName        string  `json:"wx_measurement_name"`
-	WriteEcobeeWeatherMeasurement bool    `json:"write_ecobee_weather_measurement"`
-	EcobeeThermostatName          string  `json:"ecobee_thermostat_name"`
-	PollutionMeasurementName      string  `json:"pollution_measurement_name"`
-}
-
 func main() {
-	configFile := flag.String("config", "./config.json", "Configuration JSON file.")
-	printData := flag.Bool("printData", false, "Print weather/pollution data to stdout.")
-	printVersion := flag.Bool("version", false, "Print version and exit.")
-	flag.Parse()
+	cmd, err := parseSubcommand()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n\n", err)
+		usage()
+		os.Exit(2)
+	}
+	flag.Usage = usage
+	opts := parseFlags(cmd)
+	if err := checkArgs(cmd, opts.args); err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n\n", err)
+		usage()
+		os.Exit(2)
+	}
 
-	if *printVersion {
+	if opts.printVersion || cmd == cmdVersion {
 		fmt.Println(version)
 		os.Exit(0)
 	}
 
-	if *configFile == "" {
-		fmt.Println("-config is required.")
-		os.Exit(1)
+	if cmd == cmdInit {
+		if err := runInit(opts.configFile, opts.importEcobeeConfig); err != nil {
+			fatalf("Failed to write config file: %s", err)
+		}
+		os.Exit(0)
 	}
 
-	config := Config{}
-	cfgBytes, err := os.ReadFile(*configFile)
-	if err != nil {
-		log.Fatalf("Unable to read config file '%s': %s", *configFile, err)
-	}
-	if err = json.Unmarshal(cfgBytes, &config); err != nil {
-		log.Fatalf("Unable to parse config file '%s': %s", *configFile, err)
-	}
-	if config.APIKey == "" {
-		log.Fatal("api_key must be set in the config file.")
-	}
-	if config.WeatherMeasurementName == "" {
-		log.Fatal("wx_measurement_name must be set in the config file.")
-	}
-	if config.WriteEcobeeWeatherMeasurement && config.EcobeeThermostatName == "" {
-		log.Fatal("ecobee_thermostat_name must be set in the config file if write_ecobee_wx_measurement is set.")
+	if cmd == cmdServiceShow || cmd == cmdServiceInstall {
+		if err := runService(cmd == cmdServiceInstall, opts.serviceType, opts.configFile, opts.serviceInterval); err != nil {
+			fatalf("Failed to generate service files: %s", err)
+		}
+		os.Exit(0)
 	}
 
-	authString := ""
-	if config.InfluxUser != "" || config.InfluxPass != "" {
-		authString = fmt.Sprintf("%s:%s", config.InfluxUser, config.InfluxPass)
-	} else if config.InfluxToken != "" {
-		authString = config.InfluxToken
-	}
-	influxClient := influxdb2.NewClient(config.InfluxServer, authString)
-	if !config.InfluxHealthCheckDisabled {
-		ctx, cancel := context.WithTimeout(context.Background(), influxTimeout)
-		defer cancel()
-		health, err := influxClient.Health(ctx)
+	if opts.importEcobeeConfig != "" {
+		imported, todo, err := ImportEcobeeConfig(opts.importEcobeeConfig)
 		if err != nil {
-			log.Fatalf("Failed to check InfluxDB health: %v", err)
+			fatalf("Unable to import ecobee_influx_connector config file '%s': %s", opts.importEcobeeConfig, err)
 		}
-		if health.Status != "pass" {
-			log.Fatalf("InfluxDB did not pass health check: status %s; message '%s'", health.Status, *health.Message)
+		b, err := json.MarshalIndent(imported, "", "  ")
+		if err != nil {
+			fatalf("Failed to encode config: %s", err)
 		}
+		fmt.Println(string(b))
+		fmt.Fprintf(os.Stderr, "Fill in these fields before using this config: %s\n", strings.Join(todo, ", "))
+		os.Exit(0)
 	}
-	influxWriteAPI := influxClient.WriteAPIBlocking(config.InfluxOrg, config.InfluxBucket)
 
-	configCoords := owm.Coordinates{
-		Longitude: config.Longitude,
-		Latitude:  config.Latitude,
+	printer, err := newPrintOutput(opts.printFormat, os.Stdout)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	if !opts.printData {
+		printer = nil
 	}
 
-	wx, err := owm.NewCurrent("F", "EN", config.APIKey)
-	if err != nil {
-		log.Fatalf("Failed to create OpenWeatherMap current weather client: %s", err)
+	if opts.lineProtocol && (opts.printData || opts.sendDigestEmail || cmd == cmdStats) {
+		fmt.Println("-lineProtocol can't be used with -printData, -sendDigest, or the stats subcommand.")
+		os.Exit(1)
 	}
 
-	if err := wx.CurrentByCoordinates(&configCoords); err != nil {
-		log.Fatalf("Failed to get weather from OpenWeatherMap: %s", err)
+	if opts.recordDir != "" && opts.replayDir != "" {
+		fmt.Println("-record and -replay can't be used together.")
+		os.Exit(1)
 	}
 
-	// see response docs at: https://openweathermap.org/current#parameter
-	weatherTime := time.Unix(int64(wx.Dt), 0)
-	outdoorTemp := libwx.TempF(wx.Main.Temp)
-	feelsLikeTemp := libwx.TempF(wx.Main.FeelsLike)
-	// nb. OpenWeatherMap reports pressure in hPa regardless of unit setting; hPa == millibar
-	pressureMillibar := libwx.PressureMb(wx.Main.Pressure)
-	outdoorHumidity := libwx.ClampedRelHumidity(wx.Main.Humidity) // int, in %
-	dewpoint := libwx.DewPointF(outdoorTemp, outdoorHumidity)
-	windSpeedMph := libwx.SpeedMph(wx.Wind.Speed)
-	windBearing := wx.Wind.Deg
-	visibilityMeters := libwx.Meter(wx.Visibility)
-	visibilityMiles := visibilityMeters.Miles()
-	cloudsPercent := wx.Clouds.All
-	// TODO(cdzombak): record weather condition codes from wx.Weather
-	//                 see https://openweathermap.org/weather-conditions#Weather-Condition-Codes-2
+	if opts.configFile == "" {
+		fmt.Println("-config is required.")
+		os.Exit(1)
+	}
 
-	if *printData {
-		fmt.Printf("Conditions at %s:\n", weatherTime)
-		fmt.Printf("\ttemperature: %.1f degF\n\tpressure: %.0f mb\n\thumidity: %d%%\n\tdew point: %.1f degF\n\twind: %.0f at %.1f mph\n\tvisibility: %.1f miles\n\tcloud cover: %d%%",
-			outdoorTemp, pressureMillibar, outdoorHumidity, dewpoint, windBearing, windSpeedMph, visibilityMiles, cloudsPercent)
+	config := loadConfig(opts.configFile)
+	setup(config, opts)
+	if cmd == cmdRun && !opts.sendDigestEmail && opts.replayDir == "" {
+		heartbeatURL = config.HeartbeatURL
+		heartbeatFailURL = config.HeartbeatFailURL
 	}
+	config.validate(opts)
 
-	heatIdxF, heatIdxFErr := libwx.HeatIndexFWithValidation(outdoorTemp, outdoorHumidity)
-	heatIdxC, heatIdxCErr := libwx.HeatIndexCWithValidation(outdoorTemp.C(), outdoorHumidity)
-	windChillF, windChillFErr := libwx.WindChillFWithValidation(outdoorTemp, windSpeedMph)
-	windChillC, windChillCErr := libwx.WindChillCWithValidation(outdoorTemp.C(), windSpeedMph)
-	wetBulbTempF, wetBulbTempFErr := libwx.WetBulbF(outdoorTemp, outdoorHumidity)
-	wetBulbTempC, wetBulbTempCErr := libwx.WetBulbC(outdoorTemp.C(), outdoorHumidity)
+	if cmd == cmdValidate {
+		fmt.Printf("%s is valid.\n", opts.configFile)
+		os.Exit(0)
+	}
 
-	if config.WriteEcobeeWeatherMeasurement {
-		if err := retry.Do(func() error {
-			ctx, cancel := context.WithTimeout(context.Background(), influxTimeout)
-			defer cancel()
-			err := influxWriteAPI.WritePoint(ctx,
-				influxdb2.NewPoint(
-					ecobeeWeatherMeasurementName,
-					map[string]string{
-						thermostatNameTag: config.EcobeeThermostatName,
-						sourceTag:         source,
-					},
-					map[string]interface{}{
-						"outdoor_temp":                    outdoorTemp.Unwrap(),
-						"outdoor_humidity":                outdoorHumidity.Unwrap(),
-						"barometric_pressure_mb":          pressureMillibar.Unwrap(),
-						"barometric_pressure_inHg":        pressureMillibar.InHg().Unwrap(),
-						"dew_point":                       dewpoint.Unwrap(),
-						"wind_speed":                      windSpeedMph.Unwrap(),
-						"wind_bearing":                    windBearing,
-						"visibility_mi":                   visibilityMiles.Unwrap(),
-						"recommended_max_indoor_humidity": libwx.IndoorHumidityRecommendationF(outdoorTemp).Unwrap(),
-						"wind_chill_f":                    windChillF.Unwrap(),
-					},
-					weatherTime,
-				))
-			if err != nil {
-				return err
-			}
-			return nil
-		}, retry.Attempts(influxAttempts), retry.Delay(influxRetryDelay)); err != nil {
-			log.Printf("Failed to write %s to influx: %s", ecobeeWeatherMeasurementName, err)
+	if config.DynamicLocation != nil {
+		// nb. the configured lat/lon are the fallback if the current position is unavailable
+		if lat, lon, err := config.DynamicLocation.FetchLocation(now()); err != nil {
+			slog.Warn("Failed to get current location; using the configured location", "lat", config.Latitude, "lon", config.Longitude, "error", err)
+		} else {
+			slog.Debug("Using current location", "lat", lat, "lon", lon)
+			config.Latitude, config.Longitude = lat, lon
 		}
 	}
 
-	if err := retry.Do(func() error {
-		ctx, cancel := context.WithTimeout(context.Background(), influxTimeout)
-		defer cancel()
-		fields := map[string]interface{}{
-			"temp_f":                          outdoorTemp.Unwrap(),
-			"temp_c":                          outdoorTemp.C().Unwrap(),
-			"rel_humidity":                    outdoorHumidity.Unwrap(),
-			"feels_like_f":                    feelsLikeTemp.Unwrap(),
-			"feels_like_c":                    feelsLikeTemp.C().Unwrap(),
-			"barometric_pressure_mb":          pressureMillibar.Unwrap(),
-			"barometric_pressure_inHg":        pressureMillibar.InHg().Unwrap(),
-			"dew_point_f":                     dewpoint.Unwrap(),
-			"dew_point_c":                     dewpoint.C().Unwrap(),
-			"wind_speed_mph":                  windSpeedMph.Unwrap(),
-			"wind_speed_kt":                   windSpeedMph.Knots().Unwrap(),
-			"wind_bearing":                    windBearing,
-			"visibility_mi":                   visibilityMiles.Unwrap(),
-			"recommended_max_indoor_humidity": libwx.IndoorHumidityRecommendationF(outdoorTemp).Unwrap(),
-			"cloud_cover":                     cloudsPercent,
+	state := &State{}
+	if config.StateDir != "" {
+		if state, err = LoadState(config.StateDir); err != nil {
+			fatalf("Unable to load state from '%s': %s", config.StateDir, err)
 		}
+	}
 
-		if heatIdxFErr == nil {
-			fields["heat_index_f"] = heatIdxF.Unwrap()
-		}
-		if heatIdxCErr == nil {
-			fields["heat_index_c"] = heatIdxC.Unwrap()
+	var influxTargets []*influxTarget
+	var outputs []pointOutput
+	if opts.lineProtocol {
+		// nb. with -lineProtocol, points are only written to stdout, regardless of routing
+		outputs = append(outputs, newLineProtocolOutput(os.Stdout))
+		config.OutputRoutes = nil
+	} else if opts.dryRun() {
+		outputs = append(outputs, discardOutput{})
+		config.OutputRoutes = nil
+	} else {
+		if influxTargets, err = newInfluxTargets(config); err != nil {
+			fatal(err)
 		}
-		if windChillFErr == nil {
-			fields["wind_chill_f"] = windChillF.Unwrap()
+		outputs = newOutputs(config)
+	}
+	influxWriter := newInfluxWriter(influxTargets, outputs, config, state)
+	influxWriter.printer = printer
+	closeOnFatal = influxWriter.Close
+
+	if opts.sendDigestEmail {
+		if err := sendDigest(config, influxWriter, config.coords()); err != nil {
+			fatalf("Failed to send digest e-mail: %s", err)
 		}
-		if windChillCErr == nil {
-			fields["wind_chill_c"] = windChillC.Unwrap()
+		os.Exit(0)
+	}
+
+	if cmd == cmdStats {
+		if err := runStats(config, influxWriter, opts.args[0]); err != nil {
+			fatalf("Failed to compute stats: %s", err)
 		}
-		if wetBulbTempFErr == nil {
-			fields["wet_bulb_f"] = wetBulbTempF.Unwrap()
+		influxWriter.Close()
+		os.Exit(0)
+	}
+
+	if cmd == cmdImport {
+		err := runImport(config, influxWriter, opts.bulkUnits, opts.args)
+		influxWriter.Close()
+		if err != nil {
+			fatalf("Failed to import bulk history: %s", err)
 		}
-		if wetBulbTempCErr == nil {
-			fields["wet_bulb_c"] = wetBulbTempC.Unwrap()
+		os.Exit(0)
+	}
+
+	if cmd == cmdBackfill {
+		err := runBackfill(config, influxWriter, opts.backfillFrom, opts.backfillTo)
+		influxWriter.Close()
+		if err != nil {
+			fatalf("Failed to backfill history: %s", err)
 		}
+		os.Exit(0)
+	}
 
-		err := influxWriteAPI.WritePoint(ctx,
-			influxdb2.NewPoint(
-				config.WeatherMeasurementName,
-				map[string]string{
-					sourceTag: source,
-					latTag:    strconv.FormatFloat(config.Latitude, 'f', 3, 64),
-					lonTag:    strconv.FormatFloat(config.Longitude, 'f', 3, 64),
-				},
-				fields,
-				weatherTime,
-			))
+	if config.LeaderLock != nil && opts.sideEffects() {
+		isLeader, leader, err := config.LeaderLock.Acquire(config, influxWriter, now())
 		if err != nil {
-			return err
+			fatalf("Failed to acquire leader lock: %s", err)
+		}
+		if !isLeader {
+			slog.Info("Another instance is the active leader; not writing data", "leader", leader, "node", config.LeaderLock.NodeID)
+			pingHeartbeat(false, fmt.Sprintf("standby; %s is the active leader", leader))
+			os.Exit(0)
 		}
-		return nil
-	}, retry.Attempts(influxAttempts), retry.Delay(influxRetryDelay)); err != nil {
-		log.Printf("Failed to write %s to influx: %s", config.WeatherMeasurementName, err)
 	}
 
-	// Pollution: https://openweathermap.org/api/air-pollution
-	polResp, err := owm.NewPollution(config.APIKey)
-	if err != nil {
-		log.Fatalf("Failed to create OpenWeatherMap pollution client: %s", err)
+	r := &run{
+		config:       config,
+		opts:         opts,
+		state:        state,
+		writer:       influxWriter,
+		printReports: opts.printData && printer == nil,
 	}
-	if err := polResp.PollutionByParams(&owm.PollutionParameters{
-		Location: configCoords,
-		Datetime: "current", // unused internally by the library but it appears in the example code, so ...
-	}); err != nil {
-		log.Fatalf("Failed to get pollution from OpenWeatherMap: %s", err)
+	r.run()
+}
+
+// setup configures logging, the clock, the OpenWeatherMap client, and response
+// recording, replay, and debugging per the config and command-line options.
+func setup(config Config, opts options) {
+	logLevel, logFormat := opts.logLevel, opts.logFormat
+	if logLevel == "" {
+		logLevel = config.LogLevel
 	}
-	if len(polResp.List) == 0 {
-		log.Fatal("OpenWeatherMap didn't return any pollution information")
+	if logFormat == "" {
+		logFormat = config.LogFormat
 	}
-	polData := polResp.List[0]
-
-	aqiUsParticulates, err := aqi.Calculate(
-		aqi.PM25{Concentration: polData.Components.Pm25},
-		aqi.PM10{Concentration: polData.Components.Pm10},
-	)
-	if err != nil {
-		log.Fatalf("Failed to calculate US AQI for particulates: %s", err)
+	if opts.debug {
+		logLevel = "debug"
 	}
-	aqiUs, err := aqi.Calculate(
-		aqi.PM25{Concentration: polData.Components.Pm25},
-		aqi.PM10{Concentration: polData.Components.Pm10},
-		aqi.CO{Concentration: polData.Components.Co},
-		aqi.NO2{Concentration: polData.Components.No2},
-		aqi.SO2{Concentration: polData.Components.So2},
-	)
-	if err != nil {
-		log.Fatalf("Failed to calculate overall US AQI: %s", err)
+	if err := setupLogging(logLevel, logFormat); err != nil {
+		fatal(err)
 	}
-
-	if *printData {
-		fmt.Printf("Pollution at %s:\n", weatherTime)
-		fmt.Printf("\tAQI (US EPA): %.1f\n\tAQI (US EPA, particulates): %.1f\n\tCO: %.2f\n\tNO: %.2f\n\tNO2: %.2f\n\tO3: %.2f\n\tSO2: %.2f\n\tPM2.5: %.2f\n\tPM10: %.2f\n\tNH3: %.2f\n",
-			aqiUs.AQI, aqiUsParticulates.AQI, polData.Components.Co, polData.Components.No, polData.Components.No2, polData.Components.O3, polData.Components.So2, polData.Components.Pm25, polData.Components.Pm10, polData.Components.Nh3)
+	if err := setupClock(config.Timezone, opts.fakeNow); err != nil {
+		fatal(err)
 	}
-
-	if err := retry.Do(func() error {
-		ctx, cancel := context.WithTimeout(context.Background(), influxTimeout)
-		defer cancel()
-		err := influxWriteAPI.WritePoint(ctx,
-			influxdb2.NewPoint(
-				config.PollutionMeasurementName,
-				map[string]string{
-					sourceTag: source,
-					latTag:    strconv.FormatFloat(config.Latitude, 'f', 3, 64),
-					lonTag:    strconv.FormatFloat(config.Longitude, 'f', 3, 64),
-				},
-				map[string]interface{}{
-					"aqi_1_5":        polData.Main.Aqi,
-					"aqi_us_pm":      aqiUsParticulates.AQI,
-					"aqi_us_pm_name": aqiUsParticulates.Index.Name,
-					"aqi_us":         aqiUs.AQI,
-					"aqi_us_name":    aqiUs.Index.Name,
-					"co":             polData.Components.Co,
-					"no":             polData.Components.No,
-					"no2":            polData.Components.No2,
-					"o3":             polData.Components.O3,
-					"so2":            polData.Components.So2,
-					"pm25":           polData.Components.Pm25,
-					"pm10":           polData.Components.Pm10,
-					"nh3":            polData.Components.Nh3,
-				},
-				time.Unix(int64(polData.Dt), 0),
-			))
+	if err := configureOWMClient(config); err != nil {
+		fatal(err)
+	}
+	if opts.replayDir != "" {
+		runTime, err := enableReplay(opts.replayDir)
+		if err != nil {
+			fatalf("Failed to load recorded responses from '%s': %s", opts.replayDir, err)
+		}
+		if opts.fakeNow == "" {
+			nowFunc = func() time.Time { return runTime }
+		}
+		slog.Info("Replaying recorded responses", "dir", opts.replayDir, "time", runTime)
+	} else if opts.recordDir != "" {
+		runDir, err := enableRecording(opts.recordDir, now())
 		if err != nil {
-			return err
+			fatalf("Failed to set up response recording in '%s': %s", opts.recordDir, err)
+		}
+		slog.Info("Recording raw API responses", "dir", runDir)
+	}
+	if opts.debug {
+		if err := enableDebugResponses(opts.debugDir); err != nil {
+			fatalf("Failed to set up debug response logging: %s", err)
 		}
-		return nil
-	}, retry.Attempts(influxAttempts), retry.Delay(influxRetryDelay)); err != nil {
-		log.Printf("Failed to write %s to influx: %s", config.PollutionMeasurementName, err)
 	}
 }
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cdzombak/libwx"
+)
+
+const (
+	marineSource                 = "open-meteo"
+	defaultMarineMeasurementName = "marine"
+	feetPerMeter                 = 3.28084
+)
+
+// marineVariables lists the Open-Meteo Marine API current variables fetched.
+var marineVariables = []string{
+	"wave_height", "wave_direction", "wave_period",
+	"swell_wave_height", "swell_wave_direction", "swell_wave_period",
+	"sea_surface_temperature",
+}
+
+// MarineConfig describes the configuration for the marine measurement.
+type MarineConfig struct {
+	// Latitude and Longitude optionally override the configured location, e.g. to point
+	// just offshore.
+	Latitude        *float64 `json:"lat,omitempty"`
+	Longitude       *float64 `json:"lon,omitempty"`
+	MeasurementName string   `json:"measurement_name,omitempty"`
+}
+
+// Validate checks the marine configuration.
+func (c MarineConfig) Validate() error {
+	if (c.Latitude == nil) != (c.Longitude == nil) {
+		return errors.New("lat and lon must be set together")
+	}
+	if c.Latitude != nil && (*c.Latitude < -90 || *c.Latitude > 90 || *c.Longitude < -180 || *c.Longitude > 180) {
+		return errors.New("lat must be between -90 and 90, and lon between -180 and 180")
+	}
+	return nil
+}
+
+// Location returns the location to fetch marine conditions for, given the configured
+// location.
+func (c MarineConfig) Location(lat, lon float64) (float64, float64) {
+	if c.Latitude != nil {
+		return *c.Latitude, *c.Longitude
+	}
+	return lat, lon
+}
+
+// Measurement returns the configured measurement name, or the default "marine".
+func (c MarineConfig) Measurement() string {
+	if c.MeasurementName == "" {
+		return defaultMarineMeasurementName
+	}
+	return c.MeasurementName
+}
+
+// MarineConditions are current sea conditions. Fields contains wave and swell heights
+// (m and ft), periods (s), and directions (degrees), and the sea surface temperature.
+type MarineConditions struct {
+	Time   time.Time
+	Fields map[string]interface{}
+}
+
+// FetchMarine fetches current sea conditions for the given location from the Open-Meteo
+// Marine API. The API has no data for locations away from the ocean; an error is returned
+// in that case.
+// See https://open-meteo.com/en/docs/marine-weather-api
+func FetchMarine(lat, lon float64) (*MarineConditions, error) {
+	q := url.Values{}
+	q.Set("latitude", strconv.FormatFloat(lat, 'f', 4, 64))
+	q.Set("longitude", strconv.FormatFloat(lon, 'f', 4, 64))
+	q.Set("current", strings.Join(marineVariables, ","))
+	q.Set("timeformat", "unixtime")
+	resp, err := owmHTTPClient.Get("https://marine-api.open-meteo.com/v1/marine?" + q.Encode())
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Open-Meteo Marine API returned %s", resp.Status)
+	}
+	var body struct {
+		Current map[string]*float64 `json:"current"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("failed to decode Open-Meteo Marine API response: %w", err)
+	}
+
+	cur := body.Current
+	m := &MarineConditions{Time: time.Unix(int64(valueOrZero(cur["time"])), 0), Fields: make(map[string]interface{})}
+	for _, prefix := range []string{"wave", "swell_wave"} {
+		if v := cur[prefix+"_height"]; v != nil {
+			m.Fields[prefix+"_height_m"] = *v
+			m.Fields[prefix+"_height_ft"] = *v * feetPerMeter
+		}
+		if v := cur[prefix+"_period"]; v != nil {
+			m.Fields[prefix+"_period_s"] = *v
+		}
+		if v := cur[prefix+"_direction"]; v != nil {
+			m.Fields[prefix+"_direction"] = *v
+		}
+	}
+	if v := cur["sea_surface_temperature"]; v != nil {
+		m.Fields["sea_surface_temp_c"] = *v
+		m.Fields["sea_surface_temp_f"] = libwx.TempC(*v).F().Unwrap()
+	}
+	if len(m.Fields) == 0 {
+		return nil, fmt.Errorf("Open-Meteo has no marine data for %.4f, %.4f; is the location near the ocean?", lat, lon)
+	}
+	return m, nil
+}
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cdzombak/libwx"
+	"github.com/cdzombak/openweather-influxdb-connector/pkg/owmconnector"
+)
+
+const (
+	metarSource                 = "metar"
+	metarStationTag             = "station"
+	defaultMETARMeasurementName = "metar"
+)
+
+// Flight categories, as defined by the FAA.
+const (
+	FlightCategoryVFR  = "VFR"
+	FlightCategoryMVFR = "MVFR"
+	FlightCategoryIFR  = "IFR"
+	FlightCategoryLIFR = "LIFR"
+)
+
+var icaoStationRegexp = regexp.MustCompile(`^[A-Z0-9]{4}$`)
+
+// METARConfig describes the configuration for the METAR measurement.
+type METARConfig struct {
+	Station         string `json:"station"`
+	MeasurementName string `json:"measurement_name,omitempty"`
+}
+
+// Validate checks the METAR configuration.
+func (c METARConfig) Validate() error {
+	if !icaoStationRegexp.MatchString(c.Station) {
+		return errors.New("station must be a 4-character ICAO station identifier (e.g. 'KARB')")
+	}
+	return nil
+}
+
+// Measurement returns the configured measurement name, or the default "metar".
+func (c METARConfig) Measurement() string {
+	if c.MeasurementName == "" {
+		return defaultMETARMeasurementName
+	}
+	return c.MeasurementName
+}
+
+// METAR is a decoded METAR report.
+type METAR struct {
+	Station string
+	Time    time.Time
+	Raw     string
+	// VisibilityMiles is the prevailing visibility, in statute miles. Visibilities reported as
+	// "10+" or "P6SM" are written as 10 and 6, respectively.
+	VisibilityMiles *float64
+	// CeilingFt is the height (ft AGL) of the lowest broken, overcast, or obscured layer, or
+	// nil if there's no ceiling.
+	CeilingFt *int
+	TempC     *float64
+	DewPointC *float64
+	// WindBearing is nil if the wind is calm or variable.
+	WindBearing *float64
+	WindSpeedKt *float64
+	WindGustKt  *float64
+	AltimeterMb *float64
+	Weather     string
+}
+
+// FlightCategory returns the report's flight category, or "" if the report doesn't
+// include visibility.
+func (m *METAR) FlightCategory() string {
+	if m.VisibilityMiles == nil {
+		return ""
+	}
+	vis := *m.VisibilityMiles
+	ceil := 1 << 30
+	if m.CeilingFt != nil {
+		ceil = *m.CeilingFt
+	}
+	switch {
+	case ceil < 500 || vis < 1:
+		return FlightCategoryLIFR
+	case ceil < 1000 || vis < 3:
+		return FlightCategoryIFR
+	case ceil <= 3000 || vis <= 5:
+		return FlightCategoryMVFR
+	default:
+		return FlightCategoryVFR
+	}
+}
+
+// Fields returns the report's fields, as written to the METAR measurement.
+func (m *METAR) Fields() map[string]interface{} {
+	fields := map[string]interface{}{
+		"raw_metar": m.Raw,
+	}
+	if m.VisibilityMiles != nil {
+		fields["visibility_mi"] = *m.VisibilityMiles
+		fields["flight_category"] = m.FlightCategory()
+	}
+	if m.CeilingFt != nil {
+		fields["ceiling_ft"] = *m.CeilingFt
+	}
+	if m.TempC != nil {
+		fields["temp_c"] = *m.TempC
+		fields["temp_f"] = libwx.TempC(*m.TempC).F().Unwrap()
+	}
+	if m.DewPointC != nil {
+		fields["dew_point_c"] = *m.DewPointC
+		fields["dew_point_f"] = libwx.TempC(*m.DewPointC).F().Unwrap()
+	}
+	if m.WindBearing != nil {
+		fields["wind_bearing"] = *m.WindBearing
+	}
+	if m.WindSpeedKt != nil {
+		fields["wind_speed_kt"] = *m.WindSpeedKt
+	}
+	if m.WindGustKt != nil {
+		fields["wind_gust_kt"] = *m.WindGustKt
+	}
+	if m.AltimeterMb != nil {
+		fields["altimeter_mb"] = *m.AltimeterMb
+		fields["altimeter_inHg"] = libwx.PressureMb(*m.AltimeterMb).InHg().Unwrap()
+	}
+	if m.Weather != "" {
+		fields["wx_string"] = m.Weather
+	}
+	return fields
+}
+
+// FetchMETAR fetches the latest METAR for the configured station from aviationweather.gov.
+// See https://aviationweather.gov/data/api/
+func FetchMETAR(c METARConfig) (*METAR, error) {
+	q := url.Values{}
+	q.Set("ids", c.Station)
+	q.Set("format", "json")
+	req, err := http.NewRequest(http.MethodGet, "https://aviationweather.gov/api/data/metar?"+q.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", owmconnector.DefaultNWSUserAgent)
+	resp, err := owmHTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNoContent {
+		return nil, fmt.Errorf("aviationweather.gov has no recent METAR for %s", c.Station)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("aviationweather.gov returned %s", resp.Status)
+	}
+	return decodeMETAR(resp.Body, c.Station)
+}
+
+// decodeMETAR decodes the latest METAR from an aviationweather.gov METAR API response
+// (in JSON format) for the given station.
+func decodeMETAR(r io.Reader, station string) (*METAR, error) {
+	var body []struct {
+		IcaoID   string      `json:"icaoId"`
+		ObsTime  int64       `json:"obsTime"`
+		RawOb    string      `json:"rawOb"`
+		Temp     *float64    `json:"temp"`
+		Dewp     *float64    `json:"dewp"`
+		Wdir     interface{} `json:"wdir"`
+		Wspd     *float64    `json:"wspd"`
+		Wgst     *float64    `json:"wgst"`
+		Visib    interface{} `json:"visib"`
+		Altim    *float64    `json:"altim"`
+		WxString *string     `json:"wxString"`
+		Clouds   []struct {
+			Cover string `json:"cover"`
+			Base  *int   `json:"base"`
+		} `json:"clouds"`
+	}
+	if err := json.NewDecoder(r).Decode(&body); err != nil {
+		return nil, fmt.Errorf("failed to decode aviationweather.gov response: %w", err)
+	}
+	if len(body) == 0 {
+		return nil, fmt.Errorf("aviationweather.gov has no recent METAR for %s", station)
+	}
+
+	ob := body[0]
+	m := &METAR{
+		Station:     ob.IcaoID,
+		Time:        time.Unix(ob.ObsTime, 0),
+		Raw:         ob.RawOb,
+		TempC:       ob.Temp,
+		DewPointC:   ob.Dewp,
+		WindSpeedKt: ob.Wspd,
+		WindGustKt:  ob.Wgst,
+		AltimeterMb: ob.Altim,
+	}
+	if ob.WxString != nil {
+		m.Weather = *ob.WxString
+	}
+	// nb. wdir is "VRB" for variable winds
+	if wdir, ok := ob.Wdir.(float64); ok && (m.WindSpeedKt == nil || *m.WindSpeedKt > 0) {
+		m.WindBearing = &wdir
+	}
+	switch v := ob.Visib.(type) {
+	case float64:
+		m.VisibilityMiles = &v
+	case string:
+		v = strings.TrimSuffix(strings.TrimSuffix(v, "SM"), "+")
+		if vis, err := strconv.ParseFloat(strings.TrimPrefix(v, "P"), 64); err == nil {
+			m.VisibilityMiles = &vis
+		}
+	}
+	for _, layer := range ob.Clouds {
+		switch layer.Cover {
+		case "BKN", "OVC", "OVX", "VV":
+			if layer.Base != nil && (m.CeilingFt == nil || *layer.Base < *m.CeilingFt) {
+				base := *layer.Base
+				m.CeilingFt = &base
+			}
+		}
+	}
+	return m, nil
+}
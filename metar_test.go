@@ -0,0 +1,158 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDecodeMETAR(t *testing.T) {
+	tests := []struct {
+		name         string
+		fixture      string
+		wantBearing  *float64
+		wantVis      *float64
+		wantCeiling  *int
+		wantCategory string
+	}{
+		{
+			name:         "variable wind",
+			fixture:      `[{"icaoId":"KARB","obsTime":1720094160,"rawOb":"METAR KARB 041156Z VRB03KT 10SM CLR 21/16 A3002","temp":21,"dewp":16,"wdir":"VRB","wspd":3,"visib":"10+","altim":1016.6,"clouds":[{"cover":"CLR","base":null}]}]`,
+			wantVis:      ptr(10.0),
+			wantCategory: FlightCategoryVFR,
+		},
+		{
+			name:         "calm wind",
+			fixture:      `[{"icaoId":"KARB","obsTime":1720094160,"rawOb":"METAR KARB 041156Z 00000KT 10SM SCT250 21/16 A3002","temp":21,"dewp":16,"wdir":0,"wspd":0,"visib":"10+","altim":1016.6,"clouds":[{"cover":"SCT","base":25000}]}]`,
+			wantVis:      ptr(10.0),
+			wantCategory: FlightCategoryVFR,
+		},
+		{
+			name:         "wind",
+			fixture:      `[{"icaoId":"KARB","obsTime":1720094160,"rawOb":"METAR KARB 041156Z 22012G20KT 10SM BKN035 21/16 A3002","temp":21,"dewp":16,"wdir":220,"wspd":12,"wgst":20,"visib":"10+","altim":1016.6,"clouds":[{"cover":"BKN","base":3500}]}]`,
+			wantBearing:  ptr(220.0),
+			wantVis:      ptr(10.0),
+			wantCeiling:  ptr(3500),
+			wantCategory: FlightCategoryVFR,
+		},
+		{
+			name:         "CAVOK",
+			fixture:      `[{"icaoId":"EGLL","obsTime":1720094400,"rawOb":"METAR EGLL 041200Z 24008KT CAVOK 22/12 Q1018","temp":22,"dewp":12,"wdir":240,"wspd":8,"visib":"6+","altim":1018,"clouds":[{"cover":"CAVOK"}]}]`,
+			wantBearing:  ptr(240.0),
+			wantVis:      ptr(6.0),
+			wantCategory: FlightCategoryVFR,
+		},
+		{
+			name:         "visibility with statute mile suffix",
+			fixture:      `[{"icaoId":"KARB","obsTime":1720094160,"rawOb":"METAR KARB 041156Z 22005KT P6SM FEW100 21/16 A3002","temp":21,"dewp":16,"wdir":220,"wspd":5,"visib":"P6SM","altim":1016.6,"clouds":[{"cover":"FEW","base":10000}]}]`,
+			wantBearing:  ptr(220.0),
+			wantVis:      ptr(6.0),
+			wantCategory: FlightCategoryVFR,
+		},
+		{
+			name:        "missing visibility",
+			fixture:     `[{"icaoId":"KARB","obsTime":1720094160,"rawOb":"METAR KARB 041156Z AUTO 22005KT OVC008 21/16 A3002","temp":21,"dewp":16,"wdir":220,"wspd":5,"visib":null,"altim":1016.6,"clouds":[{"cover":"OVC","base":800}]}]`,
+			wantBearing: ptr(220.0),
+			wantCeiling: ptr(800),
+		},
+		{
+			name:         "lowest ceiling layer",
+			fixture:      `[{"icaoId":"KARB","obsTime":1720094160,"rawOb":"METAR KARB 041156Z 22005KT 2SM BR SCT004 BKN009 OVC015 21/20 A3002","temp":21,"dewp":20,"wdir":220,"wspd":5,"visib":2,"altim":1016.6,"wxString":"BR","clouds":[{"cover":"SCT","base":400},{"cover":"OVC","base":1500},{"cover":"BKN","base":900}]}]`,
+			wantBearing:  ptr(220.0),
+			wantVis:      ptr(2.0),
+			wantCeiling:  ptr(900),
+			wantCategory: FlightCategoryIFR,
+		},
+		{
+			name:         "vertical visibility",
+			fixture:      `[{"icaoId":"KARB","obsTime":1720094160,"rawOb":"METAR KARB 041156Z 00000KT 1/4SM FG VV002 18/18 A3002","temp":18,"dewp":18,"wdir":0,"wspd":0,"visib":0.25,"altim":1016.6,"wxString":"FG","clouds":[{"cover":"VV","base":200}]}]`,
+			wantVis:      ptr(0.25),
+			wantCeiling:  ptr(200),
+			wantCategory: FlightCategoryLIFR,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := decodeMETAR(strings.NewReader(tt.fixture), "KARB")
+			if err != nil {
+				t.Fatalf("decodeMETAR() error = %v", err)
+			}
+			if !equalPtr(m.WindBearing, tt.wantBearing) {
+				t.Errorf("WindBearing = %v; want %v", fmtPtr(m.WindBearing), fmtPtr(tt.wantBearing))
+			}
+			if !equalPtr(m.VisibilityMiles, tt.wantVis) {
+				t.Errorf("VisibilityMiles = %v; want %v", fmtPtr(m.VisibilityMiles), fmtPtr(tt.wantVis))
+			}
+			if !equalPtr(m.CeilingFt, tt.wantCeiling) {
+				t.Errorf("CeilingFt = %v; want %v", fmtPtr(m.CeilingFt), fmtPtr(tt.wantCeiling))
+			}
+			if got := m.FlightCategory(); got != tt.wantCategory {
+				t.Errorf("FlightCategory() = %q; want %q", got, tt.wantCategory)
+			}
+			fields := m.Fields()
+			if _, ok := fields["flight_category"]; ok != (tt.wantCategory != "") {
+				t.Errorf("fields[\"flight_category\"] = %v; want it set: %t", fields["flight_category"], tt.wantCategory != "")
+			}
+		})
+	}
+}
+
+func TestDecodeMETARNoReport(t *testing.T) {
+	if _, err := decodeMETAR(strings.NewReader(`[]`), "KARB"); err == nil {
+		t.Error("decodeMETAR() of an empty response succeeded; want an error")
+	}
+}
+
+func TestFlightCategory(t *testing.T) {
+	tests := []struct {
+		vis     float64
+		ceiling *int
+		want    string
+	}{
+		// visibility alone, with no ceiling
+		{0.75, nil, FlightCategoryLIFR},
+		{1, nil, FlightCategoryIFR},
+		{2.75, nil, FlightCategoryIFR},
+		{3, nil, FlightCategoryMVFR},
+		{5, nil, FlightCategoryMVFR},
+		{5.5, nil, FlightCategoryVFR},
+		// ceiling alone, with unrestricted visibility
+		{10, ptr(499), FlightCategoryLIFR},
+		{10, ptr(500), FlightCategoryIFR},
+		{10, ptr(999), FlightCategoryIFR},
+		{10, ptr(1000), FlightCategoryMVFR},
+		{10, ptr(3000), FlightCategoryMVFR},
+		{10, ptr(3100), FlightCategoryVFR},
+		// the more restrictive of ceiling and visibility wins
+		{2, ptr(5000), FlightCategoryIFR},
+		{10, ptr(400), FlightCategoryLIFR},
+		{0.5, ptr(2000), FlightCategoryLIFR},
+		{4, ptr(800), FlightCategoryIFR},
+	}
+	for _, tt := range tests {
+		m := &METAR{VisibilityMiles: &tt.vis, CeilingFt: tt.ceiling}
+		if got := m.FlightCategory(); got != tt.want {
+			t.Errorf("FlightCategory() with visibility %v mi and ceiling %v ft = %q; want %q", tt.vis, fmtPtr(tt.ceiling), got, tt.want)
+		}
+	}
+}
+
+// ptr returns a pointer to v.
+func ptr[T any](v T) *T {
+	return &v
+}
+
+// equalPtr reports whether a and b are both nil, or point to equal values.
+func equalPtr[T comparable](a, b *T) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+// fmtPtr returns the value p points to, or "nil".
+func fmtPtr[T any](p *T) interface{} {
+	if p == nil {
+		return "nil"
+	}
+	return *p
+}
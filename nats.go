@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+const (
+	defaultNATSName          = "nats"
+	defaultNATSSubjectPrefix = "weather"
+	natsTimeout              = 10 * time.Second
+)
+
+// NATSConfig describes the configuration for the NATS output.
+type NATSConfig struct {
+	Name          string `json:"name,omitempty"`
+	URL           string `json:"url"`
+	SubjectPrefix string `json:"subject_prefix,omitempty"`
+	User          string `json:"user,omitempty"`
+	Pass          string `json:"password,omitempty"`
+	Token         string `json:"token,omitempty"`
+	CredsFile     string `json:"creds_file,omitempty"`
+	JetStream     bool   `json:"jetstream,omitempty"`
+}
+
+// Validate checks the NATS configuration.
+func (c NATSConfig) Validate() error {
+	if c.URL == "" {
+		return errors.New("url must be set")
+	}
+	if strings.ContainsAny(c.SubjectPrefix, " \t*>") {
+		return errors.New("subject_prefix must not contain whitespace or wildcards")
+	}
+	return nil
+}
+
+// OutputName returns the configured output name, or the default "nats".
+func (c NATSConfig) OutputName() string {
+	if c.Name == "" {
+		return defaultNATSName
+	}
+	return c.Name
+}
+
+// Subject returns the subject that points for the given measurement are published to.
+func (c NATSConfig) Subject(measurement string) string {
+	prefix := c.SubjectPrefix
+	if prefix == "" {
+		prefix = defaultNATSSubjectPrefix
+	}
+	return prefix + "." + measurement
+}
+
+// natsOutput publishes points as JSON messages to NATS, optionally via JetStream.
+type natsOutput struct {
+	config NATSConfig
+	conn   *nats.Conn
+	js     jetstream.JetStream
+}
+
+// newNATSOutput connects to the configured NATS server.
+func newNATSOutput(c NATSConfig) (*natsOutput, error) {
+	opts := []nats.Option{nats.Name("openweather-influxdb-connector"), nats.Timeout(natsTimeout)}
+	if c.User != "" || c.Pass != "" {
+		opts = append(opts, nats.UserInfo(c.User, c.Pass))
+	}
+	if c.Token != "" {
+		opts = append(opts, nats.Token(c.Token))
+	}
+	if c.CredsFile != "" {
+		opts = append(opts, nats.UserCredentials(c.CredsFile))
+	}
+	conn, err := nats.Connect(c.URL, opts...)
+	if err != nil {
+		return nil, err
+	}
+	o := &natsOutput{config: c, conn: conn}
+	if c.JetStream {
+		if o.js, err = jetstream.New(conn); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	return o, nil
+}
+
+// Name returns the output's name, for output routing.
+func (o *natsOutput) Name() string {
+	return o.config.OutputName()
+}
+
+// WritePoint publishes a single point. With JetStream, the message ID is derived from the
+// measurement, tags, and timestamp, so the server deduplicates re-published observations.
+func (o *natsOutput) WritePoint(ctx context.Context, measurement string, tags map[string]string, fields map[string]interface{}, ts time.Time) error {
+	payload, err := marshalPoint(measurement, tags, fields, ts)
+	if err != nil {
+		return err
+	}
+	subject := o.config.Subject(measurement)
+	if o.js == nil {
+		return o.conn.Publish(subject, payload)
+	}
+	ctx, cancel := context.WithTimeout(ctx, natsTimeout)
+	defer cancel()
+	_, err = o.js.Publish(ctx, subject, payload, jetstream.WithMsgID(natsMsgID(measurement, tags, ts)))
+	return err
+}
+
+// Close flushes any buffered messages and closes the connection.
+func (o *natsOutput) Close() {
+	if err := o.conn.FlushTimeout(natsTimeout); err != nil {
+		slog.Error("Failed to flush NATS messages", "error", err)
+	}
+	o.conn.Close()
+}
+
+// natsMsgID returns a JetStream message ID identifying the series and timestamp of a point.
+func natsMsgID(measurement string, tags map[string]string, ts time.Time) string {
+	keys := sortedKeys(tags)
+	var b strings.Builder
+	b.WriteString(measurement)
+	for _, k := range keys {
+		fmt.Fprintf(&b, ",%s=%s", k, tags[k])
+	}
+	b.WriteString(" ")
+	b.WriteString(strconv.FormatInt(ts.Unix(), 10))
+	return b.String()
+}
@@ -0,0 +1,328 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+)
+
+const (
+	defaultNotificationCooldown = 6 * time.Hour
+	defaultNotificationMessage  = "{{.Rule}}: {{.Field}} is {{.Value}}"
+	notificationTimeout         = 10 * time.Second
+)
+
+// NotificationsConfig describes the configuration for threshold alert notifications.
+type NotificationsConfig struct {
+	Ntfy     *NtfyConfig        `json:"ntfy,omitempty"`
+	Pushover *PushoverConfig    `json:"pushover,omitempty"`
+	Telegram *TelegramConfig    `json:"telegram,omitempty"`
+	Rules    []NotificationRule `json:"rules"`
+}
+
+// NtfyConfig describes an ntfy topic to publish notifications to.
+type NtfyConfig struct {
+	URL   string `json:"url"`
+	Token string `json:"token,omitempty"`
+}
+
+// PushoverConfig describes the Pushover application and user to send notifications to.
+type PushoverConfig struct {
+	Token string `json:"token"`
+	User  string `json:"user"`
+}
+
+// TelegramConfig describes the Telegram bot and chat to send notifications to.
+type TelegramConfig struct {
+	BotToken string `json:"bot_token"`
+	ChatID   string `json:"chat_id"`
+}
+
+// NotificationRule fires a notification when its condition matches the data from a run.
+type NotificationRule struct {
+	Name        string `json:"name"`
+	Measurement string `json:"measurement,omitempty"`
+	Condition   string `json:"condition"`
+	Message     string `json:"message,omitempty"`
+	Cooldown    string `json:"cooldown,omitempty"`
+
+	field    string
+	op       string
+	operand  interface{}
+	cooldown time.Duration
+	tmpl     *template.Template
+}
+
+// Validate checks the notifications configuration and prepares its rules for evaluation.
+func (c *NotificationsConfig) Validate() error {
+	if c.Ntfy == nil && c.Pushover == nil && c.Telegram == nil {
+		return errors.New("at least one of ntfy, pushover, or telegram must be configured")
+	}
+	if c.Ntfy != nil && c.Ntfy.URL == "" {
+		return errors.New("ntfy.url must be set")
+	}
+	if c.Pushover != nil && (c.Pushover.Token == "" || c.Pushover.User == "") {
+		return errors.New("pushover.token and pushover.user must be set")
+	}
+	if c.Telegram != nil && (c.Telegram.BotToken == "" || c.Telegram.ChatID == "") {
+		return errors.New("telegram.bot_token and telegram.chat_id must be set")
+	}
+	if len(c.Rules) == 0 {
+		return errors.New("rules must contain at least one rule")
+	}
+	names := make(map[string]bool)
+	for i := range c.Rules {
+		r := &c.Rules[i]
+		if r.Name == "" {
+			return fmt.Errorf("rule %d: name must be set", i)
+		}
+		if names[r.Name] {
+			return fmt.Errorf("rule '%s': duplicate rule name", r.Name)
+		}
+		names[r.Name] = true
+		if err := r.prepare(); err != nil {
+			return fmt.Errorf("rule '%s': %w", r.Name, err)
+		}
+	}
+	return nil
+}
+
+func (r *NotificationRule) prepare() error {
+	switch r.Measurement {
+	case "", schemaWeather, schemaPollution, schemaFreezeRisk, schemaPollen:
+	default:
+		return fmt.Errorf("measurement must be '%s', '%s', '%s', or '%s'", schemaWeather, schemaPollution, schemaFreezeRisk, schemaPollen)
+	}
+
+	var err error
+	if r.field, r.op, r.operand, err = parseCondition(r.Condition); err != nil {
+		return err
+	}
+	if _, ok := r.operand.(float64); !ok && r.op != "==" && r.op != "!=" {
+		return fmt.Errorf("operator '%s' requires a numeric value", r.op)
+	}
+
+	r.cooldown = defaultNotificationCooldown
+	if r.Cooldown != "" {
+		d, err := time.ParseDuration(r.Cooldown)
+		if err != nil {
+			return fmt.Errorf("invalid cooldown: %w", err)
+		}
+		r.cooldown = d
+	}
+
+	msg := r.Message
+	if msg == "" {
+		msg = defaultNotificationMessage
+	}
+	tmpl, err := template.New(r.Name).Parse(msg)
+	if err != nil {
+		return fmt.Errorf("invalid message template: %w", err)
+	}
+	r.tmpl = tmpl
+	return nil
+}
+
+// parseCondition parses a condition in 'field op value' form. The value may be a quoted
+// string containing spaces.
+func parseCondition(cond string) (field, op string, operand interface{}, err error) {
+	field, rest, _ := strings.Cut(strings.TrimSpace(cond), " ")
+	op, value, _ := strings.Cut(strings.TrimSpace(rest), " ")
+	value = strings.TrimSpace(value)
+	if field == "" || op == "" || value == "" {
+		return "", "", nil, fmt.Errorf("condition '%s' must be in 'field op value' form", cond)
+	}
+	switch op {
+	case ">", ">=", "<", "<=", "==", "!=":
+	default:
+		return "", "", nil, fmt.Errorf("unsupported operator '%s'", op)
+	}
+	if f, err := strconv.ParseFloat(value, 64); err == nil {
+		operand = f
+	} else if b, err := strconv.ParseBool(value); err == nil {
+		operand = b
+	} else if s, err := strconv.Unquote(value); err == nil {
+		operand = s
+	} else {
+		return "", "", nil, fmt.Errorf("value '%s' must be a number, a boolean, or a quoted string", value)
+	}
+	return field, op, operand, nil
+}
+
+// NotificationData is passed to a rule's message template.
+type NotificationData struct {
+	Rule   string
+	Field  string
+	Value  interface{}
+	Fields map[string]interface{}
+}
+
+// match reports whether the rule's condition matches the given data, which maps schema
+// names to the fields written for that measurement during this run.
+func (r *NotificationRule) match(data map[string]map[string]interface{}) (NotificationData, bool) {
+	measurements := []string{r.Measurement}
+	if r.Measurement == "" {
+		measurements = []string{schemaWeather, schemaPollution}
+	}
+	for _, m := range measurements {
+		fields, ok := data[m]
+		if !ok {
+			continue
+		}
+		v, ok := fields[r.field]
+		if !ok {
+			continue
+		}
+		return NotificationData{Rule: r.Name, Field: r.field, Value: v, Fields: fields}, compare(v, r.op, r.operand)
+	}
+	return NotificationData{}, false
+}
+
+func compare(v interface{}, op string, operand interface{}) bool {
+	switch want := operand.(type) {
+	case float64:
+		got, ok := toFloat64(v)
+		if !ok {
+			return false
+		}
+		switch op {
+		case ">":
+			return got > want
+		case ">=":
+			return got >= want
+		case "<":
+			return got < want
+		case "<=":
+			return got <= want
+		case "==":
+			return got == want
+		case "!=":
+			return got != want
+		}
+	case bool, string:
+		switch op {
+		case "==":
+			return v == want
+		case "!=":
+			return v != want
+		}
+	}
+	return false
+}
+
+func toFloat64(v interface{}) (float64, bool) {
+	if f, ok := v.(float64); ok {
+		return f, true
+	}
+	if i, ok := toInt64(v); ok {
+		return float64(i), true
+	}
+	return 0, false
+}
+
+// Notify evaluates each rule against the given data and sends notifications for matching
+// rules that aren't in their cooldown period, recording sent notifications in the state.
+// It returns the number of notifications sent and the number that failed.
+func (c *NotificationsConfig) Notify(state *State, data map[string]map[string]interface{}, now time.Time) (sent, failed int) {
+	for i := range c.Rules {
+		r := &c.Rules[i]
+		nd, ok := r.match(data)
+		if !ok {
+			continue
+		}
+		if last, ok := state.NotificationsSent[r.Name]; ok && now.Sub(last) < r.cooldown {
+			continue
+		}
+		var msg bytes.Buffer
+		if err := r.tmpl.Execute(&msg, nd); err != nil {
+			slog.Error("Failed to render notification message", "rule", r.Name, "error", err)
+			failed++
+			continue
+		}
+		if err := c.send(r.Name, msg.String()); err != nil {
+			slog.Error("Failed to send notification", "rule", r.Name, "error", err)
+			failed++
+			continue
+		}
+		if state.NotificationsSent == nil {
+			state.NotificationsSent = make(map[string]time.Time)
+		}
+		state.NotificationsSent[r.Name] = now
+		slog.Info("Sent notification", "rule", r.Name)
+		sent++
+	}
+	return sent, failed
+}
+
+// send delivers the message to every configured service. Nothing is sent during a replay.
+func (c *NotificationsConfig) send(title, msg string) error {
+	if replaying {
+		slog.Info("Not sending notification during replay", "title", title)
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), notificationTimeout)
+	defer cancel()
+
+	var errs []error
+	if c.Ntfy != nil {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.Ntfy.URL, strings.NewReader(msg))
+		if err == nil {
+			req.Header.Set("Title", title)
+			if c.Ntfy.Token != "" {
+				req.Header.Set("Authorization", "Bearer "+c.Ntfy.Token)
+			}
+			err = doNotificationRequest(req, "ntfy")
+		}
+		errs = append(errs, err)
+	}
+	if c.Pushover != nil {
+		form := url.Values{}
+		form.Set("token", c.Pushover.Token)
+		form.Set("user", c.Pushover.User)
+		form.Set("title", title)
+		form.Set("message", msg)
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, "https://api.pushover.net/1/messages.json", strings.NewReader(form.Encode()))
+		if err == nil {
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			err = doNotificationRequest(req, "Pushover")
+		}
+		errs = append(errs, err)
+	}
+	if c.Telegram != nil {
+		body, err := json.Marshal(map[string]string{
+			"chat_id": c.Telegram.ChatID,
+			"text":    title + "\n" + msg,
+		})
+		if err == nil {
+			var req *http.Request
+			req, err = http.NewRequestWithContext(ctx, http.MethodPost, "https://api.telegram.org/bot"+c.Telegram.BotToken+"/sendMessage", bytes.NewReader(body))
+			if err == nil {
+				req.Header.Set("Content-Type", "application/json")
+				err = doNotificationRequest(req, "Telegram")
+			}
+		}
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+func doNotificationRequest(req *http.Request, service string) error {
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("%s: %w", service, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("%s returned %s", service, resp.Status)
+	}
+	return nil
+}
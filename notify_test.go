@@ -0,0 +1,77 @@
+package main
+
+import "testing"
+
+func TestNotificationRulePrepare(t *testing.T) {
+	tests := []struct {
+		condition   string
+		wantField   string
+		wantOp      string
+		wantOperand interface{}
+		wantErr     bool
+	}{
+		{condition: "aqi_us > 150", wantField: "aqi_us", wantOp: ">", wantOperand: 150.0},
+		{condition: "temp_f <= -5.5", wantField: "temp_f", wantOp: "<=", wantOperand: -5.5},
+		{condition: "  temp_f   <   20  ", wantField: "temp_f", wantOp: "<", wantOperand: 20.0},
+		{condition: "alert == true", wantField: "alert", wantOp: "==", wantOperand: true},
+		{condition: `condition == "Clear"`, wantField: "condition", wantOp: "==", wantOperand: "Clear"},
+		{condition: `aqi_eu_name == "Very High"`, wantField: "aqi_eu_name", wantOp: "==", wantOperand: "Very High"},
+		{condition: `tree_category != "Very High"`, wantField: "tree_category", wantOp: "!=", wantOperand: "Very High"},
+		{condition: `condition == "a  \"b\"  c"`, wantField: "condition", wantOp: "==", wantOperand: `a  "b"  c`},
+		{condition: "", wantErr: true},
+		{condition: "temp_f", wantErr: true},
+		{condition: "temp_f <", wantErr: true},
+		{condition: "temp_f => 20", wantErr: true},
+		{condition: "condition == Mostly Cloudy", wantErr: true},
+		{condition: `condition == "Mostly Cloudy`, wantErr: true},
+		{condition: `condition > "Mostly Cloudy"`, wantErr: true},
+		{condition: "alert < true", wantErr: true},
+	}
+	for _, tt := range tests {
+		r := NotificationRule{Name: "test", Condition: tt.condition}
+		err := r.prepare()
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("prepare(%q) succeeded; want an error", tt.condition)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("prepare(%q) = %v", tt.condition, err)
+			continue
+		}
+		if r.field != tt.wantField || r.op != tt.wantOp || r.operand != tt.wantOperand {
+			t.Errorf("prepare(%q) = %q %q %#v; want %q %q %#v", tt.condition, r.field, r.op, r.operand, tt.wantField, tt.wantOp, tt.wantOperand)
+		}
+	}
+}
+
+func TestNotificationRuleMatch(t *testing.T) {
+	data := map[string]map[string]interface{}{
+		schemaWeather:   {"temp_f": 18.5, "condition": "Mostly Cloudy"},
+		schemaPollution: {"aqi_us": 151, "aqi_eu_name": "Very High"},
+	}
+	tests := []struct {
+		condition string
+		want      bool
+	}{
+		{"temp_f < 20", true},
+		{"temp_f < 18.5", false},
+		{"aqi_us > 150", true},
+		{"aqi_us >= 152", false},
+		{`condition == "Mostly Cloudy"`, true},
+		{`condition != "Mostly Cloudy"`, false},
+		{`aqi_eu_name == "Very High"`, true},
+		{`aqi_eu_name == "Very"`, false},
+		{"missing_field > 0", false},
+	}
+	for _, tt := range tests {
+		r := NotificationRule{Name: "test", Condition: tt.condition}
+		if err := r.prepare(); err != nil {
+			t.Fatalf("prepare(%q) = %v", tt.condition, err)
+		}
+		if _, got := r.match(data); got != tt.want {
+			t.Errorf("match(%q) = %v; want %v", tt.condition, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,23 @@
+package main
+
+import (
+	"time"
+
+	"github.com/cdzombak/openweather-influxdb-connector/pkg/owmconnector"
+)
+
+// RecordPM adds a particulate matter reading to the history and prunes readings older
+// than the NowCast window.
+func (s *State) RecordPM(t time.Time, pm25, pm10 float64) {
+	if n := len(s.PMHistory); n == 0 || t.After(s.PMHistory[n-1].Time) {
+		s.PMHistory = append(s.PMHistory, owmconnector.PMReading{Time: t, PM25: pm25, PM10: pm10})
+	}
+	cutoff := t.Add(-owmconnector.NowCastHours * time.Hour)
+	kept := s.PMHistory[:0]
+	for _, r := range s.PMHistory {
+		if r.Time.After(cutoff) {
+			kept = append(kept, r)
+		}
+	}
+	s.PMHistory = kept
+}
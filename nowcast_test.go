@@ -0,0 +1,30 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/cdzombak/openweather-influxdb-connector/pkg/owmconnector"
+)
+
+func TestRecordPM(t *testing.T) {
+	start := time.Date(2024, 7, 4, 0, 0, 0, 0, time.UTC)
+	s := &State{}
+	for h := 0; h < 15; h++ {
+		s.RecordPM(start.Add(time.Duration(h)*time.Hour), float64(h), float64(h))
+	}
+	// a reading older than the latest one is ignored
+	s.RecordPM(start.Add(13*time.Hour), 1000, 1000)
+
+	if len(s.PMHistory) != owmconnector.NowCastHours {
+		t.Fatalf("len(PMHistory) = %d; want %d", len(s.PMHistory), owmconnector.NowCastHours)
+	}
+	if first := s.PMHistory[0].Time; !first.Equal(start.Add(3 * time.Hour)) {
+		t.Errorf("oldest reading is from %s; want %s", first, start.Add(3*time.Hour))
+	}
+	for i, r := range s.PMHistory {
+		if r.PM25 == 1000 {
+			t.Errorf("PMHistory[%d] is the out-of-order reading", i)
+		}
+	}
+}
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/cdzombak/openweather-influxdb-connector/pkg/owmconnector"
+)
+
+// nwsAlertFields adds fields summarizing the given active NWS alerts (most severe first) to
+// the weather fields: the number of alerts, and if there are any, the distinct alert events
+// and the most severe alert's severity.
+func nwsAlertFields(fields map[string]interface{}, alerts []owmconnector.NWSAlert) {
+	fields["nws_alerts"] = len(alerts)
+	if len(alerts) == 0 {
+		return
+	}
+	seen := make(map[string]bool)
+	var events []string
+	for _, a := range alerts {
+		if !seen[a.Event] {
+			seen[a.Event] = true
+			events = append(events, a.Event)
+		}
+	}
+	fields["nws_alert_events"] = strings.Join(events, ",")
+	fields["nws_alert_severity"] = alerts[0].Severity
+}
@@ -0,0 +1,250 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cdzombak/openweather-influxdb-connector/pkg/owmconnector"
+)
+
+const (
+	openAQSource              = "openaq"
+	stationIDTag              = "station_id"
+	defaultOpenAQRadiusKm     = 10.0
+	maxOpenAQRadiusKm         = 25.0
+	defaultOpenAQMaxStations  = 3
+	openAQMaxLocationsPerCall = 100
+)
+
+// defaultOpenAQParameters lists the OpenAQ parameters written by default.
+var defaultOpenAQParameters = []string{"pm25", "pm10", "o3", "no2", "so2", "co"}
+
+// openAQMolecularWeights lists the molecular weights (g/mol) of the gases OpenAQ may report
+// in ppm or ppb, for conversion to ug/m^3.
+var openAQMolecularWeights = map[string]float64{
+	"co":  28.01,
+	"no":  30.01,
+	"no2": 46.01,
+	"o3":  48.00,
+	"so2": 64.07,
+	"nh3": 17.03,
+}
+
+// OpenAQConfig describes the configuration for the OpenAQ monitoring station input.
+type OpenAQConfig struct {
+	APIKey      string   `json:"api_key"`
+	RadiusKm    float64  `json:"radius_km,omitempty"`
+	Parameters  []string `json:"parameters,omitempty"`
+	MaxStations int      `json:"max_stations,omitempty"`
+}
+
+// Validate checks the OpenAQ configuration.
+func (c OpenAQConfig) Validate() error {
+	if c.APIKey == "" {
+		return errors.New("api_key must be set")
+	}
+	if c.RadiusKm < 0 || c.RadiusKm > maxOpenAQRadiusKm {
+		return fmt.Errorf("radius_km must be between 0 and %.0f", maxOpenAQRadiusKm)
+	}
+	if c.MaxStations < 0 {
+		return errors.New("max_stations may not be negative")
+	}
+	supported := make(map[string]bool)
+	for _, pc := range owmconnector.PollutionComponents {
+		supported[pc.Field] = true
+	}
+	for _, p := range c.Parameters {
+		if !supported[p] {
+			return fmt.Errorf("unsupported parameter '%s'", p)
+		}
+	}
+	return nil
+}
+
+// Radius returns the search radius around the location, in km.
+func (c OpenAQConfig) Radius() float64 {
+	if c.RadiusKm == 0 {
+		return defaultOpenAQRadiusKm
+	}
+	return c.RadiusKm
+}
+
+// Params returns the parameters to write.
+func (c OpenAQConfig) Params() []string {
+	if len(c.Parameters) == 0 {
+		return defaultOpenAQParameters
+	}
+	return c.Parameters
+}
+
+// Stations returns the maximum number of stations to write.
+func (c OpenAQConfig) Stations() int {
+	if c.MaxStations == 0 {
+		return defaultOpenAQMaxStations
+	}
+	return c.MaxStations
+}
+
+// OpenAQStation is a monitoring station's latest measurements.
+type OpenAQStation struct {
+	ID         int
+	Name       string
+	DistanceKm float64
+	Time       time.Time
+	// Components contains concentrations (ug/m^3) keyed by field name (e.g. "pm25").
+	Components map[string]float64
+}
+
+// Fields returns the station's fields, as written to the pollution measurement.
+func (s OpenAQStation) Fields() map[string]interface{} {
+	fields := map[string]interface{}{
+		"station_name":        s.Name,
+		"station_distance_km": s.DistanceKm,
+	}
+	for k, v := range s.Components {
+		fields[k] = v
+	}
+	return fields
+}
+
+func doOpenAQRequest(c OpenAQConfig, path string, q url.Values, v interface{}) error {
+	req, err := http.NewRequest(http.MethodGet, "https://api.openaq.org/v3/"+path+"?"+q.Encode(), nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("X-API-Key", c.APIKey)
+	resp, err := owmHTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("OpenAQ API returned %s", resp.Status)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("failed to decode OpenAQ response: %w", err)
+	}
+	return nil
+}
+
+// FetchOpenAQ fetches the latest measurements of the configured parameters from the
+// monitoring stations nearest the given location, nearest first. Stations that don't
+// report any of the parameters are skipped.
+// See https://docs.openaq.org/using-the-api/quick-start
+func FetchOpenAQ(c OpenAQConfig, lat, lon float64) ([]OpenAQStation, error) {
+	q := url.Values{}
+	q.Set("coordinates", fmt.Sprintf("%.4f,%.4f", lat, lon))
+	q.Set("radius", strconv.Itoa(int(c.Radius()*1000)))
+	q.Set("limit", strconv.Itoa(openAQMaxLocationsPerCall))
+	var locations struct {
+		Results []struct {
+			ID       int      `json:"id"`
+			Name     string   `json:"name"`
+			Distance *float64 `json:"distance"`
+			Sensors  []struct {
+				ID        int `json:"id"`
+				Parameter struct {
+					Name  string `json:"name"`
+					Units string `json:"units"`
+				} `json:"parameter"`
+			} `json:"sensors"`
+		} `json:"results"`
+	}
+	if err := doOpenAQRequest(c, "locations", q, &locations); err != nil {
+		return nil, err
+	}
+
+	wanted := make(map[string]bool)
+	for _, p := range c.Params() {
+		wanted[p] = true
+	}
+	sort.SliceStable(locations.Results, func(i, j int) bool {
+		return valueOrZero(locations.Results[i].Distance) < valueOrZero(locations.Results[j].Distance)
+	})
+
+	var stations []OpenAQStation
+	for _, loc := range locations.Results {
+		if len(stations) >= c.Stations() {
+			break
+		}
+		type sensor struct{ parameter, units string }
+		sensors := make(map[int]sensor)
+		for _, s := range loc.Sensors {
+			if wanted[s.Parameter.Name] {
+				sensors[s.ID] = sensor{s.Parameter.Name, s.Parameter.Units}
+			}
+		}
+		if len(sensors) == 0 {
+			continue
+		}
+
+		var latest struct {
+			Results []struct {
+				Datetime struct {
+					UTC time.Time `json:"utc"`
+				} `json:"datetime"`
+				Value     float64 `json:"value"`
+				SensorsID int     `json:"sensorsId"`
+			} `json:"results"`
+		}
+		if err := doOpenAQRequest(c, fmt.Sprintf("locations/%d/latest", loc.ID), url.Values{}, &latest); err != nil {
+			slog.Warn("Failed to get latest OpenAQ measurements", "station_id", loc.ID, "error", err)
+			continue
+		}
+		st := OpenAQStation{
+			ID:         loc.ID,
+			Name:       loc.Name,
+			DistanceKm: valueOrZero(loc.Distance) / 1000,
+			Components: make(map[string]float64),
+		}
+		for _, r := range latest.Results {
+			s, ok := sensors[r.SensorsID]
+			if !ok || r.Value < 0 {
+				continue
+			}
+			v, err := openAQConcentration(s.parameter, s.units, r.Value)
+			if err != nil {
+				slog.Warn("Skipping OpenAQ measurement", "station_id", loc.ID, "error", err)
+				continue
+			}
+			st.Components[s.parameter] = v
+			if r.Datetime.UTC.After(st.Time) {
+				st.Time = r.Datetime.UTC
+			}
+		}
+		if len(st.Components) > 0 {
+			stations = append(stations, st)
+		}
+	}
+	if len(stations) == 0 {
+		return nil, fmt.Errorf("no OpenAQ station within %.0f km reported %s", c.Radius(), strings.Join(c.Params(), ", "))
+	}
+	return stations, nil
+}
+
+// openAQConcentration converts an OpenAQ measurement to ug/m^3.
+func openAQConcentration(parameter, units string, v float64) (float64, error) {
+	switch strings.ToLower(units) {
+	case "µg/m³", "ug/m3", "μg/m³":
+		return v, nil
+	case "ppb", "ppm":
+		mw, ok := openAQMolecularWeights[parameter]
+		if !ok {
+			return 0, fmt.Errorf("can't convert %s from %s", parameter, units)
+		}
+		if strings.ToLower(units) == "ppm" {
+			v *= 1000
+		}
+		return owmconnector.PPBToUgM3(v, mw), nil
+	default:
+		return 0, fmt.Errorf("unsupported units '%s' for %s", units, parameter)
+	}
+}
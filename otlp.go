@@ -0,0 +1,224 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultOTLPName         = "otlp"
+	defaultOTLPMetricPrefix = "owm"
+	otlpTimeout             = 10 * time.Second
+	otlpServiceName         = "openweather-influxdb-connector"
+)
+
+// OTLPConfig describes the configuration for the OpenTelemetry OTLP/HTTP metrics output.
+type OTLPConfig struct {
+	Name         string            `json:"name,omitempty"`
+	Endpoint     string            `json:"endpoint"`
+	Headers      map[string]string `json:"headers,omitempty"`
+	MetricPrefix string            `json:"metric_prefix,omitempty"`
+}
+
+// Validate checks the OTLP configuration.
+func (c OTLPConfig) Validate() error {
+	if c.Endpoint == "" {
+		return errors.New("endpoint must be set")
+	}
+	u, err := url.Parse(c.Endpoint)
+	if err != nil {
+		return fmt.Errorf("invalid endpoint: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return errors.New("endpoint must be an http or https URL")
+	}
+	return nil
+}
+
+// OutputName returns the configured output name, or the default "otlp".
+func (c OTLPConfig) OutputName() string {
+	if c.Name == "" {
+		return defaultOTLPName
+	}
+	return c.Name
+}
+
+// MetricsURL returns the OTLP/HTTP metrics URL: the endpoint with /v1/metrics appended,
+// unless the endpoint already names a path.
+func (c OTLPConfig) MetricsURL() string {
+	u, err := url.Parse(c.Endpoint)
+	if err == nil && u.Path != "" && u.Path != "/" {
+		return c.Endpoint
+	}
+	return strings.TrimSuffix(c.Endpoint, "/") + "/v1/metrics"
+}
+
+// otlpOutput exports each numeric field of a point as an OTLP gauge named
+// <metric_prefix>.<measurement>.<field>, using OTLP/HTTP's JSON encoding. The point's
+// tags become data point attributes; the configured location is reported as resource
+// attributes.
+type otlpOutput struct {
+	config   OTLPConfig
+	client   *http.Client
+	resource otlpResource
+}
+
+type otlpKeyValue struct {
+	Key   string `json:"key"`
+	Value struct {
+		StringValue *string  `json:"stringValue,omitempty"`
+		DoubleValue *float64 `json:"doubleValue,omitempty"`
+	} `json:"value"`
+}
+
+type otlpResource struct {
+	Attributes []otlpKeyValue `json:"attributes"`
+}
+
+type otlpDataPoint struct {
+	Attributes   []otlpKeyValue `json:"attributes,omitempty"`
+	TimeUnixNano string         `json:"timeUnixNano"`
+	AsDouble     *float64       `json:"asDouble,omitempty"`
+	AsInt        *string        `json:"asInt,omitempty"`
+}
+
+type otlpMetric struct {
+	Name  string `json:"name"`
+	Unit  string `json:"unit,omitempty"`
+	Gauge struct {
+		DataPoints []otlpDataPoint `json:"dataPoints"`
+	} `json:"gauge"`
+}
+
+type otlpScopeMetrics struct {
+	Scope struct {
+		Name string `json:"name"`
+	} `json:"scope"`
+	Metrics []otlpMetric `json:"metrics"`
+}
+
+type otlpResourceMetrics struct {
+	Resource     otlpResource       `json:"resource"`
+	ScopeMetrics []otlpScopeMetrics `json:"scopeMetrics"`
+}
+
+type otlpExportRequest struct {
+	ResourceMetrics []otlpResourceMetrics `json:"resourceMetrics"`
+}
+
+func otlpString(k, v string) otlpKeyValue {
+	kv := otlpKeyValue{Key: k}
+	kv.Value.StringValue = &v
+	return kv
+}
+
+func otlpDouble(k string, v float64) otlpKeyValue {
+	kv := otlpKeyValue{Key: k}
+	kv.Value.DoubleValue = &v
+	return kv
+}
+
+// newOTLPOutput returns an OTLP output for the given configuration, reporting the given
+// location as resource attributes.
+func newOTLPOutput(c OTLPConfig, lat, lon float64) *otlpOutput {
+	return &otlpOutput{
+		config: c,
+		client: &http.Client{Timeout: otlpTimeout},
+		resource: otlpResource{Attributes: []otlpKeyValue{
+			otlpString("service.name", otlpServiceName),
+			otlpDouble("geo.location.lat", lat),
+			otlpDouble("geo.location.lon", lon),
+		}},
+	}
+}
+
+// Name returns the output's name, for output routing.
+func (o *otlpOutput) Name() string {
+	return o.config.OutputName()
+}
+
+// WritePoint exports the point's numeric fields as gauges. Non-numeric fields are ignored.
+func (o *otlpOutput) WritePoint(ctx context.Context, measurement string, tags map[string]string, fields map[string]interface{}, ts time.Time) error {
+	prefix := o.config.MetricPrefix
+	if prefix == "" {
+		prefix = defaultOTLPMetricPrefix
+	}
+
+	tagKeys := sortedKeys(tags)
+	attrs := make([]otlpKeyValue, 0, len(tagKeys))
+	for _, k := range tagKeys {
+		attrs = append(attrs, otlpString(k, tags[k]))
+	}
+
+	names := sortedKeys(fields)
+
+	var metrics []otlpMetric
+	for _, k := range names {
+		dp := otlpDataPoint{Attributes: attrs, TimeUnixNano: strconv.FormatInt(ts.UnixNano(), 10)}
+		switch v := fields[k].(type) {
+		case float64:
+			dp.AsDouble = &v
+		case float32:
+			f := float64(v)
+			dp.AsDouble = &f
+		case int:
+			s := strconv.Itoa(v)
+			dp.AsInt = &s
+		case int64:
+			s := strconv.FormatInt(v, 10)
+			dp.AsInt = &s
+		default:
+			continue
+		}
+		m := otlpMetric{Name: prefix + "." + measurement + "." + k}
+		m.Gauge.DataPoints = []otlpDataPoint{dp}
+		metrics = append(metrics, m)
+	}
+	if len(metrics) == 0 {
+		return nil
+	}
+
+	scope := otlpScopeMetrics{Metrics: metrics}
+	scope.Scope.Name = otlpServiceName
+	body := otlpExportRequest{ResourceMetrics: []otlpResourceMetrics{{
+		Resource:     o.resource,
+		ScopeMetrics: []otlpScopeMetrics{scope},
+	}}}
+
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, otlpTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, o.config.MetricsURL(), bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range o.config.Headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := o.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("OTLP endpoint returned %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
+
+// Close is a no-op; each point is exported with its own request.
+func (o *otlpOutput) Close() {}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestOTLPMetricsURL(t *testing.T) {
+	tests := []struct {
+		endpoint string
+		want     string
+	}{
+		{"http://localhost:4318", "http://localhost:4318/v1/metrics"},
+		{"http://localhost:4318/", "http://localhost:4318/v1/metrics"},
+		{"https://otlp.example.com/otlp/v1/metrics", "https://otlp.example.com/otlp/v1/metrics"},
+	}
+	for _, tt := range tests {
+		if got := (OTLPConfig{Endpoint: tt.endpoint}).MetricsURL(); got != tt.want {
+			t.Errorf("MetricsURL() for %q = %q; want %q", tt.endpoint, got, tt.want)
+		}
+	}
+}
+
+func TestOTLPWritePoint(t *testing.T) {
+	var gotPath, gotAuth, gotBody string
+	status := http.StatusOK
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		gotPath, gotAuth, gotBody = r.URL.Path, r.Header.Get("Authorization"), string(b)
+		w.WriteHeader(status)
+		io.WriteString(w, "rejected\n")
+	}))
+	defer srv.Close()
+
+	o := newOTLPOutput(OTLPConfig{Endpoint: srv.URL, Headers: map[string]string{"Authorization": "Bearer xyz"}}, 42.28, -83.74)
+	ts := time.Unix(1720094400, 0)
+	tags := map[string]string{"location": "home", "data_source": "owm"}
+	fields := map[string]interface{}{"temp_f": 72.5, "rel_humidity": 55, "condition": "Clear"}
+	if err := o.WritePoint(context.Background(), "weather", tags, fields, ts); err != nil {
+		t.Fatalf("WritePoint() = %v", err)
+	}
+
+	const attrs = `"attributes":[{"key":"data_source","value":{"stringValue":"owm"}},{"key":"location","value":{"stringValue":"home"}}]`
+	want := `{"resourceMetrics":[{"resource":{"attributes":[` +
+		`{"key":"service.name","value":{"stringValue":"openweather-influxdb-connector"}},` +
+		`{"key":"geo.location.lat","value":{"doubleValue":42.28}},` +
+		`{"key":"geo.location.lon","value":{"doubleValue":-83.74}}]},` +
+		`"scopeMetrics":[{"scope":{"name":"openweather-influxdb-connector"},"metrics":[` +
+		`{"name":"owm.weather.rel_humidity","gauge":{"dataPoints":[{` + attrs + `,"timeUnixNano":"1720094400000000000","asInt":"55"}]}},` +
+		`{"name":"owm.weather.temp_f","gauge":{"dataPoints":[{` + attrs + `,"timeUnixNano":"1720094400000000000","asDouble":72.5}]}}` +
+		`]}]}]}`
+	if gotBody != want {
+		t.Errorf("request body = %s\nwant %s", gotBody, want)
+	}
+	if gotPath != "/v1/metrics" {
+		t.Errorf("request path = %q; want /v1/metrics", gotPath)
+	}
+	if gotAuth != "Bearer xyz" {
+		t.Errorf("Authorization header = %q; want the configured header", gotAuth)
+	}
+
+	gotBody = ""
+	if err := o.WritePoint(context.Background(), "weather", tags, map[string]interface{}{"condition": "Clear"}, ts); err != nil || gotBody != "" {
+		t.Errorf("WritePoint() with no numeric fields = %v, sent %q; want nothing sent", err, gotBody)
+	}
+
+	status = http.StatusBadRequest
+	if err := o.WritePoint(context.Background(), "weather", tags, fields, ts); err == nil {
+		t.Error("WritePoint() succeeded when the endpoint returned 400; want an error")
+	}
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+const (
+	defaultOutputWriteTimeout  = 15 * time.Second
+	defaultOutputFailureBudget = 3
+)
+
+var (
+	// errOutputDisabled is returned by writes to an output disabled after repeated failures.
+	errOutputDisabled = errors.New("output disabled after repeated failures")
+	// errOutputBusy is returned by writes to an output whose previous write overran its
+	// deadline and hasn't returned yet.
+	errOutputBusy = errors.New("previous write has not finished")
+)
+
+// pointOutput is a destination that points are written to: an InfluxDB target, or
+// another output like NATS.
+type pointOutput interface {
+	// Name returns the output's name, for output routing.
+	Name() string
+	// WritePoint writes a single point to the output, giving up when ctx is done.
+	WritePoint(ctx context.Context, measurement string, tags map[string]string, fields map[string]interface{}, ts time.Time) error
+	// Close flushes any pending writes and closes the output.
+	Close()
+}
+
+// jsonPoint is the JSON encoding of a point, used by message-based outputs.
+type jsonPoint struct {
+	Measurement string                 `json:"measurement"`
+	Tags        map[string]string      `json:"tags"`
+	Fields      map[string]interface{} `json:"fields"`
+	Time        time.Time              `json:"time"`
+}
+
+// marshalPoint encodes a point as JSON.
+func marshalPoint(measurement string, tags map[string]string, fields map[string]interface{}, ts time.Time) ([]byte, error) {
+	return json.Marshal(jsonPoint{
+		Measurement: measurement,
+		Tags:        tags,
+		Fields:      fields,
+		Time:        ts.UTC(),
+	})
+}
+
+// sink wraps an output with a deadline for each write and a budget of consecutive failed
+// writes; once the budget is used up, the output is disabled for the rest of the run.
+type sink struct {
+	output   pointOutput
+	timeout  time.Duration
+	budget   int
+	failures int
+	disabled bool
+	// busy holds a token while a write is in progress, so a write that overran its
+	// deadline isn't overlapped by the next one.
+	busy chan struct{}
+}
+
+// newSink wraps the given output with the configured write timeout and failure budget.
+func newSink(o pointOutput, config Config) *sink {
+	return &sink{
+		output:  o,
+		timeout: config.outputWriteTimeout(),
+		budget:  config.outputFailureBudget(),
+		busy:    make(chan struct{}, 1),
+	}
+}
+
+// Name returns the wrapped output's name.
+func (s *sink) Name() string {
+	return s.output.Name()
+}
+
+// WritePoint writes a single point to the output, failing if the write doesn't complete
+// within the timeout. It's not safe for concurrent use.
+func (s *sink) WritePoint(measurement string, tags map[string]string, fields map[string]interface{}, ts time.Time) error {
+	if s.disabled {
+		return errOutputDisabled
+	}
+	err := s.write(measurement, tags, fields, ts)
+	if err == nil {
+		s.failures = 0
+		return nil
+	}
+	s.failures++
+	if s.failures >= s.budget {
+		s.disabled = true
+		slog.Error("Output failed too many consecutive writes; not writing to it for the rest of this run", "output", s.Name(), "failures", s.failures)
+	}
+	return err
+}
+
+func (s *sink) write(measurement string, tags map[string]string, fields map[string]interface{}, ts time.Time) error {
+	select {
+	case s.busy <- struct{}{}:
+	default:
+		return errOutputBusy
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
+	done := make(chan error, 1)
+	go func() {
+		err := s.output.WritePoint(ctx, measurement, tags, fields, ts)
+		<-s.busy
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return fmt.Errorf("write timed out after %s", s.timeout)
+	}
+}
+
+// Close waits (up to the write timeout) for any write still in progress, then closes the output.
+func (s *sink) Close() {
+	select {
+	case s.busy <- struct{}{}:
+	case <-time.After(s.timeout):
+		slog.Warn("Closing output with a write still in progress", "output", s.Name())
+	}
+	s.output.Close()
+}
+
+// outputWriteTimeout returns the configured deadline for each write to an output, or the default.
+func (c Config) outputWriteTimeout() time.Duration {
+	if c.OutputWriteTimeout == "" {
+		return defaultOutputWriteTimeout
+	}
+	d, _ := time.ParseDuration(c.OutputWriteTimeout)
+	return d
+}
+
+// outputFailureBudget returns the configured number of consecutive failed writes after
+// which an output is disabled, or the default.
+func (c Config) outputFailureBudget() int {
+	if c.OutputFailureBudget == 0 {
+		return defaultOutputFailureBudget
+	}
+	return c.OutputFailureBudget
+}
+
+// discardOutput drops every point; it's used by the print subcommand.
+type discardOutput struct{}
+
+func (discardOutput) Name() string { return "discard" }
+
+func (discardOutput) WritePoint(context.Context, string, map[string]string, map[string]interface{}, time.Time) error {
+	return nil
+}
+
+func (discardOutput) Close() {}
+
+// newOutputs connects to the configured non-InfluxDB outputs. An output that can't be set
+// up is logged and skipped.
+func newOutputs(config Config) []pointOutput {
+	var outputs []pointOutput
+	if config.NATS != nil {
+		o, err := newNATSOutput(*config.NATS)
+		if err != nil {
+			slog.Error("Failed to connect to NATS; not writing to it", "url", config.NATS.URL, "error", err)
+		} else {
+			outputs = append(outputs, o)
+		}
+	}
+	if config.Postgres != nil {
+		o, err := newPostgresOutput(*config.Postgres)
+		if err != nil {
+			slog.Error("Failed to connect to PostgreSQL; not writing to it", "error", err)
+		} else {
+			outputs = append(outputs, o)
+		}
+	}
+	if config.QuestDB != nil {
+		outputs = append(outputs, newQuestDBOutput(*config.QuestDB))
+	}
+	if config.Statsd != nil {
+		o, err := newStatsdOutput(*config.Statsd)
+		if err != nil {
+			slog.Error("Failed to set up statsd output; not writing to it", "address", config.Statsd.Address, "error", err)
+		} else {
+			outputs = append(outputs, o)
+		}
+	}
+	if config.OTLP != nil {
+		outputs = append(outputs, newOTLPOutput(*config.OTLP, config.Latitude, config.Longitude))
+	}
+	if config.AWS != nil {
+		awsOutputs, err := newAWSOutputs(*config.AWS)
+		if err != nil {
+			slog.Error("Failed to set up AWS outputs; not writing to them", "error", err)
+		} else {
+			outputs = append(outputs, awsOutputs...)
+		}
+	}
+	if config.Exec != nil {
+		outputs = append(outputs, &execOutput{config: *config.Exec})
+	}
+	return outputs
+}
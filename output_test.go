@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// fakeOutput is an output whose writes take the given delay (or until ctx is done) and
+// then return err.
+type fakeOutput struct {
+	delay  time.Duration
+	err    error
+	writes int
+}
+
+func (o *fakeOutput) Name() string { return "fake" }
+
+func (o *fakeOutput) WritePoint(ctx context.Context, _ string, _ map[string]string, _ map[string]interface{}, _ time.Time) error {
+	o.writes++
+	select {
+	case <-time.After(o.delay):
+		return o.err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (o *fakeOutput) Close() {}
+
+func TestSinkTimeout(t *testing.T) {
+	o := &fakeOutput{delay: time.Second}
+	s := newSink(o, Config{OutputWriteTimeout: "20ms"})
+	start := time.Now()
+	if err := s.WritePoint("m", nil, nil, time.Time{}); err == nil {
+		t.Fatal("WritePoint() succeeded; want a timeout error")
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("WritePoint() took %s; want it to give up after the write timeout", elapsed)
+	}
+}
+
+func TestSinkFailureBudget(t *testing.T) {
+	o := &fakeOutput{err: errors.New("unavailable")}
+	s := newSink(o, Config{OutputFailureBudget: 2})
+
+	if err := s.WritePoint("m", nil, nil, time.Time{}); err == nil || errors.Is(err, errOutputDisabled) {
+		t.Fatalf("first WritePoint() = %v; want the output's error", err)
+	}
+	o.err = nil
+	if err := s.WritePoint("m", nil, nil, time.Time{}); err != nil {
+		t.Fatalf("second WritePoint() = %v; want success", err)
+	}
+
+	// a success resets the budget, so it takes two more consecutive failures to disable the output
+	o.err = errors.New("unavailable")
+	for i := 0; i < 2; i++ {
+		if err := s.WritePoint("m", nil, nil, time.Time{}); err == nil || errors.Is(err, errOutputDisabled) {
+			t.Fatalf("WritePoint() = %v; want the output's error", err)
+		}
+	}
+	if !s.disabled {
+		t.Fatal("output isn't disabled after using up its failure budget")
+	}
+
+	writes := o.writes
+	if err := s.WritePoint("m", nil, nil, time.Time{}); !errors.Is(err, errOutputDisabled) {
+		t.Errorf("WritePoint() to a disabled output = %v; want errOutputDisabled", err)
+	}
+	if o.writes != writes {
+		t.Error("a disabled output was written to")
+	}
+}
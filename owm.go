@@ -0,0 +1,24 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+)
+
+const defaultOWMTimeout = 15 * time.Second
+
+// owmHTTPClient is the HTTP client used for all OpenWeatherMap API requests.
+var owmHTTPClient = &http.Client{Timeout: defaultOWMTimeout}
+
+// configureOWMClient applies the OpenWeatherMap client settings from the config.
+func configureOWMClient(config Config) error {
+	if config.OWMTimeout != "" {
+		d, err := time.ParseDuration(config.OWMTimeout)
+		if err != nil || d <= 0 {
+			return fmt.Errorf("owm_timeout must be a positive duration (e.g. '10s'); got '%s'", config.OWMTimeout)
+		}
+		owmHTTPClient.Timeout = d
+	}
+	return nil
+}
@@ -0,0 +1,257 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cdzombak/libwx"
+)
+
+const (
+	owmAPIBaseURL = "https://api.openweathermap.org/data/2.5/"
+
+	defaultRoadRiskMeasurementName       = "road_risk"
+	defaultSolarRadiationMeasurementName = "solar_radiation"
+	defaultFireWeatherMeasurementName    = "fire_weather_index"
+)
+
+// roadStates names OpenWeatherMap Road Risk's road surface states.
+// See https://openweathermap.org/api/road-risk
+var roadStates = map[int]string{
+	0:  "dry",
+	1:  "moist",
+	2:  "moist and chemically treated",
+	3:  "wet",
+	4:  "wet and chemically treated",
+	5:  "ice",
+	6:  "frost",
+	7:  "snow",
+	8:  "snow/ice watch",
+	9:  "snow/ice warning",
+	10: "wet above freezing",
+	11: "wet below freezing",
+	12: "absorption",
+	13: "absorption dew point",
+}
+
+// OWMPaidConfig describes which of OpenWeatherMap's paid (special product) APIs to fetch
+// data from, and the measurements to write it to.
+type OWMPaidConfig struct {
+	RoadRisk                        bool   `json:"road_risk,omitempty"`
+	RoadRiskMeasurementName         string `json:"road_risk_measurement_name,omitempty"`
+	SolarRadiation                  bool   `json:"solar_radiation,omitempty"`
+	SolarRadiationMeasurementName   string `json:"solar_radiation_measurement_name,omitempty"`
+	FireWeatherIndex                bool   `json:"fire_weather_index,omitempty"`
+	FireWeatherIndexMeasurementName string `json:"fire_weather_index_measurement_name,omitempty"`
+}
+
+// Validate checks the paid API configuration.
+func (c OWMPaidConfig) Validate() error {
+	if !c.RoadRisk && !c.SolarRadiation && !c.FireWeatherIndex {
+		return errors.New("at least one of road_risk, solar_radiation, or fire_weather_index must be enabled")
+	}
+	return nil
+}
+
+// RoadRiskMeasurement returns the configured Road Risk measurement name, or the default.
+func (c OWMPaidConfig) RoadRiskMeasurement() string {
+	if c.RoadRiskMeasurementName == "" {
+		return defaultRoadRiskMeasurementName
+	}
+	return c.RoadRiskMeasurementName
+}
+
+// SolarRadiationMeasurement returns the configured Solar Radiation measurement name, or
+// the default.
+func (c OWMPaidConfig) SolarRadiationMeasurement() string {
+	if c.SolarRadiationMeasurementName == "" {
+		return defaultSolarRadiationMeasurementName
+	}
+	return c.SolarRadiationMeasurementName
+}
+
+// FireWeatherIndexMeasurement returns the configured Fire Weather Index measurement name,
+// or the default.
+func (c OWMPaidConfig) FireWeatherIndexMeasurement() string {
+	if c.FireWeatherIndexMeasurementName == "" {
+		return defaultFireWeatherMeasurementName
+	}
+	return c.FireWeatherIndexMeasurementName
+}
+
+// owmPaidReading is a reading from one of OpenWeatherMap's paid APIs.
+type owmPaidReading struct {
+	Time   time.Time
+	Fields map[string]interface{}
+}
+
+func doOWMPaidRequest(req *http.Request, api string, v interface{}) error {
+	resp, err := owmHTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusUnauthorized {
+		return fmt.Errorf("OpenWeatherMap %s API returned %s; is your API key subscribed to it?", api, resp.Status)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("OpenWeatherMap %s API returned %s", api, resp.Status)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("failed to decode OpenWeatherMap %s response: %w", api, err)
+	}
+	return nil
+}
+
+func owmPaidQuery(apiKey string, lat, lon float64) url.Values {
+	q := url.Values{}
+	q.Set("lat", strconv.FormatFloat(lat, 'f', 4, 64))
+	q.Set("lon", strconv.FormatFloat(lon, 'f', 4, 64))
+	q.Set("appid", apiKey)
+	return q
+}
+
+// fetchRoadRisk fetches current road conditions and weather alerts at the given location.
+// See https://openweathermap.org/api/road-risk
+func fetchRoadRisk(apiKey string, lat, lon float64, now time.Time) (*owmPaidReading, error) {
+	q := url.Values{}
+	q.Set("appid", apiKey)
+	reqBody, err := json.Marshal(map[string]interface{}{
+		"track": []map[string]interface{}{{"lat": lat, "lon": lon, "dt": now.Unix()}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest(http.MethodPost, owmAPIBaseURL+"roadrisk?"+q.Encode(), bytes.NewReader(reqBody))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	var body []struct {
+		Dt      int64 `json:"dt"`
+		Weather struct {
+			Temp                   *float64 `json:"temp"`
+			PrecipitationIntensity *float64 `json:"precipitation_intensity"`
+		} `json:"weather"`
+		Road *struct {
+			State int      `json:"state"`
+			Temp  *float64 `json:"temp"`
+		} `json:"road"`
+		Alerts []struct {
+			Event      string `json:"event"`
+			EventLevel int    `json:"event_level"`
+		} `json:"alerts"`
+	}
+	if err := doOWMPaidRequest(req, "Road Risk", &body); err != nil {
+		return nil, err
+	}
+	if len(body) == 0 {
+		return nil, errors.New("OpenWeatherMap Road Risk API didn't return any data")
+	}
+
+	pt := body[0]
+	r := &owmPaidReading{Time: time.Unix(pt.Dt, 0), Fields: make(map[string]interface{})}
+	// nb. Road Risk temperatures are in Kelvin
+	if pt.Weather.Temp != nil {
+		r.Fields["air_temp_f"] = libwx.TempC(*pt.Weather.Temp - 273.15).F().Unwrap()
+	}
+	if pt.Weather.PrecipitationIntensity != nil {
+		r.Fields["precipitation_intensity_mmh"] = *pt.Weather.PrecipitationIntensity
+	}
+	if pt.Road != nil {
+		r.Fields["road_state"] = pt.Road.State
+		if name, ok := roadStates[pt.Road.State]; ok {
+			r.Fields["road_state_name"] = name
+		}
+		if pt.Road.Temp != nil {
+			r.Fields["road_temp_f"] = libwx.TempC(*pt.Road.Temp - 273.15).F().Unwrap()
+		}
+	}
+	maxLevel := 0
+	var events []string
+	for _, a := range pt.Alerts {
+		events = append(events, a.Event)
+		if a.EventLevel > maxLevel {
+			maxLevel = a.EventLevel
+		}
+	}
+	r.Fields["alerts"] = len(pt.Alerts)
+	r.Fields["alert_level"] = maxLevel
+	r.Fields["alert_events"] = strings.Join(events, ", ")
+	return r, nil
+}
+
+// fetchSolarRadiation fetches current solar irradiance at the given location.
+// See https://openweathermap.org/api/solar-radiation
+func fetchSolarRadiation(apiKey string, lat, lon float64) (*owmPaidReading, error) {
+	req, err := http.NewRequest(http.MethodGet, owmAPIBaseURL+"solar_radiation?"+owmPaidQuery(apiKey, lat, lon).Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	var body struct {
+		List []struct {
+			Dt        int64              `json:"dt"`
+			Radiation map[string]float64 `json:"radiation"`
+		} `json:"list"`
+	}
+	if err := doOWMPaidRequest(req, "Solar Radiation", &body); err != nil {
+		return nil, err
+	}
+	if len(body.List) == 0 {
+		return nil, errors.New("OpenWeatherMap Solar Radiation API didn't return any data")
+	}
+
+	cur := body.List[0]
+	r := &owmPaidReading{Time: time.Unix(cur.Dt, 0), Fields: make(map[string]interface{})}
+	// GHI, DNI, and DHI (W/m^2), under actual and clear sky conditions
+	for _, k := range []string{"ghi", "dni", "dhi", "ghi_cs", "dni_cs", "dhi_cs"} {
+		if v, ok := cur.Radiation[k]; ok {
+			r.Fields[k] = v
+		}
+	}
+	return r, nil
+}
+
+// fetchFireWeatherIndex fetches the current Fire Weather Index at the given location.
+// See https://openweathermap.org/api/fire-weather-index
+func fetchFireWeatherIndex(apiKey string, lat, lon float64) (*owmPaidReading, error) {
+	req, err := http.NewRequest(http.MethodGet, owmAPIBaseURL+"fwi?"+owmPaidQuery(apiKey, lat, lon).Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	var body struct {
+		List []struct {
+			Dt   int64 `json:"dt"`
+			Main struct {
+				FWI float64 `json:"fwi"`
+			} `json:"main"`
+			DangerRating struct {
+				Description string `json:"description"`
+				Value       int    `json:"value"`
+			} `json:"danger_rating"`
+		} `json:"list"`
+	}
+	if err := doOWMPaidRequest(req, "Fire Weather Index", &body); err != nil {
+		return nil, err
+	}
+	if len(body.List) == 0 {
+		return nil, errors.New("OpenWeatherMap Fire Weather Index API didn't return any data")
+	}
+
+	cur := body.List[0]
+	return &owmPaidReading{
+		Time: time.Unix(cur.Dt, 0),
+		Fields: map[string]interface{}{
+			"fwi":                cur.Main.FWI,
+			"danger_rating":      cur.DangerRating.Value,
+			"danger_rating_name": cur.DangerRating.Description,
+		},
+	}, nil
+}
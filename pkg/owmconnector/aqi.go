@@ -0,0 +1,181 @@
+package owmconnector
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/mrflynn/go-aqi"
+)
+
+// Supported AQI standards, for PollutionOptions.Standards.
+const (
+	AQIStandardUS = "us"
+	AQIStandardEU = "eu"
+	AQIStandardUK = "uk"
+	AQIStandardCA = "ca"
+)
+
+// DefaultAQIStandards are the AQI standards calculated if none are given.
+var DefaultAQIStandards = []string{AQIStandardUS, AQIStandardEU}
+
+// caqiBreakpoints are the upper concentration bounds (ug/m^3) for the CAQI index
+// levels 25, 50, 75, and 100, per the hourly background CAQI grid.
+// See https://www.airqualitynow.eu/about_indices_definition.php
+var caqiBreakpoints = map[string][4]float64{
+	"no2":  {50, 100, 200, 400},
+	"pm10": {25, 50, 90, 180},
+	"o3":   {60, 120, 180, 240},
+	"pm25": {15, 30, 55, 110},
+	"co":   {5000, 7500, 10000, 20000},
+	"so2":  {50, 100, 350, 500},
+}
+
+// CAQIPollutant calculates the Common Air Quality Index sub-index for a single pollutant,
+// identified by its field name (e.g. "pm25"). Values above 100 are extrapolated linearly.
+func CAQIPollutant(pollutant string, concentration float64) (float64, bool) {
+	bp, ok := caqiBreakpoints[pollutant]
+	if !ok || concentration < 0 {
+		return 0, false
+	}
+	lo := 0.0
+	for i, hi := range bp {
+		if concentration <= hi || i == len(bp)-1 {
+			return 25*float64(i) + 25*(concentration-lo)/(hi-lo), true
+		}
+		lo = hi
+	}
+	return 0, false
+}
+
+// CAQI calculates the European Common Air Quality Index from the given pollutant
+// concentrations (keyed by field name), returning the highest sub-index.
+func CAQI(concentrations map[string]float64) float64 {
+	caqi := 0.0
+	for p, c := range concentrations {
+		if idx, ok := CAQIPollutant(p, c); ok {
+			caqi = math.Max(caqi, idx)
+		}
+	}
+	return caqi
+}
+
+// CAQIName returns the CAQI category name for the given index value.
+func CAQIName(caqi float64) string {
+	switch {
+	case caqi < 25:
+		return "Very Low"
+	case caqi < 50:
+		return "Low"
+	case caqi < 75:
+		return "Medium"
+	case caqi <= 100:
+		return "High"
+	default:
+		return "Very High"
+	}
+}
+
+// daqiBreakpoints are the upper concentration bounds (ug/m^3) for UK DAQI index bands 1-9;
+// concentrations above the last bound are band 10.
+// See https://uk-air.defra.gov.uk/air-pollution/daqi?view=more-info
+var daqiBreakpoints = map[string][9]float64{
+	"o3":   {33, 66, 100, 120, 140, 160, 187, 213, 240},
+	"no2":  {67, 134, 200, 267, 334, 400, 467, 534, 600},
+	"so2":  {88, 177, 266, 354, 443, 532, 710, 887, 1064},
+	"pm25": {11, 23, 35, 41, 47, 53, 58, 64, 70},
+	"pm10": {16, 33, 50, 58, 66, 75, 83, 91, 100},
+}
+
+// DAQI calculates the UK Daily Air Quality Index (1-10) from the given pollutant
+// concentrations (keyed by field name), returning the highest band.
+// nb. DAQI is defined over averaging periods (e.g. 24-hour mean PM) that OpenWeatherMap's
+// current readings don't provide, so this is an instantaneous approximation.
+func DAQI(concentrations map[string]float64) int {
+	daqi := 1
+	for p, c := range concentrations {
+		bp, ok := daqiBreakpoints[p]
+		if !ok {
+			continue
+		}
+		band := 1
+		for _, hi := range bp {
+			if math.Round(c) > hi {
+				band++
+			}
+		}
+		if band > daqi {
+			daqi = band
+		}
+	}
+	return daqi
+}
+
+// DAQIName returns the DAQI band name for the given index value.
+func DAQIName(daqi int) string {
+	switch {
+	case daqi <= 3:
+		return "Low"
+	case daqi <= 6:
+		return "Moderate"
+	case daqi <= 9:
+		return "High"
+	default:
+		return "Very High"
+	}
+}
+
+const (
+	// ppb = ug/m^3 * molarVolume / molecularWeight, at 25 degC and 1 atm
+	molarVolumeLiters  = 24.45
+	molecularWeightNO2 = 46.0055
+	molecularWeightO3  = 48.00
+)
+
+// AQHI calculates the Canadian Air Quality Health Index from NO2, O3, and PM2.5
+// concentrations (ug/m^3).
+// nb. AQHI is defined over 3-hour averages, so this is an instantaneous approximation.
+// See https://www.canada.ca/en/environment-climate-change/services/air-quality-health-index/about.html
+func AQHI(no2, o3, pm25 float64) float64 {
+	no2ppb := no2 * molarVolumeLiters / molecularWeightNO2
+	o3ppb := o3 * molarVolumeLiters / molecularWeightO3
+	return (1000.0 / 10.4) * ((math.Exp(0.000871*no2ppb) - 1) + (math.Exp(0.000537*o3ppb) - 1) + (math.Exp(0.000487*pm25) - 1))
+}
+
+// AQHIName returns the AQHI health risk category name for the given index value.
+func AQHIName(aqhi float64) string {
+	switch r := math.Round(aqhi); {
+	case r <= 3:
+		return "Low Risk"
+	case r <= 6:
+		return "Moderate Risk"
+	case r <= 10:
+		return "High Risk"
+	default:
+		return "Very High Risk"
+	}
+}
+
+// DominantPollutantUS returns the display name of the pollutant with the highest US EPA AQI
+// sub-index among the given measurements, which are keyed by display name (e.g. "PM2.5").
+func DominantPollutantUS(measurements map[string]aqi.Measurement) (string, error) {
+	names := make([]string, 0, len(measurements))
+	for name := range measurements {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	dominant := ""
+	highest := -1.0
+	for _, name := range names {
+		r, err := aqi.Calculate(measurements[name])
+		if err != nil {
+			return "", fmt.Errorf("failed to calculate AQI for %s: %w", name, err)
+		}
+		if r.AQI > highest {
+			highest = r.AQI
+			dominant = name
+		}
+	}
+	return dominant, nil
+}
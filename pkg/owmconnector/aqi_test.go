@@ -0,0 +1,184 @@
+package owmconnector
+
+import (
+	"math"
+	"testing"
+
+	"github.com/mrflynn/go-aqi"
+)
+
+func TestCAQIPollutant(t *testing.T) {
+	tests := []struct {
+		pollutant     string
+		concentration float64
+		want          float64
+		wantOK        bool
+	}{
+		{"pm25", 0, 0, true},
+		{"pm25", 15, 25, true},
+		{"pm25", 20, 25 + 25*5.0/15, true},
+		{"pm25", 110, 100, true},
+		{"pm25", 220, 150, true}, // extrapolated above 100
+		{"no2", 300, 75 + 25*100.0/200, true},
+		{"co", 5000, 25, true},
+		{"nh3", 10, 0, false},
+		{"pm25", -1, 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := CAQIPollutant(tt.pollutant, tt.concentration)
+		if ok != tt.wantOK || math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("CAQIPollutant(%q, %v) = %v, %v; want %v, %v", tt.pollutant, tt.concentration, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+func TestCAQI(t *testing.T) {
+	tests := []struct {
+		name           string
+		concentrations map[string]float64
+		want           float64
+	}{
+		{"highest sub-index", map[string]float64{"pm25": 20, "no2": 40, "o3": 30}, 25 + 25*5.0/15},
+		{"unsupported pollutants are ignored", map[string]float64{"no2": 50, "nh3": 1000, "no": 1000}, 25},
+		{"no pollutants", map[string]float64{}, 0},
+	}
+	for _, tt := range tests {
+		if got := CAQI(tt.concentrations); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("CAQI(%s) = %v; want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestCAQIName(t *testing.T) {
+	tests := []struct {
+		caqi float64
+		want string
+	}{
+		{0, "Very Low"},
+		{24.9, "Very Low"},
+		{25, "Low"},
+		{50, "Medium"},
+		{75, "High"},
+		{100, "High"},
+		{100.1, "Very High"},
+	}
+	for _, tt := range tests {
+		if got := CAQIName(tt.caqi); got != tt.want {
+			t.Errorf("CAQIName(%v) = %q; want %q", tt.caqi, got, tt.want)
+		}
+	}
+}
+
+func TestDAQI(t *testing.T) {
+	tests := []struct {
+		name           string
+		concentrations map[string]float64
+		want           int
+	}{
+		{"no pollutants", map[string]float64{}, 1},
+		{"top of band 1", map[string]float64{"pm25": 11}, 1},
+		{"rounded down into band 1", map[string]float64{"pm25": 11.4}, 1},
+		{"rounded up into band 2", map[string]float64{"pm25": 11.5}, 2},
+		{"band 10", map[string]float64{"pm25": 71}, 10},
+		{"highest band", map[string]float64{"pm25": 5, "o3": 101, "no2": 10}, 4},
+		{"unsupported pollutants are ignored", map[string]float64{"co": 100000, "nh3": 1000}, 1},
+	}
+	for _, tt := range tests {
+		if got := DAQI(tt.concentrations); got != tt.want {
+			t.Errorf("DAQI(%s) = %d; want %d", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestDAQIName(t *testing.T) {
+	tests := []struct {
+		daqi int
+		want string
+	}{
+		{1, "Low"},
+		{3, "Low"},
+		{4, "Moderate"},
+		{6, "Moderate"},
+		{7, "High"},
+		{9, "High"},
+		{10, "Very High"},
+	}
+	for _, tt := range tests {
+		if got := DAQIName(tt.daqi); got != tt.want {
+			t.Errorf("DAQIName(%d) = %q; want %q", tt.daqi, got, tt.want)
+		}
+	}
+}
+
+func TestAQHI(t *testing.T) {
+	tests := []struct {
+		no2, o3, pm25 float64
+		want          float64
+	}{
+		{0, 0, 0, 0},
+		// 24.45 ppb each of NO2 and O3
+		{46.0055, 48.00, 10, 3.8098487578987523},
+		{40, 60, 25, 4.565902391188617},
+	}
+	for _, tt := range tests {
+		if got := AQHI(tt.no2, tt.o3, tt.pm25); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("AQHI(%v, %v, %v) = %v; want %v", tt.no2, tt.o3, tt.pm25, got, tt.want)
+		}
+	}
+}
+
+func TestAQHIName(t *testing.T) {
+	tests := []struct {
+		aqhi float64
+		want string
+	}{
+		{1, "Low Risk"},
+		{3.4, "Low Risk"},
+		{3.5, "Moderate Risk"},
+		{6.4, "Moderate Risk"},
+		{6.5, "High Risk"},
+		{10.4, "High Risk"},
+		{10.5, "Very High Risk"},
+	}
+	for _, tt := range tests {
+		if got := AQHIName(tt.aqhi); got != tt.want {
+			t.Errorf("AQHIName(%v) = %q; want %q", tt.aqhi, got, tt.want)
+		}
+	}
+}
+
+func TestDominantPollutantUS(t *testing.T) {
+	tests := []struct {
+		name         string
+		measurements map[string]aqi.Measurement
+		want         string
+		wantErr      bool
+	}{
+		{
+			"highest sub-index",
+			map[string]aqi.Measurement{"PM2.5": aqi.PM25{Concentration: 35.5}, "PM10": aqi.PM10{Concentration: 50}},
+			"PM2.5", false,
+		},
+		{
+			"a tie goes to the first name",
+			map[string]aqi.Measurement{"PM2.5": aqi.PM25{Concentration: 0}, "PM10": aqi.PM10{Concentration: 0}},
+			"PM10", false,
+		},
+		{"no measurements", map[string]aqi.Measurement{}, "", false},
+		{
+			"negative concentration",
+			map[string]aqi.Measurement{"PM2.5": aqi.PM25{Concentration: -1}},
+			"", true,
+		},
+	}
+	for _, tt := range tests {
+		got, err := DominantPollutantUS(tt.measurements)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("DominantPollutantUS(%s) error = %v; want error %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("DominantPollutantUS(%s) = %q; want %q", tt.name, got, tt.want)
+		}
+	}
+}
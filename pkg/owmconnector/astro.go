@@ -0,0 +1,96 @@
+package owmconnector
+
+import (
+	"math"
+	"time"
+)
+
+const (
+	degToRad = math.Pi / 180.0
+	radToDeg = 180.0 / math.Pi
+
+	unixEpochJulianDay = 2440587.5
+	j2000JulianDay     = 2451545.0
+)
+
+// julianDay returns the Julian day number (with fractional day) for the given time.
+func julianDay(t time.Time) float64 {
+	return float64(t.UTC().UnixNano())/float64(24*time.Hour) + unixEpochJulianDay
+}
+
+// normalizeDegrees returns d normalized to [0, 360).
+func normalizeDegrees(d float64) float64 {
+	d = math.Mod(d, 360.0)
+	if d < 0 {
+		d += 360.0
+	}
+	return d
+}
+
+// SolarPosition calculates the sun's elevation above the horizon and its azimuth
+// (clockwise from true north), both in degrees, for the given location and time.
+// It uses the low-precision algorithm from the Astronomical Almanac, which is
+// accurate to about 0.01 degrees for dates between 1950 and 2050.
+func SolarPosition(t time.Time, lat, lon float64) (elevation, azimuth float64) {
+	n := julianDay(t) - j2000JulianDay
+
+	meanLon := normalizeDegrees(280.460 + 0.9856474*n)
+	meanAnomaly := normalizeDegrees(357.528+0.9856003*n) * degToRad
+	eclipticLon := (meanLon + 1.915*math.Sin(meanAnomaly) + 0.020*math.Sin(2*meanAnomaly)) * degToRad
+	obliquity := (23.439 - 0.0000004*n) * degToRad
+
+	rightAscension := math.Atan2(math.Cos(obliquity)*math.Sin(eclipticLon), math.Cos(eclipticLon))
+	declination := math.Asin(math.Sin(obliquity) * math.Sin(eclipticLon))
+
+	gmstHours := math.Mod(18.697374558+24.06570982441908*n, 24.0)
+	hourAngle := (normalizeDegrees(gmstHours*15.0+lon) * degToRad) - rightAscension
+
+	latRad := lat * degToRad
+	elevationRad := math.Asin(math.Sin(latRad)*math.Sin(declination) + math.Cos(latRad)*math.Cos(declination)*math.Cos(hourAngle))
+	azimuthRad := math.Atan2(-math.Sin(hourAngle), math.Tan(declination)*math.Cos(latRad)-math.Sin(latRad)*math.Cos(hourAngle))
+
+	return elevationRad * radToDeg, normalizeDegrees(azimuthRad * radToDeg)
+}
+
+// ClearSkyGHI estimates clear-sky global horizontal irradiance (W/m^2) for the given
+// solar elevation (degrees) using the Haurwitz model.
+func ClearSkyGHI(solarElevation float64) float64 {
+	if solarElevation <= 0 {
+		return 0
+	}
+	cosZenith := math.Sin(solarElevation * degToRad)
+	return 1098.0 * cosZenith * math.Exp(-0.059/cosZenith)
+}
+
+// CloudAdjustedGHI reduces a clear-sky irradiance estimate for the given cloud cover
+// (percent) using the Kasten-Czeplak relationship.
+func CloudAdjustedGHI(clearSkyGHI float64, cloudCoverPercent int) float64 {
+	c := math.Max(0, math.Min(1, float64(cloudCoverPercent)/100.0))
+	return clearSkyGHI * (1 - 0.75*math.Pow(c, 3.4))
+}
+
+const (
+	synodicMonthDays       = 29.530588853
+	referenceNewMoonJulian = 2451550.1 // 2000-01-06 18:14 UTC
+)
+
+// MoonPhase returns the moon's phase at the given time as a fraction of the synodic month
+// (0 = new moon, 0.5 = full moon), and the illuminated fraction of its disk (0-1).
+func MoonPhase(t time.Time) (phase, illumination float64) {
+	age := math.Mod(julianDay(t)-referenceNewMoonJulian, synodicMonthDays)
+	if age < 0 {
+		age += synodicMonthDays
+	}
+	phase = age / synodicMonthDays
+	illumination = (1 - math.Cos(2*math.Pi*phase)) / 2
+	return phase, illumination
+}
+
+// MoonPhaseName returns the conventional name for the given moon phase fraction.
+func MoonPhaseName(phase float64) string {
+	names := []string{
+		"New Moon", "Waxing Crescent", "First Quarter", "Waxing Gibbous",
+		"Full Moon", "Waning Gibbous", "Last Quarter", "Waning Crescent",
+	}
+	return names[int(math.Floor(phase*8+0.5))%8]
+}
@@ -0,0 +1,77 @@
+package owmconnector
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	owm "github.com/briandowns/openweathermap"
+)
+
+// DefaultTimeout is the HTTP timeout used for OpenWeatherMap requests if Client.HTTPClient
+// isn't set.
+const DefaultTimeout = 15 * time.Second
+
+// Client fetches observations for a single location from OpenWeatherMap.
+type Client struct {
+	APIKey    string
+	Latitude  float64
+	Longitude float64
+	// ElevationMeters is optional; see NewObservation.
+	ElevationMeters *float64
+	// HTTPClient is used for all requests. If nil, a client with DefaultTimeout is used.
+	HTTPClient *http.Client
+}
+
+// Name returns "openweathermap".
+func (c *Client) Name() string { return ProviderOpenWeatherMap }
+
+func (c *Client) httpClient() *http.Client {
+	if c.HTTPClient == nil {
+		c.HTTPClient = &http.Client{Timeout: DefaultTimeout}
+	}
+	return c.HTTPClient
+}
+
+func (c *Client) coordinates() *owm.Coordinates {
+	return &owm.Coordinates{Latitude: c.Latitude, Longitude: c.Longitude}
+}
+
+// FetchWeather fetches the raw current weather response, in imperial units.
+func (c *Client) FetchWeather() (*owm.CurrentWeatherData, error) {
+	wx, err := owm.NewCurrent("F", "EN", c.APIKey, owm.WithHttpClient(c.httpClient()))
+	if err != nil {
+		return nil, err
+	}
+	if err := wx.CurrentByCoordinates(c.coordinates()); err != nil {
+		return nil, err
+	}
+	return wx, nil
+}
+
+// FetchObservation fetches the current weather observation.
+func (c *Client) FetchObservation() (*Observation, error) {
+	wx, err := c.FetchWeather()
+	if err != nil {
+		return nil, err
+	}
+	return NewObservation(wx, c.Latitude, c.Longitude, c.ElevationMeters), nil
+}
+
+// FetchPollution fetches current air pollution.
+func (c *Client) FetchPollution() (*Pollution, error) {
+	return FetchPollution(c.httpClient(), c.APIKey, *c.coordinates())
+}
+
+// Fetch fetches current weather and air pollution.
+func (c *Client) Fetch() (*Observation, *Pollution, error) {
+	obs, err := c.FetchObservation()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get weather from OpenWeatherMap: %w", err)
+	}
+	pol, err := c.FetchPollution()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get pollution from OpenWeatherMap: %w", err)
+	}
+	return obs, pol, nil
+}
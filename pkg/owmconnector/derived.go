@@ -0,0 +1,172 @@
+package owmconnector
+
+import (
+	"errors"
+	"math"
+
+	"github.com/cdzombak/libwx"
+)
+
+const (
+	metersPerSecondPerMph = 0.44704
+	// MetersPerFoot converts feet to meters.
+	MetersPerFoot = 0.3048
+)
+
+// HumidexMinTempC is the minimum air temperature (degC) at which Environment Canada reports humidex.
+const HumidexMinTempC = 20.0
+
+// Humidex calculates the Canadian humidex from air temperature and dew point.
+// See https://en.wikipedia.org/wiki/Humidex
+func Humidex(temp libwx.TempC, dewPoint libwx.TempC) float64 {
+	e := 6.11 * math.Exp(5417.7530*((1/273.16)-(1/(273.15+dewPoint.Unwrap()))))
+	return temp.Unwrap() + 0.5555*(e-10.0)
+}
+
+// HumidexWithValidation calculates the humidex, returning an error if the
+// temperature is below the range where humidex is meaningful.
+func HumidexWithValidation(temp libwx.TempC, dewPoint libwx.TempC) (float64, error) {
+	if temp.Unwrap() < HumidexMinTempC {
+		return 0, errors.New("humidex is only meaningful for temperatures >= 20 degC")
+	}
+	return Humidex(temp, dewPoint), nil
+}
+
+// ApparentTempC calculates the Australian Bureau of Meteorology apparent temperature
+// (Steadman's non-radiation formula), which accounts for humidity and wind across
+// the full temperature range.
+// See http://www.bom.gov.au/info/thermal_stress/#atapproximation
+func ApparentTempC(temp libwx.TempC, rh libwx.RelHumidity, windSpeed libwx.SpeedMph) libwx.TempC {
+	ta := temp.Unwrap()
+	e := (rh.UnwrapFloat64() / 100.0) * 6.105 * math.Exp((17.27*ta)/(237.7+ta))
+	ws := windSpeed.Unwrap() * metersPerSecondPerMph
+	return libwx.TempC(ta + 0.33*e - 0.70*ws - 4.00)
+}
+
+// SaturationVaporPressureKPa calculates the saturation vapor pressure (kPa) over water
+// at the given temperature using the Tetens equation.
+func SaturationVaporPressureKPa(temp libwx.TempC) float64 {
+	t := temp.Unwrap()
+	return 0.6108 * math.Exp((17.27*t)/(t+237.3))
+}
+
+// VaporPressureDeficitKPa calculates the vapor pressure deficit (kPa): the difference
+// between saturation vapor pressure and actual vapor pressure.
+func VaporPressureDeficitKPa(temp libwx.TempC, rh libwx.RelHumidity) float64 {
+	return SaturationVaporPressureKPa(temp) * (1.0 - rh.UnwrapFloat64()/100.0)
+}
+
+// StationPressureMb converts sea-level pressure to station pressure at the given
+// elevation (meters) using the standard atmosphere barometric formula.
+func StationPressureMb(seaLevel libwx.PressureMb, elevationM float64) libwx.PressureMb {
+	return libwx.PressureMb(seaLevel.Unwrap() * math.Pow(1-(0.0065*elevationM)/288.15, 5.25588))
+}
+
+// PressureAltitudeFt calculates pressure altitude (feet): the altitude in the standard
+// atmosphere at which the given station pressure occurs.
+func PressureAltitudeFt(stationPressure libwx.PressureMb) float64 {
+	return (1 - math.Pow(stationPressure.Unwrap()/1013.25, 0.190284)) * 145366.45
+}
+
+// DensityAltitudeFt calculates density altitude (feet) from pressure altitude and
+// outside air temperature, using the standard aviation approximation.
+func DensityAltitudeFt(pressureAltitudeFt float64, temp libwx.TempC) float64 {
+	isaTempC := 15.0 - 1.98*(pressureAltitudeFt/1000.0)
+	return pressureAltitudeFt + 118.8*(temp.Unwrap()-isaTempC)
+}
+
+// AirDensityKgM3 calculates the density (kg/m^3) of moist air at the given
+// temperature, (station) pressure, and relative humidity.
+func AirDensityKgM3(temp libwx.TempC, pressure libwx.PressureMb, rh libwx.RelHumidity) float64 {
+	const (
+		rDryAir      = 287.058 // J/(kg·K)
+		rWaterVapor  = 461.495 // J/(kg·K)
+		paPerMb      = 100.0
+		paPerKPa     = 1000.0
+		kelvinOffset = 273.15
+	)
+	tK := temp.Unwrap() + kelvinOffset
+	pv := SaturationVaporPressureKPa(temp) * paPerKPa * (rh.UnwrapFloat64() / 100.0)
+	pd := pressure.Unwrap()*paPerMb - pv
+	return pd/(rDryAir*tK) + pv/(rWaterVapor*tK)
+}
+
+// RelHumidityFromDewPoint calculates the relative humidity from the temperature and dew
+// point, inverting the Magnus formula libwx.DewPointC uses.
+func RelHumidityFromDewPoint(temp libwx.TempC, dewPoint libwx.TempC) libwx.RelHumidity {
+	const (
+		a = 17.625
+		b = 243.04
+	)
+	t, td := temp.Unwrap(), dewPoint.Unwrap()
+	rh := 100 * math.Exp(a*td/(b+td)-a*t/(b+t))
+	return libwx.ClampedRelHumidity(int(math.Round(rh)))
+}
+
+// FrostPointC calculates the frost point: the temperature at which air becomes saturated
+// with respect to ice. It uses the Magnus formula over ice. ok is false if the frost point
+// is undefined, i.e. at 0% relative humidity.
+func FrostPointC(temp libwx.TempC, rh libwx.RelHumidity) (frostPoint libwx.TempC, ok bool) {
+	const hPaPerKPa = 10.0
+	e := SaturationVaporPressureKPa(temp) * hPaPerKPa * (rh.UnwrapFloat64() / 100.0)
+	if e <= 0 {
+		return 0, false
+	}
+	g := math.Log(e / 6.1115)
+	return libwx.TempC(272.55 * g / (22.452 - g)), true
+}
+
+const (
+	frostRiskMaxTempC     = 3.0
+	frostRiskMaxPointC    = 0.5
+	frostRiskMaxWindSpeed = libwx.SpeedMph(5.0)
+)
+
+// FrostRisk reports whether conditions favor frost formation: air temperature near freezing,
+// frost point at or below freezing, and light wind (which allows radiative cooling at the surface).
+func FrostRisk(temp libwx.TempC, frostPoint libwx.TempC, windSpeed libwx.SpeedMph) bool {
+	return temp.Unwrap() <= frostRiskMaxTempC &&
+		frostPoint.Unwrap() <= frostRiskMaxPointC &&
+		windSpeed <= frostRiskMaxWindSpeed
+}
+
+// GlobeTempEstimateC estimates black globe temperature from air temperature, wind speed,
+// and incident solar radiation (W/m^2), from a simplified energy balance on a standard
+// 150mm black globe with ISO 7726 forced-convection heat transfer.
+func GlobeTempEstimateC(temp libwx.TempC, windSpeed libwx.SpeedMph, solarWm2 float64) libwx.TempC {
+	const (
+		globeDiameterM    = 0.15
+		globeAbsorptivity = 0.95
+		radiativeCoeff    = 6.0 // W/(m^2·K), linearized longwave exchange
+		minWindSpeedMS    = 0.5
+	)
+	v := math.Max(minWindSpeedMS, windSpeed.Unwrap()*metersPerSecondPerMph)
+	convectiveCoeff := 6.3 * math.Pow(v, 0.6) / math.Pow(globeDiameterM, 0.4)
+	// a sphere's surface area is 4x its projected area, so it absorbs 1/4 of direct solar per unit surface:
+	absorbed := globeAbsorptivity * math.Max(0, solarWm2) / 4.0
+	return libwx.TempC(temp.Unwrap() + absorbed/(convectiveCoeff+radiativeCoeff))
+}
+
+// WBGTEstimateC estimates the outdoor wet bulb globe temperature from an estimated
+// globe temperature; the psychrometric wet bulb temperature approximates the natural
+// wet bulb temperature.
+func WBGTEstimateC(temp libwx.TempC, wetBulb libwx.TempC, globe libwx.TempC) libwx.TempC {
+	return libwx.TempC(0.7*wetBulb.Unwrap() + 0.2*globe.Unwrap() + 0.1*temp.Unwrap())
+}
+
+// WBGTCategory returns the heat stress flag category for the given WBGT,
+// per the flag system used by the US military and ACSM.
+func WBGTCategory(wbgt libwx.TempF) string {
+	switch {
+	case wbgt < 80:
+		return "white"
+	case wbgt < 85:
+		return "green"
+	case wbgt < 88:
+		return "yellow"
+	case wbgt < 90:
+		return "red"
+	default:
+		return "black"
+	}
+}
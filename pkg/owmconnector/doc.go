@@ -0,0 +1,9 @@
+// Package owmconnector fetches current weather and air pollution from OpenWeatherMap (or
+// Open-Meteo; see Provider) and calculates the derived metrics (heat index, wind chill,
+// WBGT, solar position, AQIs, and so on) that openweather-influxdb-connector writes, so
+// other Go programs can embed the same logic.
+//
+// The connector's stateful features (pressure trends, degree days, smoothing, and so on)
+// and its outputs remain in the CLI. NowCast is calculated here from a PM history the
+// caller keeps; see PollutionOptions.
+package owmconnector
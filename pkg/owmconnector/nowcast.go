@@ -0,0 +1,82 @@
+package owmconnector
+
+import (
+	"math"
+	"time"
+)
+
+const (
+	// NowCastHours is the length of the NowCast window, in hours.
+	NowCastHours         = 12
+	nowCastMinWeight     = 0.5
+	nowCastRecentHours   = 3
+	nowCastMinRecentData = 2
+)
+
+// PMReading is a single historical particulate matter observation.
+type PMReading struct {
+	Time time.Time `json:"time"`
+	PM25 float64   `json:"pm25"`
+	PM10 float64   `json:"pm10"`
+}
+
+// NowCastPM calculates the EPA NowCast PM2.5 and PM10 concentrations as of t from the
+// given history. The boolean return value is false if there isn't enough recent data
+// (at least 2 of the 3 most recent hours).
+// See https://www.airnow.gov/sites/default/files/2020-05/aqi-technical-assistance-document-sept2018.pdf
+func NowCastPM(history []PMReading, t time.Time) (pm25, pm10 float64, ok bool) {
+	var sums25, sums10 [NowCastHours]float64
+	var counts [NowCastHours]int
+	for _, r := range history {
+		age := t.Sub(r.Time)
+		if age < 0 {
+			continue
+		}
+		h := int(age / time.Hour)
+		if h >= NowCastHours {
+			continue
+		}
+		sums25[h] += r.PM25
+		sums10[h] += r.PM10
+		counts[h]++
+	}
+
+	recent := 0
+	for h := 0; h < nowCastRecentHours; h++ {
+		if counts[h] > 0 {
+			recent++
+		}
+	}
+	if recent < nowCastMinRecentData {
+		return 0, 0, false
+	}
+
+	hourly := func(sums [NowCastHours]float64) float64 {
+		var avgs [NowCastHours]float64
+		lo, hi := math.Inf(1), math.Inf(-1)
+		for h := 0; h < NowCastHours; h++ {
+			if counts[h] == 0 {
+				continue
+			}
+			avgs[h] = sums[h] / float64(counts[h])
+			lo = math.Min(lo, avgs[h])
+			hi = math.Max(hi, avgs[h])
+		}
+		w := nowCastMinWeight
+		if hi > 0 {
+			w = math.Max(nowCastMinWeight, lo/hi)
+		}
+		num, den := 0.0, 0.0
+		for h := 0; h < NowCastHours; h++ {
+			if counts[h] == 0 {
+				continue
+			}
+			weight := math.Pow(w, float64(h))
+			num += weight * avgs[h]
+			den += weight
+		}
+		return num / den
+	}
+
+	return hourly(sums25), hourly(sums10), true
+}
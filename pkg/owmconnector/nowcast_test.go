@@ -0,0 +1,78 @@
+package owmconnector
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestNowCastPM(t *testing.T) {
+	now := time.Date(2024, 7, 4, 12, 0, 0, 0, time.UTC)
+	// hourly builds a PM history with one reading per given hour ago; a negative value
+	// leaves that hour without a reading.
+	hourly := func(pm25 ...float64) []PMReading {
+		var history []PMReading
+		for h := len(pm25) - 1; h >= 0; h-- {
+			if pm25[h] < 0 {
+				continue
+			}
+			history = append(history, PMReading{
+				Time: now.Add(-time.Duration(h) * time.Hour),
+				PM25: pm25[h],
+				PM10: 2 * pm25[h],
+			})
+		}
+		return history
+	}
+
+	tests := []struct {
+		name    string
+		history []PMReading
+		want25  float64
+		wantOK  bool
+	}{
+		{"constant", hourly(10, 10, 10, 10, 10, 10, 10, 10, 10, 10, 10, 10), 10, true},
+		{"weight factor", hourly(10, 8), (10 + 0.8*8) / 1.8, true},
+		{"weight factor of exactly 0.5", hourly(20, 10), (20 + 0.5*10) / 1.5, true},
+		{"weight floor", hourly(40, 10), (40 + 0.5*10) / 1.5, true},
+		{"weight floor with zero readings", hourly(0, 0, 0), 0, true},
+		{"gap in the most recent hours", hourly(10, -1, 8), (10 + 0.64*8) / 1.64, true},
+		{"gap in older hours", hourly(10, 10, -1, -1, -1, 10), 10, true},
+		{"only the most recent hour", hourly(10, -1, -1, 10, 10, 10), 0, false},
+		{"only the third most recent hour", hourly(-1, -1, 10, 10), 0, false},
+		{"no recent hours", hourly(-1, -1, -1, 10, 10), 0, false},
+		{"no history", nil, 0, false},
+		{
+			"readings outside the window are ignored",
+			append([]PMReading{{Time: now.Add(-12 * time.Hour), PM25: 1000, PM10: 2000}}, hourly(10, 10)...),
+			10, true,
+		},
+		{
+			"future readings are ignored",
+			append(hourly(10, 10), PMReading{Time: now.Add(time.Hour), PM25: 1000, PM10: 2000}),
+			10, true,
+		},
+		{
+			"readings in the same hour are averaged",
+			append(hourly(-1, 10), PMReading{Time: now.Add(-10 * time.Minute), PM25: 4, PM10: 8}, PMReading{Time: now, PM25: 8, PM10: 16}),
+			(6 + 0.6*10) / 1.6, true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pm25, pm10, ok := NowCastPM(tt.history, now)
+			if ok != tt.wantOK {
+				t.Fatalf("NowCastPM() ok = %v; want %v", ok, tt.wantOK)
+			}
+			if !ok {
+				return
+			}
+			if math.Abs(pm25-tt.want25) > 1e-9 {
+				t.Errorf("NowCastPM() pm25 = %v; want %v", pm25, tt.want25)
+			}
+			if math.Abs(pm10-2*tt.want25) > 1e-9 {
+				t.Errorf("NowCastPM() pm10 = %v; want %v", pm10, 2*tt.want25)
+			}
+		})
+	}
+}
@@ -0,0 +1,343 @@
+package owmconnector
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/cdzombak/libwx"
+)
+
+// DefaultNWSUserAgent is the User-Agent sent to the NWS API if NWSClient.UserAgent isn't set.
+// NWS requires every request to identify the application making it.
+const DefaultNWSUserAgent = "openweather-influxdb-connector (https://github.com/cdzombak/openweather-influxdb-connector)"
+
+// NWSClient fetches observations for a single US location from the National Weather
+// Service API, using the latest observation from the station nearest the location.
+// See https://www.weather.gov/documentation/services-web-api
+type NWSClient struct {
+	Latitude  float64
+	Longitude float64
+	// ElevationMeters is optional; see NewObservation.
+	ElevationMeters *float64
+	// Station is the observation station ID (e.g. "KARB"). If empty, the station nearest
+	// the location is looked up on every fetch.
+	Station string
+	// UserAgent identifies the application to NWS. If empty, DefaultNWSUserAgent is used.
+	UserAgent string
+	// PollutionProvider is used for FetchPollution, since NWS doesn't report air quality.
+	// If nil, Open-Meteo is used.
+	PollutionProvider Provider
+	// HTTPClient is used for all requests. If nil, a client with DefaultTimeout is used.
+	HTTPClient *http.Client
+}
+
+// NWSAlert is an active NWS alert (watch, warning, advisory, etc.) for a location.
+type NWSAlert struct {
+	Event    string
+	Severity string
+	Headline string
+	Onset    time.Time
+	Expires  time.Time
+}
+
+// nwsSeverities lists NWS alert severities, from least to most severe.
+var nwsSeverities = []string{"Unknown", "Minor", "Moderate", "Severe", "Extreme"}
+
+// NWSAlertSeverityRank returns the rank of the given NWS alert severity, from 0 ("Unknown"
+// or unrecognized) to 4 ("Extreme").
+func NWSAlertSeverityRank(severity string) int {
+	for i, s := range nwsSeverities {
+		if s == severity {
+			return i
+		}
+	}
+	return 0
+}
+
+// Name returns "nws".
+func (c *NWSClient) Name() string { return ProviderNWS }
+
+func (c *NWSClient) httpClient() *http.Client {
+	if c.HTTPClient == nil {
+		c.HTTPClient = &http.Client{Timeout: DefaultTimeout}
+	}
+	return c.HTTPClient
+}
+
+// get fetches the given NWS API URL and decodes its GeoJSON response into v.
+func (c *NWSClient) get(u string, v interface{}) error {
+	req, err := http.NewRequest(http.MethodGet, u, nil)
+	if err != nil {
+		return err
+	}
+	ua := c.UserAgent
+	if ua == "" {
+		ua = DefaultNWSUserAgent
+	}
+	req.Header.Set("User-Agent", ua)
+	req.Header.Set("Accept", "application/geo+json")
+	resp, err := c.httpClient().Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("NWS API returned %s", resp.Status)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("failed to decode NWS response: %w", err)
+	}
+	return nil
+}
+
+func (c *NWSClient) point() string {
+	return fmt.Sprintf("%.4f,%.4f", c.Latitude, c.Longitude)
+}
+
+// station returns the configured station, or looks up the station nearest the location.
+func (c *NWSClient) station() (string, error) {
+	if c.Station != "" {
+		return c.Station, nil
+	}
+	var point struct {
+		Properties struct {
+			ObservationStations string `json:"observationStations"`
+		} `json:"properties"`
+	}
+	if err := c.get("https://api.weather.gov/points/"+c.point(), &point); err != nil {
+		return "", err
+	}
+	if point.Properties.ObservationStations == "" {
+		return "", errors.New("NWS didn't return observation stations for the location")
+	}
+	// nb. stations are sorted by distance from the point
+	var stations struct {
+		Features []struct {
+			Properties struct {
+				StationIdentifier string `json:"stationIdentifier"`
+			} `json:"properties"`
+		} `json:"features"`
+	}
+	if err := c.get(point.Properties.ObservationStations, &stations); err != nil {
+		return "", err
+	}
+	if len(stations.Features) == 0 {
+		return "", errors.New("NWS didn't return any observation stations for the location")
+	}
+	return stations.Features[0].Properties.StationIdentifier, nil
+}
+
+// nwsValue is a quantitative value in an NWS response. Value is nil if the station didn't
+// report it.
+type nwsValue struct {
+	Value    *float64 `json:"value"`
+	UnitCode string   `json:"unitCode"`
+}
+
+// FetchObservation fetches the latest observation from the station.
+func (c *NWSClient) FetchObservation() (*Observation, error) {
+	station, err := c.station()
+	if err != nil {
+		return nil, err
+	}
+	var body struct {
+		Properties struct {
+			Timestamp          time.Time `json:"timestamp"`
+			Icon               string    `json:"icon"`
+			Temperature        nwsValue  `json:"temperature"`
+			Dewpoint           nwsValue  `json:"dewpoint"`
+			WindDirection      nwsValue  `json:"windDirection"`
+			WindSpeed          nwsValue  `json:"windSpeed"`
+			BarometricPressure nwsValue  `json:"barometricPressure"`
+			SeaLevelPressure   nwsValue  `json:"seaLevelPressure"`
+			Visibility         nwsValue  `json:"visibility"`
+			RelativeHumidity   nwsValue  `json:"relativeHumidity"`
+			WindChill          nwsValue  `json:"windChill"`
+			HeatIndex          nwsValue  `json:"heatIndex"`
+			CloudLayers        []struct {
+				Amount string `json:"amount"`
+			} `json:"cloudLayers"`
+		} `json:"properties"`
+	}
+	if err := c.get("https://api.weather.gov/stations/"+station+"/observations/latest", &body); err != nil {
+		return nil, err
+	}
+	p := body.Properties
+	// nb. rather than deriving fields from a zero humidity or pressure, fail so the next
+	// provider is tried
+	if p.Temperature.Value == nil {
+		return nil, fmt.Errorf("NWS station %s didn't report a temperature", station)
+	}
+	if p.RelativeHumidity.Value == nil && p.Dewpoint.Value == nil {
+		return nil, fmt.Errorf("NWS station %s didn't report humidity or dew point", station)
+	}
+	if p.SeaLevelPressure.Value == nil && p.BarometricPressure.Value == nil {
+		return nil, fmt.Errorf("NWS station %s didn't report pressure", station)
+	}
+
+	o := &Observation{
+		Time:            p.Timestamp,
+		Latitude:        c.Latitude,
+		Longitude:       c.Longitude,
+		ElevationMeters: c.ElevationMeters,
+		Temp:            libwx.TempC(*p.Temperature.Value).F(),
+		ConditionID:     owmConditionForNWSIcon(p.Icon),
+	}
+	o.FeelsLike = o.Temp
+	if p.WindChill.Value != nil {
+		o.FeelsLike = libwx.TempC(*p.WindChill.Value).F()
+	} else if p.HeatIndex.Value != nil {
+		o.FeelsLike = libwx.TempC(*p.HeatIndex.Value).F()
+	}
+	if p.RelativeHumidity.Value != nil {
+		o.Humidity = libwx.ClampedRelHumidity(int(*p.RelativeHumidity.Value + 0.5))
+	} else {
+		o.Humidity = RelHumidityFromDewPoint(o.Temp.C(), libwx.TempC(*p.Dewpoint.Value))
+	}
+	// NWS reports pressure in Pa; prefer sea level pressure, for consistency with other providers
+	if p.SeaLevelPressure.Value != nil {
+		o.Pressure = libwx.PressureMb(*p.SeaLevelPressure.Value / 100)
+	} else {
+		o.Pressure = libwx.PressureMb(*p.BarometricPressure.Value / 100)
+	}
+	if p.WindSpeed.Value != nil {
+		if p.WindSpeed.UnitCode == "wmoUnit:m_s-1" {
+			o.WindSpeed = libwx.SpeedKmH(*p.WindSpeed.Value * 3.6).Mph()
+		} else {
+			o.WindSpeed = libwx.SpeedKmH(*p.WindSpeed.Value).Mph()
+		}
+	}
+	if p.WindDirection.Value != nil {
+		o.WindBearing = *p.WindDirection.Value
+	}
+	if p.Visibility.Value != nil {
+		o.VisibilityMiles = libwx.Meter(*p.Visibility.Value).Miles()
+	}
+	for _, l := range p.CloudLayers {
+		o.CloudCoverPercent = max(o.CloudCoverPercent, nwsCloudCoverPercent(l.Amount))
+	}
+	if p.Dewpoint.Value != nil {
+		o.DewPoint = libwx.TempC(*p.Dewpoint.Value).F()
+	} else {
+		o.DewPoint = libwx.DewPointF(o.Temp, o.Humidity)
+	}
+	return o, nil
+}
+
+// FetchPollution fetches current air pollution from the PollutionProvider, or from
+// Open-Meteo if that isn't set.
+func (c *NWSClient) FetchPollution() (*Pollution, error) {
+	p := c.PollutionProvider
+	if p == nil {
+		p = &OpenMeteoClient{Latitude: c.Latitude, Longitude: c.Longitude, HTTPClient: c.httpClient()}
+	}
+	return p.FetchPollution()
+}
+
+// FetchAlerts fetches the NWS alerts currently active for the location, most severe first.
+func (c *NWSClient) FetchAlerts() ([]NWSAlert, error) {
+	var body struct {
+		Features []struct {
+			Properties struct {
+				Event    string    `json:"event"`
+				Severity string    `json:"severity"`
+				Headline string    `json:"headline"`
+				Onset    time.Time `json:"onset"`
+				Expires  time.Time `json:"expires"`
+			} `json:"properties"`
+		} `json:"features"`
+	}
+	if err := c.get("https://api.weather.gov/alerts/active?point="+c.point(), &body); err != nil {
+		return nil, err
+	}
+	alerts := make([]NWSAlert, 0, len(body.Features))
+	for _, f := range body.Features {
+		alerts = append(alerts, NWSAlert(f.Properties))
+	}
+	sort.SliceStable(alerts, func(i, j int) bool {
+		return NWSAlertSeverityRank(alerts[i].Severity) > NWSAlertSeverityRank(alerts[j].Severity)
+	})
+	return alerts, nil
+}
+
+// nwsCloudCoverPercent returns the approximate cloud cover percentage for a METAR cloud
+// layer amount, using the midpoint of the amount's range of oktas.
+func nwsCloudCoverPercent(amount string) int {
+	switch amount {
+	case "FEW":
+		return 19
+	case "SCT":
+		return 44
+	case "BKN":
+		return 75
+	case "OVC", "VV":
+		return 100
+	default: // SKC, CLR
+		return 0
+	}
+}
+
+// owmConditionForNWSIcon returns the OpenWeatherMap condition code closest to the
+// condition in the given NWS icon URL (e.g. https://api.weather.gov/icons/land/day/sct?size=medium),
+// or 0 if there's none.
+// See https://api.weather.gov/icons
+func owmConditionForNWSIcon(icon string) int {
+	if icon == "" {
+		return 0
+	}
+	// icons for mixed conditions are named like ".../day/rain_showers,40/tsra,60"; use the
+	// first condition after the time of day
+	var name string
+	parts := strings.Split(strings.SplitN(icon, "?", 2)[0], "/")
+	for i, p := range parts {
+		if (p == "day" || p == "night") && i+1 < len(parts) {
+			name = strings.SplitN(parts[i+1], ",", 2)[0]
+			break
+		}
+	}
+	switch name {
+	case "skc", "wind_skc", "hot", "cold":
+		return 800
+	case "few", "wind_few":
+		return 801
+	case "sct", "wind_sct":
+		return 802
+	case "bkn", "wind_bkn":
+		return 803
+	case "ovc", "wind_ovc":
+		return 804
+	case "rain", "rain_showers_hi":
+		return 500
+	case "rain_showers":
+		return 521
+	case "snow", "blizzard":
+		return 601
+	case "rain_snow":
+		return 616
+	case "rain_sleet", "snow_sleet", "sleet":
+		return 611
+	case "fzra", "rain_fzra", "snow_fzra":
+		return 511
+	case "tsra", "tsra_sct", "tsra_hi":
+		return 211
+	case "tornado":
+		return 781
+	case "hurricane", "tropical_storm":
+		return 771
+	case "dust":
+		return 761
+	case "smoke":
+		return 711
+	case "haze":
+		return 721
+	case "fog":
+		return 741
+	default:
+		return 0
+	}
+}
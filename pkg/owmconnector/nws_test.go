@@ -0,0 +1,126 @@
+package owmconnector
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/cdzombak/libwx"
+)
+
+// fixtureTransport responds to every request with the fixture for its URL path.
+type fixtureTransport map[string]string
+
+func (f fixtureTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, ok := f[req.URL.Path]
+	status := http.StatusOK
+	if !ok {
+		status = http.StatusNotFound
+	}
+	return &http.Response{
+		StatusCode: status,
+		Status:     http.StatusText(status),
+		Body:       io.NopCloser(strings.NewReader(body)),
+		Request:    req,
+	}, nil
+}
+
+// nwsObservationFixture returns a latest observation response with the given values for
+// the relativeHumidity, dewpoint, seaLevelPressure, and barometricPressure properties.
+func nwsObservationFixture(rh, dewpoint, seaLevelPressure, barometricPressure string) string {
+	return `{"properties": {
+		"timestamp": "2024-07-04T11:53:00+00:00",
+		"icon": "https://api.weather.gov/icons/land/day/few?size=medium",
+		"temperature": {"unitCode": "wmoUnit:degC", "value": 21.1},
+		"dewpoint": {"unitCode": "wmoUnit:degC", "value": ` + dewpoint + `},
+		"windDirection": {"unitCode": "wmoUnit:degree_(angle)", "value": 220},
+		"windSpeed": {"unitCode": "wmoUnit:km_h-1", "value": 9.36},
+		"barometricPressure": {"unitCode": "wmoUnit:Pa", "value": ` + barometricPressure + `},
+		"seaLevelPressure": {"unitCode": "wmoUnit:Pa", "value": ` + seaLevelPressure + `},
+		"visibility": {"unitCode": "wmoUnit:m", "value": 16090},
+		"relativeHumidity": {"unitCode": "wmoUnit:percent", "value": ` + rh + `},
+		"windChill": {"unitCode": "wmoUnit:degC", "value": null},
+		"heatIndex": {"unitCode": "wmoUnit:degC", "value": null},
+		"cloudLayers": [{"amount": "FEW"}]
+	}}`
+}
+
+func TestNWSFetchObservation(t *testing.T) {
+	tests := []struct {
+		name         string
+		fixture      string
+		wantErr      bool
+		wantHumidity libwx.RelHumidity
+		wantPressure libwx.PressureMb
+	}{
+		{
+			name:         "all values",
+			fixture:      nwsObservationFixture("72.6", "15.9", "101660", "98930"),
+			wantHumidity: 73,
+			wantPressure: 1016.6,
+		},
+		{
+			name:         "null sea level pressure",
+			fixture:      nwsObservationFixture("72.6", "15.9", "null", "98930"),
+			wantHumidity: 73,
+			wantPressure: 989.3,
+		},
+		{
+			name:         "null humidity",
+			fixture:      nwsObservationFixture("null", "15.9", "101660", "98930"),
+			wantHumidity: 72,
+			wantPressure: 1016.6,
+		},
+		{
+			name:    "null humidity and dew point",
+			fixture: nwsObservationFixture("null", "null", "101660", "98930"),
+			wantErr: true,
+		},
+		{
+			name:    "null pressures",
+			fixture: nwsObservationFixture("72.6", "15.9", "null", "null"),
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &NWSClient{
+				Latitude:   42.28,
+				Longitude:  -83.74,
+				Station:    "KARB",
+				HTTPClient: &http.Client{Transport: fixtureTransport{"/stations/KARB/observations/latest": tt.fixture}},
+			}
+			o, err := c.FetchObservation()
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("FetchObservation() succeeded with humidity %v and pressure %v; want an error", o.Humidity, o.Pressure)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("FetchObservation() = %v", err)
+			}
+			if o.Humidity != tt.wantHumidity {
+				t.Errorf("Humidity = %v; want %v", o.Humidity, tt.wantHumidity)
+			}
+			if o.Pressure != tt.wantPressure {
+				t.Errorf("Pressure = %v; want %v", o.Pressure, tt.wantPressure)
+			}
+			if got := o.DewPoint.C().Unwrap(); got < 15.8 || got > 16.0 {
+				t.Errorf("DewPoint = %v degC; want 15.9 degC", got)
+			}
+		})
+	}
+}
+
+func TestRelHumidityFromDewPoint(t *testing.T) {
+	for _, rh := range []libwx.RelHumidity{5, 30, 50, 73, 100} {
+		for _, temp := range []libwx.TempC{-20, 0, 21.1, 35} {
+			dewPoint := libwx.DewPointC(temp, rh)
+			if got := RelHumidityFromDewPoint(temp, dewPoint); got != rh {
+				t.Errorf("RelHumidityFromDewPoint(%v, %v) = %v; want %v", temp, dewPoint, got, rh)
+			}
+		}
+	}
+}
@@ -0,0 +1,175 @@
+package owmconnector
+
+import (
+	"time"
+
+	owm "github.com/briandowns/openweathermap"
+	"github.com/cdzombak/libwx"
+)
+
+// Observation is a current weather observation, with the values the connector's derived
+// metrics are calculated from.
+type Observation struct {
+	Time            time.Time
+	Latitude        float64
+	Longitude       float64
+	ElevationMeters *float64
+
+	Temp              libwx.TempF
+	FeelsLike         libwx.TempF
+	Pressure          libwx.PressureMb
+	Humidity          libwx.RelHumidity
+	DewPoint          libwx.TempF
+	WindSpeed         libwx.SpeedMph
+	WindBearing       float64
+	VisibilityMiles   libwx.Mile
+	CloudCoverPercent int
+	// ConditionID is the OpenWeatherMap weather condition code, or 0 if unknown.
+	// See https://openweathermap.org/weather-conditions
+	ConditionID int
+	// Extra holds fields only some providers report (e.g. pollen indices), which are
+	// included in Fields as-is.
+	Extra map[string]interface{}
+}
+
+// NewObservation returns the observation described by the given OpenWeatherMap response,
+// which must have been requested in imperial units ("F"), at the given location.
+// elevationM is optional; if set, station pressure and pressure/density altitude are
+// included in the observation's fields.
+func NewObservation(wx *owm.CurrentWeatherData, lat, lon float64, elevationM *float64) *Observation {
+	// see response docs at: https://openweathermap.org/current#parameter
+	o := &Observation{
+		Time:            time.Unix(int64(wx.Dt), 0),
+		Latitude:        lat,
+		Longitude:       lon,
+		ElevationMeters: elevationM,
+		Temp:            libwx.TempF(wx.Main.Temp),
+		FeelsLike:       libwx.TempF(wx.Main.FeelsLike),
+		// nb. OpenWeatherMap reports pressure in hPa regardless of unit setting; hPa == millibar
+		Pressure:          libwx.PressureMb(wx.Main.Pressure),
+		Humidity:          libwx.ClampedRelHumidity(wx.Main.Humidity),
+		WindSpeed:         libwx.SpeedMph(wx.Wind.Speed),
+		WindBearing:       wx.Wind.Deg,
+		VisibilityMiles:   libwx.Meter(wx.Visibility).Miles(),
+		CloudCoverPercent: wx.Clouds.All,
+	}
+	if len(wx.Weather) > 0 {
+		o.ConditionID = wx.Weather[0].ID
+	}
+	// nb. precipitation is in mm regardless of unit setting, and is omitted if there's
+	// been none in the last hour
+	o.Extra = map[string]interface{}{"precip_1h_mm": wx.Rain.OneH + wx.Snow.OneH}
+	o.DewPoint = libwx.DewPointF(o.Temp, o.Humidity)
+	return o
+}
+
+// WindChill returns the wind chill, or an error if it's undefined for the observation's
+// temperature and wind speed.
+func (o *Observation) WindChill() (libwx.TempF, error) {
+	return libwx.WindChillFWithValidation(o.Temp, o.WindSpeed)
+}
+
+// StationPressure returns the pressure at the observation's elevation, or the reported
+// (sea level) pressure if no elevation is set.
+func (o *Observation) StationPressure() libwx.PressureMb {
+	if o.ElevationMeters == nil {
+		return o.Pressure
+	}
+	return StationPressureMb(o.Pressure, *o.ElevationMeters)
+}
+
+// Fields returns the observation's fields, as written to the weather measurement, including
+// all derived metrics that can be calculated from this observation alone.
+func (o *Observation) Fields() map[string]interface{} {
+	heatIdxF, heatIdxFErr := libwx.HeatIndexFWithValidation(o.Temp, o.Humidity)
+	heatIdxC, heatIdxCErr := libwx.HeatIndexCWithValidation(o.Temp.C(), o.Humidity)
+	windChillF, windChillFErr := o.WindChill()
+	windChillC, windChillCErr := libwx.WindChillCWithValidation(o.Temp.C(), o.WindSpeed)
+	wetBulbTempF, wetBulbTempFErr := libwx.WetBulbF(o.Temp, o.Humidity)
+	wetBulbTempC, wetBulbTempCErr := libwx.WetBulbC(o.Temp.C(), o.Humidity)
+	humidex, humidexErr := HumidexWithValidation(o.Temp.C(), o.DewPoint.C())
+	apparentTempC := ApparentTempC(o.Temp.C(), o.Humidity, o.WindSpeed)
+	frostPointC, frostPointOK := FrostPointC(o.Temp.C(), o.Humidity)
+
+	fields := map[string]interface{}{
+		"temp_f":                          o.Temp.Unwrap(),
+		"temp_c":                          o.Temp.C().Unwrap(),
+		"rel_humidity":                    o.Humidity.Unwrap(),
+		"feels_like_f":                    o.FeelsLike.Unwrap(),
+		"feels_like_c":                    o.FeelsLike.C().Unwrap(),
+		"barometric_pressure_mb":          o.Pressure.Unwrap(),
+		"barometric_pressure_inHg":        o.Pressure.InHg().Unwrap(),
+		"dew_point_f":                     o.DewPoint.Unwrap(),
+		"dew_point_c":                     o.DewPoint.C().Unwrap(),
+		"wind_speed_mph":                  o.WindSpeed.Unwrap(),
+		"wind_speed_kt":                   o.WindSpeed.Knots().Unwrap(),
+		"wind_bearing":                    o.WindBearing,
+		"visibility_mi":                   o.VisibilityMiles.Unwrap(),
+		"recommended_max_indoor_humidity": libwx.IndoorHumidityRecommendationF(o.Temp).Unwrap(),
+		"cloud_cover":                     o.CloudCoverPercent,
+		"apparent_temp_f":                 apparentTempC.F().Unwrap(),
+		"apparent_temp_c":                 apparentTempC.Unwrap(),
+		"vpd_kpa":                         VaporPressureDeficitKPa(o.Temp.C(), o.Humidity),
+	}
+
+	if heatIdxFErr == nil {
+		fields["heat_index_f"] = heatIdxF.Unwrap()
+	}
+	if heatIdxCErr == nil {
+		fields["heat_index_c"] = heatIdxC.Unwrap()
+	}
+	if windChillFErr == nil {
+		fields["wind_chill_f"] = windChillF.Unwrap()
+	}
+	if windChillCErr == nil {
+		fields["wind_chill_c"] = windChillC.Unwrap()
+	}
+	if wetBulbTempFErr == nil {
+		fields["wet_bulb_f"] = wetBulbTempF.Unwrap()
+	}
+	if wetBulbTempCErr == nil {
+		fields["wet_bulb_c"] = wetBulbTempC.Unwrap()
+	}
+	if humidexErr == nil {
+		fields["humidex"] = humidex
+	}
+	if frostPointOK {
+		fields["frost_point_f"] = frostPointC.F().Unwrap()
+		fields["frost_point_c"] = frostPointC.Unwrap()
+		fields["frost_risk"] = FrostRisk(o.Temp.C(), frostPointC, o.WindSpeed)
+	}
+	solarElevation, solarAzimuth := SolarPosition(o.Time, o.Latitude, o.Longitude)
+	moonPhase, moonIllumination := MoonPhase(o.Time)
+	fields["solar_elevation"] = solarElevation
+	fields["solar_azimuth"] = solarAzimuth
+	fields["moon_phase"] = moonPhase
+	fields["moon_illumination"] = moonIllumination
+	fields["moon_phase_name"] = MoonPhaseName(moonPhase)
+	clearSkyGHI := ClearSkyGHI(solarElevation)
+	solarEstimate := CloudAdjustedGHI(clearSkyGHI, o.CloudCoverPercent)
+	fields["clear_sky_ghi_wm2"] = clearSkyGHI
+	fields["ghi_estimate_wm2"] = solarEstimate
+	if wetBulbTempCErr == nil {
+		globeTempC := GlobeTempEstimateC(o.Temp.C(), o.WindSpeed, solarEstimate)
+		wbgtC := WBGTEstimateC(o.Temp.C(), wetBulbTempC, globeTempC)
+		fields["wbgt_c"] = wbgtC.Unwrap()
+		fields["wbgt_f"] = wbgtC.F().Unwrap()
+		fields["wbgt_category"] = WBGTCategory(wbgtC.F())
+	}
+	stationPressure := o.StationPressure()
+	if o.ElevationMeters != nil {
+		pressureAltitudeFt := PressureAltitudeFt(stationPressure)
+		densityAltitudeFt := DensityAltitudeFt(pressureAltitudeFt, o.Temp.C())
+		fields["station_pressure_mb"] = stationPressure.Unwrap()
+		fields["station_pressure_inHg"] = stationPressure.InHg().Unwrap()
+		fields["pressure_altitude_ft"] = pressureAltitudeFt
+		fields["pressure_altitude_m"] = pressureAltitudeFt * MetersPerFoot
+		fields["density_altitude_ft"] = densityAltitudeFt
+		fields["density_altitude_m"] = densityAltitudeFt * MetersPerFoot
+	}
+	fields["air_density_kg_m3"] = AirDensityKgM3(o.Temp.C(), stationPressure, o.Humidity)
+	for k, v := range o.Extra {
+		fields[k] = v
+	}
+	return fields
+}
@@ -0,0 +1,75 @@
+package owmconnector
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/cdzombak/libwx"
+)
+
+func TestObservationFields(t *testing.T) {
+	newObs := func(tempF float64, windMph float64) *Observation {
+		o := &Observation{
+			Time:              time.Date(2024, 7, 4, 18, 0, 0, 0, time.UTC),
+			Latitude:          42.28,
+			Longitude:         -83.74,
+			Temp:              libwx.TempF(tempF),
+			FeelsLike:         libwx.TempF(tempF),
+			Pressure:          1013.25,
+			Humidity:          50,
+			WindSpeed:         libwx.SpeedMph(windMph),
+			VisibilityMiles:   10,
+			CloudCoverPercent: 20,
+		}
+		o.DewPoint = libwx.DewPointF(o.Temp, o.Humidity)
+		return o
+	}
+
+	t.Run("hot", func(t *testing.T) {
+		fields := newObs(95, 5).Fields()
+		for _, k := range []string{"temp_f", "heat_index_f", "wbgt_f", "solar_elevation", "air_density_kg_m3"} {
+			if _, ok := fields[k]; !ok {
+				t.Errorf("fields[%q] is missing", k)
+			}
+		}
+		for _, k := range []string{"wind_chill_f", "station_pressure_mb"} {
+			if v, ok := fields[k]; ok {
+				t.Errorf("fields[%q] = %v; want it absent", k, v)
+			}
+		}
+		if got := fields["temp_c"].(float64); math.Abs(got-35) > 1e-9 {
+			t.Errorf("fields[\"temp_c\"] = %v; want 35", got)
+		}
+	})
+
+	t.Run("cold", func(t *testing.T) {
+		fields := newObs(20, 15).Fields()
+		for _, k := range []string{"wind_chill_f", "frost_point_f", "frost_risk"} {
+			if _, ok := fields[k]; !ok {
+				t.Errorf("fields[%q] is missing", k)
+			}
+		}
+		if v, ok := fields["heat_index_f"]; ok {
+			t.Errorf("fields[\"heat_index_f\"] = %v; want it absent", v)
+		}
+	})
+
+	t.Run("elevation and extra fields", func(t *testing.T) {
+		o := newObs(70, 5)
+		elevation := 1000.0
+		o.ElevationMeters = &elevation
+		o.Extra = map[string]interface{}{"pollen_tree": 2}
+		fields := o.Fields()
+		stationPressure, ok := fields["station_pressure_mb"].(float64)
+		if !ok || stationPressure >= 1013.25 {
+			t.Errorf("fields[\"station_pressure_mb\"] = %v; want less than sea-level pressure", fields["station_pressure_mb"])
+		}
+		if _, ok := fields["density_altitude_ft"]; !ok {
+			t.Error("fields[\"density_altitude_ft\"] is missing")
+		}
+		if fields["pollen_tree"] != 2 {
+			t.Errorf("fields[\"pollen_tree\"] = %v; want 2", fields["pollen_tree"])
+		}
+	})
+}
@@ -0,0 +1,197 @@
+package owmconnector
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cdzombak/libwx"
+)
+
+// OpenMeteoClient fetches observations for a single location from Open-Meteo, which
+// doesn't require an API key. Its observations and pollution readings use the same
+// fields as OpenWeatherMap's.
+// See https://open-meteo.com/en/docs and https://open-meteo.com/en/docs/air-quality-api
+type OpenMeteoClient struct {
+	Latitude  float64
+	Longitude float64
+	// ElevationMeters is optional; see NewObservation.
+	ElevationMeters *float64
+	// HTTPClient is used for all requests. If nil, a client with DefaultTimeout is used.
+	HTTPClient *http.Client
+}
+
+// Name returns "open-meteo".
+func (c *OpenMeteoClient) Name() string { return ProviderOpenMeteo }
+
+func (c *OpenMeteoClient) httpClient() *http.Client {
+	if c.HTTPClient == nil {
+		c.HTTPClient = &http.Client{Timeout: DefaultTimeout}
+	}
+	return c.HTTPClient
+}
+
+// get requests the given Open-Meteo API's current values for the given variables, and
+// decodes the response's "current" object into current.
+func (c *OpenMeteoClient) get(baseURL string, variables []string, extra url.Values, current interface{}) error {
+	q := url.Values{}
+	for k, v := range extra {
+		q[k] = v
+	}
+	q.Set("latitude", strconv.FormatFloat(c.Latitude, 'f', -1, 64))
+	q.Set("longitude", strconv.FormatFloat(c.Longitude, 'f', -1, 64))
+	q.Set("current", strings.Join(variables, ","))
+	q.Set("timeformat", "unixtime")
+	resp, err := c.httpClient().Get(baseURL + "?" + q.Encode())
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("Open-Meteo API returned %s", resp.Status)
+	}
+	body := struct {
+		Current interface{} `json:"current"`
+	}{Current: current}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return fmt.Errorf("failed to decode Open-Meteo response: %w", err)
+	}
+	return nil
+}
+
+// FetchObservation fetches the current weather observation.
+func (c *OpenMeteoClient) FetchObservation() (*Observation, error) {
+	var current struct {
+		Time          int64   `json:"time"`
+		Temp          float64 `json:"temperature_2m"`
+		Humidity      int     `json:"relative_humidity_2m"`
+		ApparentTemp  float64 `json:"apparent_temperature"`
+		PressureMSL   float64 `json:"pressure_msl"`
+		WindSpeed     float64 `json:"wind_speed_10m"`
+		WindDirection float64 `json:"wind_direction_10m"`
+		CloudCover    int     `json:"cloud_cover"`
+		Visibility    float64 `json:"visibility"`
+		WeatherCode   int     `json:"weather_code"`
+	}
+	err := c.get("https://api.open-meteo.com/v1/forecast", []string{
+		"temperature_2m", "relative_humidity_2m", "apparent_temperature", "pressure_msl",
+		"wind_speed_10m", "wind_direction_10m", "cloud_cover", "visibility", "weather_code",
+	}, url.Values{
+		"temperature_unit": {"fahrenheit"},
+		"wind_speed_unit":  {"mph"},
+	}, &current)
+	if err != nil {
+		return nil, err
+	}
+	if current.Time == 0 {
+		return nil, errors.New("Open-Meteo didn't return current weather")
+	}
+
+	o := &Observation{
+		Time:              time.Unix(current.Time, 0),
+		Latitude:          c.Latitude,
+		Longitude:         c.Longitude,
+		ElevationMeters:   c.ElevationMeters,
+		Temp:              libwx.TempF(current.Temp),
+		FeelsLike:         libwx.TempF(current.ApparentTemp),
+		Pressure:          libwx.PressureMb(current.PressureMSL),
+		Humidity:          libwx.ClampedRelHumidity(current.Humidity),
+		WindSpeed:         libwx.SpeedMph(current.WindSpeed),
+		WindBearing:       current.WindDirection,
+		VisibilityMiles:   libwx.Meter(current.Visibility).Miles(),
+		CloudCoverPercent: current.CloudCover,
+		ConditionID:       owmConditionForWMOCode(current.WeatherCode),
+	}
+	o.DewPoint = libwx.DewPointF(o.Temp, o.Humidity)
+	return o, nil
+}
+
+// FetchPollution fetches current air pollution. Open-Meteo doesn't report
+// OpenWeatherMap's 1-5 AQI, so the reading's AQI is 0.
+func (c *OpenMeteoClient) FetchPollution() (*Pollution, error) {
+	// Open-Meteo doesn't report NO; its other pollutants match OpenWeatherMap's JSON keys.
+	var keys []string
+	for _, pc := range PollutionComponents {
+		if pc.JSONKey != "no" {
+			keys = append(keys, pc.JSONKey)
+		}
+	}
+	// nb. the time and interval are numbers too, so all of current decodes into this map
+	current := make(map[string]*float64)
+	if err := c.get("https://air-quality-api.open-meteo.com/v1/air-quality", keys, nil, &current); err != nil {
+		return nil, err
+	}
+	if current["time"] == nil {
+		return nil, errors.New("Open-Meteo didn't return current air quality")
+	}
+
+	r := &Pollution{
+		Dt:         int(*current["time"]),
+		Components: make(map[string]float64),
+	}
+	for _, pc := range PollutionComponents {
+		if v := current[pc.JSONKey]; v != nil {
+			r.Components[pc.Field] = *v
+		}
+	}
+	return r, nil
+}
+
+// owmConditionForWMOCode returns the OpenWeatherMap condition code closest to the given
+// WMO weather interpretation code, as reported by Open-Meteo, or 0 if there's none.
+// See https://open-meteo.com/en/docs#weathervariables
+func owmConditionForWMOCode(code int) int {
+	switch code {
+	case 0:
+		return 800 // clear sky
+	case 1:
+		return 801 // few clouds
+	case 2:
+		return 802 // scattered clouds
+	case 3:
+		return 804 // overcast
+	case 45, 48:
+		return 741 // fog
+	case 51:
+		return 300 // light drizzle
+	case 53:
+		return 301 // drizzle
+	case 55:
+		return 302 // heavy drizzle
+	case 56, 57, 66, 67:
+		return 511 // freezing rain
+	case 61:
+		return 500 // light rain
+	case 63:
+		return 501 // moderate rain
+	case 65:
+		return 502 // heavy rain
+	case 71, 77:
+		return 600 // light snow
+	case 73:
+		return 601 // snow
+	case 75:
+		return 602 // heavy snow
+	case 80:
+		return 520 // light shower rain
+	case 81:
+		return 521 // shower rain
+	case 82:
+		return 522 // heavy shower rain
+	case 85:
+		return 620 // light shower snow
+	case 86:
+		return 622 // heavy shower snow
+	case 95:
+		return 211 // thunderstorm
+	case 96, 99:
+		return 202 // thunderstorm with heavy rain
+	default:
+		return 0
+	}
+}
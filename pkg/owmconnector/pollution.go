@@ -0,0 +1,247 @@
+package owmconnector
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	owm "github.com/briandowns/openweathermap"
+	"github.com/mrflynn/go-aqi"
+)
+
+// PollutionComponent describes a pollutant reported by OpenWeatherMap.
+type PollutionComponent struct {
+	// Field is the pollutant's field name, e.g. "pm25".
+	Field string
+	// JSONKey is the key OpenWeatherMap uses for the pollutant, e.g. "pm2_5".
+	JSONKey string
+	// Label is the pollutant's display name, e.g. "PM2.5".
+	Label string
+}
+
+// PollutionComponents lists the pollutants OpenWeatherMap reports, in the order they're reported.
+var PollutionComponents = []PollutionComponent{
+	{"co", "co", "CO"},
+	{"no", "no", "NO"},
+	{"no2", "no2", "NO2"},
+	{"o3", "o3", "O3"},
+	{"so2", "so2", "SO2"},
+	{"pm25", "pm2_5", "PM2.5"},
+	{"pm10", "pm10", "PM10"},
+	{"nh3", "nh3", "NH3"},
+}
+
+// Pollution is a single air pollution observation. AQI is OpenWeatherMap's 1-5 air
+// quality index, or 0 if the provider doesn't report it. Components contains
+// concentrations (ug/m^3), keyed by field name (e.g. "pm25"), for only those pollutants
+// the provider reported.
+type Pollution struct {
+	Dt         int
+	AQI        float64
+	Components map[string]float64
+}
+
+// Missing returns the field names of the pollutants missing from the reading.
+func (r Pollution) Missing() []string {
+	var missing []string
+	for _, c := range PollutionComponents {
+		if _, ok := r.Components[c.Field]; !ok {
+			missing = append(missing, c.Field)
+		}
+	}
+	return missing
+}
+
+// Time returns the time of the reading.
+func (r Pollution) Time() time.Time {
+	return time.Unix(int64(r.Dt), 0)
+}
+
+// PollutionOptions configures the AQIs included in Pollution.Fields.
+type PollutionOptions struct {
+	// Standards lists the AQI standards to calculate (e.g. AQIStandardUS). If empty,
+	// DefaultAQIStandards are calculated.
+	Standards []string
+	// PMHistory is the particulate matter history, including this reading, from which the
+	// US NowCast AQI is calculated. If it's empty, NowCast isn't calculated.
+	PMHistory []PMReading
+}
+
+// Fields returns the reading's fields, as written to the pollution measurement: the
+// reported AQI and concentrations, the missing pollutants, and the AQIs for the given
+// standards. An index is omitted if the pollutants it requires are missing.
+func (r Pollution) Fields(opts PollutionOptions) (map[string]interface{}, error) {
+	fields := make(map[string]interface{})
+	if r.AQI != 0 {
+		fields["aqi_1_5"] = r.AQI
+	}
+	for k, v := range r.Components {
+		fields[k] = v
+	}
+	if missing := r.Missing(); len(missing) > 0 {
+		fields["missing_components"] = strings.Join(missing, ",")
+	}
+
+	standards := opts.Standards
+	if len(standards) == 0 {
+		standards = DefaultAQIStandards
+	}
+	for _, std := range standards {
+		switch std {
+		case AQIStandardUS:
+			if err := r.usAQIFields(fields, opts.PMHistory); err != nil {
+				return nil, err
+			}
+		case AQIStandardEU:
+			caqi := CAQI(r.Components)
+			fields["aqi_eu"] = caqi
+			fields["aqi_eu_name"] = CAQIName(caqi)
+		case AQIStandardUK:
+			daqi := DAQI(r.Components)
+			fields["aqi_uk"] = daqi
+			fields["aqi_uk_name"] = DAQIName(daqi)
+		case AQIStandardCA:
+			no2, hasNo2 := r.Components["no2"]
+			o3, hasO3 := r.Components["o3"]
+			pm25, hasPm25 := r.Components["pm25"]
+			if hasNo2 && hasO3 && hasPm25 {
+				aqhi := AQHI(no2, o3, pm25)
+				fields["aqhi_ca"] = aqhi
+				fields["aqhi_ca_name"] = AQHIName(aqhi)
+			}
+		default:
+			return nil, fmt.Errorf("unknown AQI standard '%s'", std)
+		}
+	}
+	return fields, nil
+}
+
+// usAQIFields adds the US EPA AQI fields to fields: the overall AQI and its dominant
+// pollutant, the AQI for particulates alone, and, if there's enough history, the NowCast
+// AQI for particulates.
+func (r Pollution) usAQIFields(fields map[string]interface{}, history []PMReading) error {
+	particulates := make(map[string]aqi.Measurement)
+	pm25, hasPm25 := r.Components["pm25"]
+	if hasPm25 {
+		particulates["PM2.5"] = aqi.PM25{Concentration: pm25}
+	}
+	pm10, hasPm10 := r.Components["pm10"]
+	if hasPm10 {
+		particulates["PM10"] = aqi.PM10{Concentration: pm10}
+	}
+	all := make(map[string]aqi.Measurement)
+	for name, m := range particulates {
+		all[name] = m
+	}
+	if v, ok := r.Components["co"]; ok {
+		all["CO"] = aqi.CO{Concentration: v}
+	}
+	if v, ok := r.Components["no2"]; ok {
+		all["NO2"] = aqi.NO2{Concentration: v}
+	}
+	if v, ok := r.Components["so2"]; ok {
+		all["SO2"] = aqi.SO2{Concentration: v}
+	}
+
+	if len(all) > 0 {
+		overall, err := calculateUSAQI(all)
+		if err != nil {
+			return fmt.Errorf("failed to calculate overall US AQI: %w", err)
+		}
+		dominant, err := DominantPollutantUS(all)
+		if err != nil {
+			return fmt.Errorf("failed to determine dominant pollutant: %w", err)
+		}
+		fields["aqi_us"] = overall.AQI
+		fields["aqi_us_name"] = overall.Index.Name
+		fields["dominant_pollutant"] = dominant
+	}
+	if len(particulates) > 0 {
+		pm, err := calculateUSAQI(particulates)
+		if err != nil {
+			return fmt.Errorf("failed to calculate US AQI for particulates: %w", err)
+		}
+		fields["aqi_us_pm"] = pm.AQI
+		fields["aqi_us_pm_name"] = pm.Index.Name
+	}
+
+	if !hasPm25 || !hasPm10 {
+		return nil
+	}
+	nowCastPm25, nowCastPm10, ok := NowCastPM(history, r.Time())
+	if !ok {
+		return nil
+	}
+	nowCast, err := aqi.Calculate(aqi.PM25{Concentration: nowCastPm25}, aqi.PM10{Concentration: nowCastPm10})
+	if err != nil {
+		return fmt.Errorf("failed to calculate US NowCast AQI: %w", err)
+	}
+	fields["aqi_us_nowcast"] = nowCast.AQI
+	fields["aqi_us_nowcast_name"] = nowCast.Index.Name
+	fields["pm25_nowcast"] = nowCastPm25
+	fields["pm10_nowcast"] = nowCastPm10
+	return nil
+}
+
+// calculateUSAQI calculates the US EPA AQI from the given measurements.
+func calculateUSAQI(measurements map[string]aqi.Measurement) (aqi.Result, error) {
+	ms := make([]aqi.Measurement, 0, len(measurements))
+	for _, m := range measurements {
+		ms = append(ms, m)
+	}
+	return aqi.Calculate(ms...)
+}
+
+// FetchPollution fetches current air pollution for the given location using the given
+// HTTP client.
+// nb. this doesn't use the openweathermap library's pollution client, which can't
+// distinguish a missing component from a zero concentration.
+// See https://openweathermap.org/api/air-pollution
+func FetchPollution(client *http.Client, apiKey string, coords owm.Coordinates) (*Pollution, error) {
+	q := url.Values{}
+	q.Set("appid", apiKey)
+	q.Set("lat", strconv.FormatFloat(coords.Latitude, 'f', -1, 64))
+	q.Set("lon", strconv.FormatFloat(coords.Longitude, 'f', -1, 64))
+	resp, err := client.Get("https://api.openweathermap.org/data/2.5/air_pollution?" + q.Encode())
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("OpenWeatherMap pollution API returned %s", resp.Status)
+	}
+
+	var body struct {
+		List []struct {
+			Dt   int `json:"dt"`
+			Main struct {
+				Aqi float64 `json:"aqi"`
+			} `json:"main"`
+			Components map[string]*float64 `json:"components"`
+		} `json:"list"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("failed to decode pollution response: %w", err)
+	}
+	if len(body.List) == 0 {
+		return nil, errors.New("OpenWeatherMap didn't return any pollution information")
+	}
+
+	data := body.List[0]
+	r := &Pollution{
+		Dt:         data.Dt,
+		AQI:        data.Main.Aqi,
+		Components: make(map[string]float64),
+	}
+	for _, c := range PollutionComponents {
+		if v := data.Components[c.JSONKey]; v != nil {
+			r.Components[c.Field] = *v
+		}
+	}
+	return r, nil
+}
@@ -0,0 +1,138 @@
+package owmconnector
+
+import (
+	"math"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestPollutionFields(t *testing.T) {
+	ts := time.Date(2024, 7, 4, 12, 0, 0, 0, time.UTC)
+	particulates := map[string]float64{"pm25": 12, "pm10": 50}
+	history := []PMReading{
+		{Time: ts.Add(-time.Hour), PM25: 12, PM10: 50},
+		{Time: ts, PM25: 12, PM10: 50},
+	}
+
+	tests := []struct {
+		name       string
+		components map[string]float64
+		opts       PollutionOptions
+		want       map[string]interface{}
+		// absent lists fields that must not be set
+		absent []string
+	}{
+		{
+			name:       "default standards",
+			components: particulates,
+			want: map[string]interface{}{
+				"aqi_1_5":            2.0,
+				"pm25":               12.0,
+				"pm10":               50.0,
+				"missing_components": "co,no,no2,o3,so2,nh3",
+				"aqi_us":             50.0,
+				"aqi_us_name":        "Good",
+				"dominant_pollutant": "PM2.5",
+				"aqi_us_pm":          50.0,
+				"aqi_us_pm_name":     "Good",
+				"aqi_eu":             50.0, // PM10 at the top of the Low band
+				"aqi_eu_name":        "Medium",
+			},
+			absent: []string{"aqi_uk", "aqhi_ca", "aqi_us_nowcast"},
+		},
+		{
+			name:       "NowCast",
+			components: particulates,
+			opts:       PollutionOptions{Standards: []string{AQIStandardUS}, PMHistory: history},
+			want: map[string]interface{}{
+				"aqi_us_nowcast":      50.0,
+				"aqi_us_nowcast_name": "Good",
+				"pm25_nowcast":        12.0,
+				"pm10_nowcast":        50.0,
+			},
+			absent: []string{"aqi_eu"},
+		},
+		{
+			name:       "NowCast requires PM10",
+			components: map[string]float64{"pm25": 12},
+			opts:       PollutionOptions{Standards: []string{AQIStandardUS}, PMHistory: history},
+			want:       map[string]interface{}{"aqi_us_pm": 50.0},
+			absent:     []string{"aqi_us_nowcast", "pm25_nowcast"},
+		},
+		{
+			name:       "UK and Canada",
+			components: map[string]float64{"pm25": 12, "no2": 40, "o3": 60},
+			opts:       PollutionOptions{Standards: []string{AQIStandardUK, AQIStandardCA}},
+			want: map[string]interface{}{
+				"aqi_uk":       2,
+				"aqi_uk_name":  "Low",
+				"aqhi_ca":      AQHI(40, 60, 12),
+				"aqhi_ca_name": "Moderate Risk",
+			},
+			absent: []string{"aqi_us", "aqi_eu"},
+		},
+		{
+			name:       "AQHI requires O3",
+			components: map[string]float64{"pm25": 12, "no2": 40},
+			opts:       PollutionOptions{Standards: []string{AQIStandardCA}},
+			absent:     []string{"aqhi_ca", "aqhi_ca_name"},
+		},
+		{
+			name:       "no US AQI without its pollutants",
+			components: map[string]float64{"o3": 60},
+			opts:       PollutionOptions{Standards: []string{AQIStandardUS}},
+			absent:     []string{"aqi_us", "aqi_us_pm", "dominant_pollutant"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := Pollution{Dt: int(ts.Unix()), AQI: 2, Components: tt.components}
+			fields, err := r.Fields(tt.opts)
+			if err != nil {
+				t.Fatalf("Fields() error = %v", err)
+			}
+			for k, want := range tt.want {
+				got, ok := fields[k]
+				if !ok {
+					t.Errorf("fields[%q] is missing; want %v", k, want)
+				} else if gotF, ok := got.(float64); ok {
+					if wantF, ok := want.(float64); !ok || math.Abs(gotF-wantF) > 1e-9 {
+						t.Errorf("fields[%q] = %v; want %v", k, got, want)
+					}
+				} else if !reflect.DeepEqual(got, want) {
+					t.Errorf("fields[%q] = %#v; want %#v", k, got, want)
+				}
+			}
+			for _, k := range tt.absent {
+				if v, ok := fields[k]; ok {
+					t.Errorf("fields[%q] = %v; want it absent", k, v)
+				}
+			}
+		})
+	}
+}
+
+func TestPollutionFieldsErrors(t *testing.T) {
+	tests := []struct {
+		name       string
+		components map[string]float64
+		opts       PollutionOptions
+	}{
+		{"unknown standard", map[string]float64{"pm25": 12}, PollutionOptions{Standards: []string{"mx"}}},
+		{"negative concentration", map[string]float64{"pm25": -1}, PollutionOptions{Standards: []string{AQIStandardUS}}},
+	}
+	for _, tt := range tests {
+		r := Pollution{Components: tt.components}
+		if _, err := r.Fields(tt.opts); err == nil {
+			t.Errorf("Fields(%s) succeeded; want an error", tt.name)
+		}
+	}
+}
+
+func TestPollutionMissing(t *testing.T) {
+	r := Pollution{Components: map[string]float64{"co": 1, "no": 0, "no2": 1, "o3": 1, "so2": 1, "pm25": 1}}
+	if got, want := r.Missing(), []string{"pm10", "nh3"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Missing() = %v; want %v", got, want)
+	}
+}
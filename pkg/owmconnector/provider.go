@@ -0,0 +1,33 @@
+package owmconnector
+
+const (
+	// ProviderOpenWeatherMap is the name of the OpenWeatherMap provider (Client).
+	ProviderOpenWeatherMap = "openweathermap"
+	// ProviderOpenMeteo is the name of the Open-Meteo provider (OpenMeteoClient).
+	ProviderOpenMeteo = "open-meteo"
+	// ProviderNWS is the name of the US National Weather Service provider (NWSClient).
+	ProviderNWS = "nws"
+	// ProviderTomorrowIO is the name of the Tomorrow.io provider (TomorrowIOClient).
+	ProviderTomorrowIO = "tomorrowio"
+	// ProviderWeatherAPI is the name of the WeatherAPI.com provider (WeatherAPIClient).
+	ProviderWeatherAPI = "weatherapi"
+)
+
+// Provider fetches current weather and air pollution for a single location from a
+// weather data provider.
+type Provider interface {
+	// Name identifies the provider, e.g. "openweathermap".
+	Name() string
+	// FetchObservation fetches the current weather observation.
+	FetchObservation() (*Observation, error)
+	// FetchPollution fetches current air pollution.
+	FetchPollution() (*Pollution, error)
+}
+
+var (
+	_ Provider = (*Client)(nil)
+	_ Provider = (*OpenMeteoClient)(nil)
+	_ Provider = (*NWSClient)(nil)
+	_ Provider = (*TomorrowIOClient)(nil)
+	_ Provider = (*WeatherAPIClient)(nil)
+)
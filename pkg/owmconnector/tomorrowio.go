@@ -0,0 +1,243 @@
+package owmconnector
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/cdzombak/libwx"
+)
+
+// TomorrowIOClient fetches observations for a single location from Tomorrow.io's
+// Timeline API. A single request is made for the current weather, air quality, and (if
+// enabled) pollen and fire index values; FetchObservation and FetchPollution share it.
+// See https://docs.tomorrow.io/reference/get-timelines
+type TomorrowIOClient struct {
+	APIKey    string
+	Latitude  float64
+	Longitude float64
+	// ElevationMeters is optional; see NewObservation.
+	ElevationMeters *float64
+	// Pollen requests the tree, grass, and weed pollen indices, which require a Tomorrow.io
+	// plan that includes them.
+	Pollen bool
+	// FireIndex requests the fire index, which requires a Tomorrow.io plan that includes it.
+	FireIndex bool
+	// HTTPClient is used for all requests. If nil, a client with DefaultTimeout is used.
+	HTTPClient *http.Client
+
+	current *tomorrowIOInterval
+}
+
+type tomorrowIOInterval struct {
+	StartTime time.Time           `json:"startTime"`
+	Values    map[string]*float64 `json:"values"`
+}
+
+// value returns the given field's value, and whether it was reported (non-null).
+func (i *tomorrowIOInterval) value(key string) (float64, bool) {
+	if v := i.Values[key]; v != nil {
+		return *v, true
+	}
+	return 0, false
+}
+
+// valueOrZero returns the given field's value, or 0 if it wasn't reported.
+func (i *tomorrowIOInterval) valueOrZero(key string) float64 {
+	v, _ := i.value(key)
+	return v
+}
+
+// tomorrowIOPollutants maps pollutants' field names to their Tomorrow.io field names and
+// molecular weights (g/mol). Gases are reported in ppb, and are converted to ug/m^3 using
+// the molecular weight; particulates (with no molecular weight) are reported in ug/m^3.
+var tomorrowIOPollutants = map[string]struct {
+	key             string
+	molecularWeight float64
+}{
+	"co":   {"pollutantCO", 28.01},
+	"no2":  {"pollutantNO2", 46.01},
+	"o3":   {"pollutantO3", 48.00},
+	"so2":  {"pollutantSO2", 64.07},
+	"pm25": {"particulateMatter25", 0},
+	"pm10": {"particulateMatter10", 0},
+}
+
+// Name returns "tomorrowio".
+func (c *TomorrowIOClient) Name() string { return ProviderTomorrowIO }
+
+func (c *TomorrowIOClient) httpClient() *http.Client {
+	if c.HTTPClient == nil {
+		c.HTTPClient = &http.Client{Timeout: DefaultTimeout}
+	}
+	return c.HTTPClient
+}
+
+// fetch fetches the current values, or returns those from a previous fetch.
+func (c *TomorrowIOClient) fetch() (*tomorrowIOInterval, error) {
+	if c.current != nil {
+		return c.current, nil
+	}
+	fields := []string{
+		"temperature", "temperatureApparent", "humidity", "dewPoint", "pressureSeaLevel",
+		"windSpeed", "windDirection", "visibility", "cloudCover", "weatherCode",
+	}
+	for _, p := range tomorrowIOPollutants {
+		fields = append(fields, p.key)
+	}
+	if c.Pollen {
+		fields = append(fields, "treeIndex", "grassIndex", "weedIndex")
+	}
+	if c.FireIndex {
+		fields = append(fields, "fireIndex")
+	}
+
+	q := url.Values{}
+	q.Set("apikey", c.APIKey)
+	q.Set("location", fmt.Sprintf("%f,%f", c.Latitude, c.Longitude))
+	q.Set("fields", strings.Join(fields, ","))
+	q.Set("timesteps", "current")
+	q.Set("units", "imperial")
+	resp, err := c.httpClient().Get("https://api.tomorrow.io/v4/timelines?" + q.Encode())
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Tomorrow.io API returned %s", resp.Status)
+	}
+	var body struct {
+		Data struct {
+			Timelines []struct {
+				Intervals []tomorrowIOInterval `json:"intervals"`
+			} `json:"timelines"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("failed to decode Tomorrow.io response: %w", err)
+	}
+	if len(body.Data.Timelines) == 0 || len(body.Data.Timelines[0].Intervals) == 0 {
+		return nil, errors.New("Tomorrow.io didn't return current conditions")
+	}
+	c.current = &body.Data.Timelines[0].Intervals[0]
+	return c.current, nil
+}
+
+// FetchObservation fetches the current weather observation.
+func (c *TomorrowIOClient) FetchObservation() (*Observation, error) {
+	cur, err := c.fetch()
+	if err != nil {
+		return nil, err
+	}
+	v := cur.valueOrZero
+	if _, ok := cur.value("temperature"); !ok {
+		return nil, errors.New("Tomorrow.io didn't return a temperature")
+	}
+	o := &Observation{
+		Time:              cur.StartTime,
+		Latitude:          c.Latitude,
+		Longitude:         c.Longitude,
+		ElevationMeters:   c.ElevationMeters,
+		Temp:              libwx.TempF(v("temperature")),
+		FeelsLike:         libwx.TempF(v("temperatureApparent")),
+		Pressure:          libwx.PressureInHg(v("pressureSeaLevel")).Mb(),
+		Humidity:          libwx.ClampedRelHumidity(int(v("humidity") + 0.5)),
+		DewPoint:          libwx.TempF(v("dewPoint")),
+		WindSpeed:         libwx.SpeedMph(v("windSpeed")),
+		WindBearing:       v("windDirection"),
+		VisibilityMiles:   libwx.Mile(v("visibility")),
+		CloudCoverPercent: int(v("cloudCover") + 0.5),
+		ConditionID:       owmConditionForTomorrowIOCode(int(v("weatherCode"))),
+		Extra:             make(map[string]interface{}),
+	}
+	for field, key := range map[string]string{
+		"pollen_tree_index":  "treeIndex",
+		"pollen_grass_index": "grassIndex",
+		"pollen_weed_index":  "weedIndex",
+	} {
+		if x, ok := cur.value(key); ok {
+			o.Extra[field] = int(x)
+		}
+	}
+	if x, ok := cur.value("fireIndex"); ok {
+		o.Extra["fire_index"] = x
+	}
+	return o, nil
+}
+
+// FetchPollution fetches current air pollution. Tomorrow.io doesn't report NO, NH3, or
+// OpenWeatherMap's 1-5 AQI, so the reading's AQI is 0.
+func (c *TomorrowIOClient) FetchPollution() (*Pollution, error) {
+	cur, err := c.fetch()
+	if err != nil {
+		return nil, err
+	}
+	r := &Pollution{
+		Dt:         int(cur.StartTime.Unix()),
+		Components: make(map[string]float64),
+	}
+	for field, p := range tomorrowIOPollutants {
+		x, ok := cur.value(p.key)
+		if !ok {
+			continue
+		}
+		if p.molecularWeight != 0 {
+			x = PPBToUgM3(x, p.molecularWeight)
+		}
+		r.Components[field] = x
+	}
+	return r, nil
+}
+
+// PPBToUgM3 converts a gas concentration in parts per billion to micrograms per cubic
+// meter, at 25 degC and 1 atm, given the gas's molecular weight (g/mol).
+func PPBToUgM3(ppb, molecularWeight float64) float64 {
+	// 24.45 L is the molar volume of an ideal gas at 25 degC and 1 atm
+	return ppb * molecularWeight / 24.45
+}
+
+// owmConditionForTomorrowIOCode returns the OpenWeatherMap condition code closest to the
+// given Tomorrow.io weather code, or 0 if there's none.
+// See https://docs.tomorrow.io/reference/data-layers-weather-codes
+func owmConditionForTomorrowIOCode(code int) int {
+	switch code {
+	case 1000:
+		return 800 // clear
+	case 1100:
+		return 801 // mostly clear
+	case 1101:
+		return 802 // partly cloudy
+	case 1102:
+		return 803 // mostly cloudy
+	case 1001:
+		return 804 // cloudy
+	case 2000, 2100:
+		return 741 // fog
+	case 4000:
+		return 301 // drizzle
+	case 4200:
+		return 500 // light rain
+	case 4001:
+		return 501 // rain
+	case 4201:
+		return 502 // heavy rain
+	case 5001, 5100:
+		return 600 // flurries, light snow
+	case 5000:
+		return 601 // snow
+	case 5101:
+		return 602 // heavy snow
+	case 6000, 6001, 6200, 6201:
+		return 511 // freezing drizzle and rain
+	case 7000, 7101, 7102:
+		return 611 // ice pellets
+	case 8000:
+		return 211 // thunderstorm
+	default:
+		return 0
+	}
+}
@@ -0,0 +1,184 @@
+package owmconnector
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/cdzombak/libwx"
+)
+
+// WeatherAPIClient fetches observations for a single location from WeatherAPI.com. A
+// single request is made for the current weather and air quality; FetchObservation and
+// FetchPollution share it.
+// See https://www.weatherapi.com/docs/
+type WeatherAPIClient struct {
+	APIKey    string
+	Latitude  float64
+	Longitude float64
+	// ElevationMeters is optional; see NewObservation.
+	ElevationMeters *float64
+	// HTTPClient is used for all requests. If nil, a client with DefaultTimeout is used.
+	HTTPClient *http.Client
+
+	current *weatherAPICurrent
+}
+
+type weatherAPICurrent struct {
+	LastUpdatedEpoch int64   `json:"last_updated_epoch"`
+	TempF            float64 `json:"temp_f"`
+	FeelsLikeF       float64 `json:"feelslike_f"`
+	Humidity         int     `json:"humidity"`
+	PressureMb       float64 `json:"pressure_mb"`
+	WindMph          float64 `json:"wind_mph"`
+	WindDegree       float64 `json:"wind_degree"`
+	VisMiles         float64 `json:"vis_miles"`
+	Cloud            int     `json:"cloud"`
+	Condition        struct {
+		Code int `json:"code"`
+	} `json:"condition"`
+	// AirQuality holds pollutant concentrations (ug/m^3) keyed by the same JSON keys as
+	// OpenWeatherMap's, along with WeatherAPI.com's own indices.
+	AirQuality map[string]*float64 `json:"air_quality"`
+}
+
+// Name returns "weatherapi".
+func (c *WeatherAPIClient) Name() string { return ProviderWeatherAPI }
+
+func (c *WeatherAPIClient) httpClient() *http.Client {
+	if c.HTTPClient == nil {
+		c.HTTPClient = &http.Client{Timeout: DefaultTimeout}
+	}
+	return c.HTTPClient
+}
+
+// fetch fetches current conditions, or returns those from a previous fetch.
+func (c *WeatherAPIClient) fetch() (*weatherAPICurrent, error) {
+	if c.current != nil {
+		return c.current, nil
+	}
+	q := url.Values{}
+	q.Set("key", c.APIKey)
+	q.Set("q", fmt.Sprintf("%f,%f", c.Latitude, c.Longitude))
+	q.Set("aqi", "yes")
+	resp, err := c.httpClient().Get("https://api.weatherapi.com/v1/current.json?" + q.Encode())
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("WeatherAPI.com returned %s", resp.Status)
+	}
+	var body struct {
+		Current *weatherAPICurrent `json:"current"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("failed to decode WeatherAPI.com response: %w", err)
+	}
+	if body.Current == nil || body.Current.LastUpdatedEpoch == 0 {
+		return nil, errors.New("WeatherAPI.com didn't return current conditions")
+	}
+	c.current = body.Current
+	return c.current, nil
+}
+
+// FetchObservation fetches the current weather observation.
+func (c *WeatherAPIClient) FetchObservation() (*Observation, error) {
+	cur, err := c.fetch()
+	if err != nil {
+		return nil, err
+	}
+	o := &Observation{
+		Time:              time.Unix(cur.LastUpdatedEpoch, 0),
+		Latitude:          c.Latitude,
+		Longitude:         c.Longitude,
+		ElevationMeters:   c.ElevationMeters,
+		Temp:              libwx.TempF(cur.TempF),
+		FeelsLike:         libwx.TempF(cur.FeelsLikeF),
+		Pressure:          libwx.PressureMb(cur.PressureMb),
+		Humidity:          libwx.ClampedRelHumidity(cur.Humidity),
+		WindSpeed:         libwx.SpeedMph(cur.WindMph),
+		WindBearing:       cur.WindDegree,
+		VisibilityMiles:   libwx.Mile(cur.VisMiles),
+		CloudCoverPercent: cur.Cloud,
+		ConditionID:       owmConditionForWeatherAPICode(cur.Condition.Code),
+	}
+	o.DewPoint = libwx.DewPointF(o.Temp, o.Humidity)
+	return o, nil
+}
+
+// FetchPollution fetches current air pollution. WeatherAPI.com doesn't report NO, NH3, or
+// OpenWeatherMap's 1-5 AQI, so the reading's AQI is 0.
+func (c *WeatherAPIClient) FetchPollution() (*Pollution, error) {
+	cur, err := c.fetch()
+	if err != nil {
+		return nil, err
+	}
+	r := &Pollution{
+		Dt:         int(cur.LastUpdatedEpoch),
+		Components: make(map[string]float64),
+	}
+	for _, pc := range PollutionComponents {
+		if v := cur.AirQuality[pc.JSONKey]; v != nil {
+			r.Components[pc.Field] = *v
+		}
+	}
+	return r, nil
+}
+
+// owmConditionForWeatherAPICode returns the OpenWeatherMap condition code closest to the
+// given WeatherAPI.com condition code, or 0 if there's none.
+// See https://www.weatherapi.com/docs/weather_conditions.json
+func owmConditionForWeatherAPICode(code int) int {
+	switch code {
+	case 1000:
+		return 800 // sunny/clear
+	case 1003:
+		return 802 // partly cloudy
+	case 1006:
+		return 803 // cloudy
+	case 1009:
+		return 804 // overcast
+	case 1030:
+		return 701 // mist
+	case 1135, 1147:
+		return 741 // fog, freezing fog
+	case 1063, 1180, 1183:
+		return 500 // patchy or light rain
+	case 1186, 1189:
+		return 501 // moderate rain
+	case 1192, 1195:
+		return 502 // heavy rain
+	case 1150, 1153:
+		return 300 // light drizzle
+	case 1072, 1168, 1171, 1198, 1201:
+		return 511 // freezing drizzle and rain
+	case 1069, 1204, 1207, 1237, 1249, 1252, 1261, 1264:
+		return 611 // sleet and ice pellets
+	case 1066, 1210, 1213:
+		return 600 // patchy or light snow
+	case 1216, 1219, 1114:
+		return 601 // moderate snow, blowing snow
+	case 1222, 1225, 1117:
+		return 602 // heavy snow, blizzard
+	case 1240:
+		return 520 // light rain shower
+	case 1243:
+		return 521 // rain shower
+	case 1246:
+		return 522 // torrential rain shower
+	case 1255:
+		return 620 // light snow showers
+	case 1258:
+		return 621 // snow showers
+	case 1087, 1273, 1279:
+		return 210 // thundery outbreaks, light thunder
+	case 1276, 1282:
+		return 211 // thunder with rain or snow
+	default:
+		return 0
+	}
+}
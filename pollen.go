@@ -0,0 +1,237 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cdzombak/openweather-influxdb-connector/pkg/owmconnector"
+)
+
+const (
+	// PollenProviderGoogle fetches pollen indices from the Google Pollen API.
+	PollenProviderGoogle = "google"
+	// PollenProviderAmbee fetches pollen counts and risk levels from Ambee.
+	PollenProviderAmbee = "ambee"
+	// PollenProviderTomorrowIO fetches pollen indices from Tomorrow.io.
+	PollenProviderTomorrowIO = owmconnector.ProviderTomorrowIO
+
+	defaultPollenMeasurementName = "pollen"
+)
+
+// pollenTypes lists the pollen types written, by field name prefix.
+var pollenTypes = []string{"tree", "grass", "weed"}
+
+// tomorrowIOPollenCategories names Tomorrow.io's pollen index values.
+// See https://docs.tomorrow.io/reference/data-layers-pollen
+var tomorrowIOPollenCategories = []string{"None", "Very Low", "Low", "Medium", "High", "Very High"}
+
+// PollenConfig describes the configuration for the pollen measurement.
+type PollenConfig struct {
+	Provider        string `json:"provider"`
+	APIKey          string `json:"api_key,omitempty"`
+	MeasurementName string `json:"measurement_name,omitempty"`
+}
+
+// Validate checks the pollen configuration. tomorrowIO is the Tomorrow.io provider
+// configuration, whose API key is used if the pollen config doesn't have its own.
+func (c PollenConfig) Validate(tomorrowIO *TomorrowIOConfig) error {
+	switch c.Provider {
+	case PollenProviderGoogle, PollenProviderAmbee:
+		if c.APIKey == "" {
+			return fmt.Errorf("api_key must be set for the %s provider", c.Provider)
+		}
+	case PollenProviderTomorrowIO:
+		if c.APIKey == "" && (tomorrowIO == nil || tomorrowIO.APIKey == "") {
+			return errors.New("api_key (or tomorrowio.api_key) must be set for the tomorrowio provider")
+		}
+	default:
+		return fmt.Errorf("provider must be '%s', '%s', or '%s'", PollenProviderGoogle, PollenProviderAmbee, PollenProviderTomorrowIO)
+	}
+	return nil
+}
+
+// Measurement returns the configured measurement name, or the default "pollen".
+func (c PollenConfig) Measurement() string {
+	if c.MeasurementName == "" {
+		return defaultPollenMeasurementName
+	}
+	return c.MeasurementName
+}
+
+// Pollen is a pollen reading. Fields contains, for each pollen type (e.g. "tree"), those of
+// <type>_index (0-5), <type>_category, and <type>_count (grains/m^3) the provider reports.
+type Pollen struct {
+	Time   time.Time
+	Fields map[string]interface{}
+}
+
+// FetchPollen fetches current pollen levels for the given location from the configured
+// provider. tomorrowIO is the Tomorrow.io provider configuration; see Validate.
+func FetchPollen(c PollenConfig, tomorrowIO *TomorrowIOConfig, lat, lon float64) (*Pollen, error) {
+	switch c.Provider {
+	case PollenProviderGoogle:
+		return fetchGooglePollen(c.APIKey, lat, lon)
+	case PollenProviderAmbee:
+		return fetchAmbeePollen(c.APIKey, lat, lon)
+	case PollenProviderTomorrowIO:
+		key := c.APIKey
+		if key == "" {
+			key = tomorrowIO.APIKey
+		}
+		return fetchTomorrowIOPollen(key, lat, lon)
+	default:
+		return nil, fmt.Errorf("unknown pollen provider '%s'", c.Provider)
+	}
+}
+
+func getPollenJSON(req *http.Request, service string, v interface{}) error {
+	resp, err := owmHTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s returned %s", service, resp.Status)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("failed to decode %s response: %w", service, err)
+	}
+	return nil
+}
+
+// fetchGooglePollen fetches today's Universal Pollen Index for each pollen type.
+// See https://developers.google.com/maps/documentation/pollen/forecast
+func fetchGooglePollen(apiKey string, lat, lon float64) (*Pollen, error) {
+	q := url.Values{}
+	q.Set("key", apiKey)
+	q.Set("location.latitude", strconv.FormatFloat(lat, 'f', 4, 64))
+	q.Set("location.longitude", strconv.FormatFloat(lon, 'f', 4, 64))
+	q.Set("days", "1")
+	req, err := http.NewRequest(http.MethodGet, "https://pollen.googleapis.com/v1/forecast:lookup?"+q.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	var body struct {
+		DailyInfo []struct {
+			Date struct {
+				Year  int `json:"year"`
+				Month int `json:"month"`
+				Day   int `json:"day"`
+			} `json:"date"`
+			PollenTypeInfo []struct {
+				Code      string `json:"code"`
+				IndexInfo *struct {
+					Value    int    `json:"value"`
+					Category string `json:"category"`
+				} `json:"indexInfo"`
+			} `json:"pollenTypeInfo"`
+		} `json:"dailyInfo"`
+	}
+	if err := getPollenJSON(req, "Google Pollen API", &body); err != nil {
+		return nil, err
+	}
+	if len(body.DailyInfo) == 0 {
+		return nil, errors.New("Google Pollen API didn't return today's pollen")
+	}
+	day := body.DailyInfo[0]
+	p := &Pollen{
+		Time:   time.Date(day.Date.Year, time.Month(day.Date.Month), day.Date.Day, 0, 0, 0, 0, localTZ),
+		Fields: make(map[string]interface{}),
+	}
+	for _, t := range day.PollenTypeInfo {
+		// nb. the index is omitted out of season
+		if t.IndexInfo == nil {
+			continue
+		}
+		prefix := strings.ToLower(t.Code)
+		p.Fields[prefix+"_index"] = t.IndexInfo.Value
+		p.Fields[prefix+"_category"] = t.IndexInfo.Category
+	}
+	return p, nil
+}
+
+// fetchAmbeePollen fetches the latest pollen counts and risk levels.
+// See https://docs.ambeedata.com/apis/pollen
+func fetchAmbeePollen(apiKey string, lat, lon float64) (*Pollen, error) {
+	q := url.Values{}
+	q.Set("lat", strconv.FormatFloat(lat, 'f', 4, 64))
+	q.Set("lng", strconv.FormatFloat(lon, 'f', 4, 64))
+	req, err := http.NewRequest(http.MethodGet, "https://api.ambeedata.com/latest/pollen/by-lat-lng?"+q.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("x-api-key", apiKey)
+	var body struct {
+		Data []struct {
+			Count     map[string]*float64 `json:"Count"`
+			Risk      map[string]string   `json:"Risk"`
+			UpdatedAt time.Time           `json:"updatedAt"`
+		} `json:"data"`
+	}
+	if err := getPollenJSON(req, "Ambee API", &body); err != nil {
+		return nil, err
+	}
+	if len(body.Data) == 0 {
+		return nil, errors.New("Ambee didn't return any pollen data")
+	}
+	d := body.Data[0]
+	p := &Pollen{Time: d.UpdatedAt, Fields: make(map[string]interface{})}
+	for _, t := range pollenTypes {
+		if v := d.Count[t+"_pollen"]; v != nil {
+			p.Fields[t+"_count"] = *v
+		}
+		if v, ok := d.Risk[t+"_pollen"]; ok {
+			p.Fields[t+"_category"] = v
+		}
+	}
+	return p, nil
+}
+
+// fetchTomorrowIOPollen fetches the current pollen index for each pollen type.
+// See https://docs.tomorrow.io/reference/data-layers-pollen
+func fetchTomorrowIOPollen(apiKey string, lat, lon float64) (*Pollen, error) {
+	q := url.Values{}
+	q.Set("apikey", apiKey)
+	q.Set("location", fmt.Sprintf("%f,%f", lat, lon))
+	q.Set("fields", "treeIndex,grassIndex,weedIndex")
+	q.Set("timesteps", "current")
+	req, err := http.NewRequest(http.MethodGet, "https://api.tomorrow.io/v4/timelines?"+q.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	var body struct {
+		Data struct {
+			Timelines []struct {
+				Intervals []struct {
+					StartTime time.Time       `json:"startTime"`
+					Values    map[string]*int `json:"values"`
+				} `json:"intervals"`
+			} `json:"timelines"`
+		} `json:"data"`
+	}
+	if err := getPollenJSON(req, "Tomorrow.io API", &body); err != nil {
+		return nil, err
+	}
+	if len(body.Data.Timelines) == 0 || len(body.Data.Timelines[0].Intervals) == 0 {
+		return nil, errors.New("Tomorrow.io didn't return current pollen")
+	}
+	cur := body.Data.Timelines[0].Intervals[0]
+	p := &Pollen{Time: cur.StartTime, Fields: make(map[string]interface{})}
+	for _, t := range pollenTypes {
+		v := cur.Values[t+"Index"]
+		if v == nil {
+			continue
+		}
+		p.Fields[t+"_index"] = *v
+		if *v >= 0 && *v < len(tomorrowIOPollenCategories) {
+			p.Fields[t+"_category"] = tomorrowIOPollenCategories[*v]
+		}
+	}
+	return p, nil
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+const (
+	defaultPostgresName = "postgres"
+	postgresTimeout     = 10 * time.Second
+)
+
+// PostgresConfig describes the configuration for the PostgreSQL/TimescaleDB output.
+type PostgresConfig struct {
+	Name        string `json:"name,omitempty"`
+	DSN         string `json:"dsn"`
+	TablePrefix string `json:"table_prefix,omitempty"`
+	Timescale   bool   `json:"timescale,omitempty"`
+}
+
+// Validate checks the PostgreSQL configuration.
+func (c PostgresConfig) Validate() error {
+	if c.DSN == "" {
+		return errors.New("dsn must be set")
+	}
+	return nil
+}
+
+// OutputName returns the configured output name, or the default "postgres".
+func (c PostgresConfig) OutputName() string {
+	if c.Name == "" {
+		return defaultPostgresName
+	}
+	return c.Name
+}
+
+// postgresColumnTypes maps field types to the PostgreSQL column types they're stored in. A
+// column's type is fixed when it's created, and a field written as an int by one run may
+// be written as a float by another (e.g. after a provider switch), so all numeric fields
+// are stored as double precision.
+var postgresColumnTypes = map[FieldType]string{
+	FieldTypeFloat:  "double precision",
+	FieldTypeInt:    "double precision",
+	FieldTypeString: "text",
+	FieldTypeBool:   "boolean",
+}
+
+// postgresOutput inserts points as rows into one table per measurement. Tables and
+// columns are created as needed: a "time" column, a text column per tag, and a column per
+// field, typed by the field's type (see postgresColumnTypes).
+type postgresOutput struct {
+	config PostgresConfig
+	db     *sql.DB
+	// columns records the columns known to exist in each table this run.
+	columns map[string]map[string]bool
+}
+
+// newPostgresOutput connects to the configured PostgreSQL database.
+func newPostgresOutput(c PostgresConfig) (*postgresOutput, error) {
+	db, err := sql.Open("postgres", c.DSN)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), postgresTimeout)
+	defer cancel()
+	if err := db.PingContext(ctx); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &postgresOutput{config: c, db: db, columns: make(map[string]map[string]bool)}, nil
+}
+
+// Name returns the output's name, for output routing.
+func (o *postgresOutput) Name() string {
+	return o.config.OutputName()
+}
+
+// WritePoint inserts a single point into the measurement's table, creating the table or
+// adding columns first if necessary.
+func (o *postgresOutput) WritePoint(ctx context.Context, measurement string, tags map[string]string, fields map[string]interface{}, ts time.Time) error {
+	ctx, cancel := context.WithTimeout(ctx, postgresTimeout)
+	defer cancel()
+
+	table := o.config.TablePrefix + measurement
+	columnTypes := make(map[string]string, len(tags)+len(fields))
+	values := make(map[string]interface{}, len(tags)+len(fields))
+	for k, v := range tags {
+		columnTypes[k] = "text"
+		values[k] = v
+	}
+	for k, v := range fields {
+		t, ok := fieldTypeOf(v)
+		if !ok {
+			return fmt.Errorf("field '%s' has unsupported type %T", k, v)
+		}
+		columnTypes[k] = postgresColumnTypes[t]
+		values[k] = v
+	}
+	if err := o.ensureColumns(ctx, table, columnTypes); err != nil {
+		return err
+	}
+
+	columns := sortedKeys(values)
+
+	quoted := []string{pq.QuoteIdentifier("time")}
+	placeholders := []string{"$1"}
+	args := []interface{}{ts.UTC()}
+	for i, c := range columns {
+		quoted = append(quoted, pq.QuoteIdentifier(c))
+		placeholders = append(placeholders, fmt.Sprintf("$%d", i+2))
+		args = append(args, values[c])
+	}
+	q := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)",
+		pq.QuoteIdentifier(table), strings.Join(quoted, ", "), strings.Join(placeholders, ", "))
+	_, err := o.db.ExecContext(ctx, q, args...)
+	return err
+}
+
+// ensureColumns creates the given table (as a Timescale hypertable, if configured) and
+// adds any of the given columns it doesn't yet have.
+func (o *postgresOutput) ensureColumns(ctx context.Context, table string, columnTypes map[string]string) error {
+	known, ok := o.columns[table]
+	if !ok {
+		q := fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (time timestamptz NOT NULL)", pq.QuoteIdentifier(table))
+		if _, err := o.db.ExecContext(ctx, q); err != nil {
+			return fmt.Errorf("failed to create table '%s': %w", table, err)
+		}
+		if o.config.Timescale {
+			if _, err := o.db.ExecContext(ctx, "SELECT create_hypertable($1::regclass, 'time', if_not_exists => TRUE)", pq.QuoteIdentifier(table)); err != nil {
+				return fmt.Errorf("failed to create hypertable '%s': %w", table, err)
+			}
+		}
+		known = make(map[string]bool)
+		o.columns[table] = known
+	}
+
+	for c, t := range columnTypes {
+		if known[c] {
+			continue
+		}
+		q := fmt.Sprintf("ALTER TABLE %s ADD COLUMN IF NOT EXISTS %s %s", pq.QuoteIdentifier(table), pq.QuoteIdentifier(c), t)
+		if _, err := o.db.ExecContext(ctx, q); err != nil {
+			return fmt.Errorf("failed to add column '%s' to table '%s': %w", c, table, err)
+		}
+		known[c] = true
+	}
+	return nil
+}
+
+// Close closes the database connection.
+func (o *postgresOutput) Close() {
+	o.db.Close()
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Formats for -format, which controls how -printData prints data.
+const (
+	// PrintFormatTable prints a human-readable summary of the current conditions and pollution.
+	PrintFormatTable = "table"
+	// PrintFormatJSON prints each point as a JSON object, one per line.
+	PrintFormatJSON = "json"
+	// PrintFormatLineProtocol prints each point in InfluxDB line protocol.
+	PrintFormatLineProtocol = "lineprotocol"
+	// PrintFormatCSV prints one CSV row per field of each point.
+	PrintFormatCSV = "csv"
+)
+
+// newPrintOutput returns an output that prints every point to w in the given format. The
+// table format isn't point-based, so it returns nil for that format.
+func newPrintOutput(format string, w io.Writer) (pointOutput, error) {
+	switch format {
+	case "", PrintFormatTable:
+		return nil, nil
+	case PrintFormatJSON:
+		return &jsonPrintOutput{w: w}, nil
+	case PrintFormatLineProtocol:
+		return newLineProtocolOutput(w), nil
+	case PrintFormatCSV:
+		return &csvPrintOutput{w: csv.NewWriter(w)}, nil
+	default:
+		return nil, fmt.Errorf("-format must be '%s', '%s', '%s', or '%s'", PrintFormatTable, PrintFormatJSON, PrintFormatLineProtocol, PrintFormatCSV)
+	}
+}
+
+// jsonPrintOutput prints points as newline-delimited JSON.
+type jsonPrintOutput struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (o *jsonPrintOutput) Name() string { return "print" }
+
+func (o *jsonPrintOutput) WritePoint(_ context.Context, measurement string, tags map[string]string, fields map[string]interface{}, ts time.Time) error {
+	b, err := marshalPoint(measurement, tags, fields, ts)
+	if err != nil {
+		return err
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	_, err = fmt.Fprintf(o.w, "%s\n", b)
+	return err
+}
+
+func (o *jsonPrintOutput) Close() {}
+
+// csvPrintOutput prints points as CSV in long form, with columns measurement, time, tags
+// (as semicolon-separated key=value pairs), field, and value.
+type csvPrintOutput struct {
+	mu          sync.Mutex
+	w           *csv.Writer
+	wroteHeader bool
+}
+
+func (o *csvPrintOutput) Name() string { return "print" }
+
+func (o *csvPrintOutput) WritePoint(_ context.Context, measurement string, tags map[string]string, fields map[string]interface{}, ts time.Time) error {
+	tagPairs := make([]string, 0, len(tags))
+	for _, k := range sortedKeys(tags) {
+		tagPairs = append(tagPairs, k+"="+tags[k])
+	}
+	fieldNames := sortedKeys(fields)
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if !o.wroteHeader {
+		if err := o.w.Write([]string{"measurement", "time", "tags", "field", "value"}); err != nil {
+			return err
+		}
+		o.wroteHeader = true
+	}
+	for _, k := range fieldNames {
+		row := []string{measurement, ts.UTC().Format(time.RFC3339), strings.Join(tagPairs, ";"), k, fmt.Sprint(fields[k])}
+		if err := o.w.Write(row); err != nil {
+			return err
+		}
+	}
+	o.w.Flush()
+	return o.w.Error()
+}
+
+func (o *csvPrintOutput) Close() {}
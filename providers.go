@@ -0,0 +1,235 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/cdzombak/openweather-influxdb-connector/pkg/owmconnector"
+)
+
+// TomorrowIOConfig describes the configuration for the Tomorrow.io provider.
+type TomorrowIOConfig struct {
+	APIKey    string `json:"api_key"`
+	Pollen    bool   `json:"pollen,omitempty"`
+	FireIndex bool   `json:"fire_index,omitempty"`
+}
+
+// Validate checks the Tomorrow.io configuration.
+func (c TomorrowIOConfig) Validate() error {
+	if c.APIKey == "" {
+		return errors.New("api_key must be set")
+	}
+	return nil
+}
+
+// WeatherAPIConfig describes the configuration for the WeatherAPI.com provider.
+type WeatherAPIConfig struct {
+	APIKey string `json:"api_key"`
+}
+
+// Validate checks the WeatherAPI.com configuration.
+func (c WeatherAPIConfig) Validate() error {
+	if c.APIKey == "" {
+		return errors.New("api_key must be set")
+	}
+	return nil
+}
+
+// providerName returns the configured weather provider, or the default "openweathermap".
+func (c Config) providerName() string {
+	if c.Provider == "" {
+		return owmconnector.ProviderOpenWeatherMap
+	}
+	return c.Provider
+}
+
+// primarySources returns the data_source tags written by the primary provider or one of
+// its fallbacks, but not by comparison providers, for queries that summarize this
+// location's primary series.
+func (c Config) primarySources() []string {
+	return append([]string{c.providerName()}, c.FallbackProviders...)
+}
+
+// newProvider returns a client for the given weather provider.
+func (c Config) newProvider(name string) owmconnector.Provider {
+	owmClient := &owmconnector.Client{
+		APIKey:          c.APIKey,
+		Latitude:        c.Latitude,
+		Longitude:       c.Longitude,
+		ElevationMeters: c.ElevationMeters,
+		HTTPClient:      owmHTTPClient,
+	}
+	switch name {
+	case owmconnector.ProviderOpenMeteo:
+		return &owmconnector.OpenMeteoClient{
+			Latitude:        c.Latitude,
+			Longitude:       c.Longitude,
+			ElevationMeters: c.ElevationMeters,
+			HTTPClient:      owmHTTPClient,
+		}
+	case owmconnector.ProviderNWS:
+		nws := &owmconnector.NWSClient{
+			Latitude:        c.Latitude,
+			Longitude:       c.Longitude,
+			ElevationMeters: c.ElevationMeters,
+			Station:         c.NWSStation,
+			HTTPClient:      owmHTTPClient,
+		}
+		// NWS doesn't report air quality; use OpenWeatherMap's if there's an API key, or
+		// Open-Meteo's otherwise
+		if c.APIKey != "" {
+			nws.PollutionProvider = owmClient
+		}
+		return nws
+	case owmconnector.ProviderTomorrowIO:
+		return &owmconnector.TomorrowIOClient{
+			APIKey:          c.TomorrowIO.APIKey,
+			Latitude:        c.Latitude,
+			Longitude:       c.Longitude,
+			ElevationMeters: c.ElevationMeters,
+			Pollen:          c.TomorrowIO.Pollen,
+			FireIndex:       c.TomorrowIO.FireIndex,
+			HTTPClient:      owmHTTPClient,
+		}
+	case owmconnector.ProviderWeatherAPI:
+		return &owmconnector.WeatherAPIClient{
+			APIKey:          c.WeatherAPI.APIKey,
+			Latitude:        c.Latitude,
+			Longitude:       c.Longitude,
+			ElevationMeters: c.ElevationMeters,
+			HTTPClient:      owmHTTPClient,
+		}
+	default:
+		return owmClient
+	}
+}
+
+// validateProvider checks that the given weather provider is supported and configured.
+func (c Config) validateProvider(name string) error {
+	switch name {
+	case owmconnector.ProviderOpenWeatherMap:
+		if c.APIKey == "" {
+			return errors.New("api_key must be set in the config file")
+		}
+	case owmconnector.ProviderOpenMeteo, owmconnector.ProviderNWS:
+	case owmconnector.ProviderTomorrowIO:
+		if c.TomorrowIO == nil {
+			return errors.New("tomorrowio must be set in the config file to use the 'tomorrowio' provider")
+		}
+		if err := c.TomorrowIO.Validate(); err != nil {
+			return fmt.Errorf("tomorrowio: %w", err)
+		}
+	case owmconnector.ProviderWeatherAPI:
+		if c.WeatherAPI == nil {
+			return errors.New("weatherapi must be set in the config file to use the 'weatherapi' provider")
+		}
+		if err := c.WeatherAPI.Validate(); err != nil {
+			return fmt.Errorf("weatherapi: %w", err)
+		}
+	default:
+		return fmt.Errorf("unsupported provider '%s' (expected '%s', '%s', '%s', '%s', or '%s')", name,
+			owmconnector.ProviderOpenWeatherMap, owmconnector.ProviderOpenMeteo, owmconnector.ProviderNWS, owmconnector.ProviderTomorrowIO, owmconnector.ProviderWeatherAPI)
+	}
+	return nil
+}
+
+// writeComparisonObservation fetches the current observation from the given comparison
+// provider and writes it to the weather measurement, tagged with the provider's name.
+// Comparison observations are written uncalibrated and aren't used by any stateful
+// features. Errors are logged rather than fatal, so one provider's outage doesn't fail
+// the run.
+func (r *run) writeComparisonObservation(name string) {
+	provider := r.config.newProvider(name)
+	obs, err := provider.FetchObservation()
+	if err != nil {
+		slog.Error("Failed to get weather from comparison provider", "provider", name, "error", err)
+		return
+	}
+	fields := obs.Fields()
+	if nws, ok := provider.(*owmconnector.NWSClient); ok {
+		if alerts, err := nws.FetchAlerts(); err != nil {
+			slog.Error("Failed to get active alerts from NWS", "error", err)
+		} else {
+			nwsAlertFields(fields, alerts)
+		}
+	}
+	writeTime := inLocal(obs.Time)
+	if r.config.wallClock(schemaWeather) {
+		writeTime = r.time
+	}
+	r.writePoint(schemaWeather, r.config.WeatherMeasurementName, r.config.locationTags(name), fields, writeTime)
+}
+
+// fetchObservationWithFailover fetches the current observation from each of the given
+// providers in turn, returning the first observation that's no older than maxAge (if
+// maxAge > 0) along with the provider that returned it. If every provider fails or
+// returns stale data, the freshest stale observation is returned, so the usual
+// stale_data_action applies; if every provider fails, the last error is returned.
+func fetchObservationWithFailover(providers []owmconnector.Provider, maxAge time.Duration) (owmconnector.Provider, *owmconnector.Observation, error) {
+	var staleProvider owmconnector.Provider
+	var staleObs *owmconnector.Observation
+	var lastErr error
+	for i, p := range providers {
+		obs, err := p.FetchObservation()
+		switch {
+		case err != nil:
+			lastErr = fmt.Errorf("failed to get weather from %s: %w", p.Name(), err)
+		case maxAge > 0 && now().Sub(obs.Time) > maxAge:
+			lastErr = fmt.Errorf("observation from %s is older than max_data_age", p.Name())
+			if staleObs == nil || obs.Time.After(staleObs.Time) {
+				staleProvider, staleObs = p, obs
+			}
+		default:
+			return p, obs, nil
+		}
+		if i+1 < len(providers) {
+			slog.Warn("Weather provider failed; trying the next provider", "provider", p.Name(), "next", providers[i+1].Name(), "error", lastErr)
+		}
+	}
+	if staleObs != nil {
+		return staleProvider, staleObs, nil
+	}
+	return nil, nil, lastErr
+}
+
+// fetchPollutionWithFailover fetches current air pollution from each of the given
+// providers in turn, returning the first reading with any pollutant concentrations along
+// with the provider that returned it.
+func fetchPollutionWithFailover(providers []owmconnector.Provider) (owmconnector.Provider, *owmconnector.Pollution, error) {
+	var lastErr error
+	for i, p := range providers {
+		pol, err := p.FetchPollution()
+		switch {
+		case err != nil:
+			lastErr = fmt.Errorf("failed to get pollution from %s: %w", p.Name(), err)
+		case len(pol.Components) == 0:
+			lastErr = fmt.Errorf("%s didn't return any pollutant concentrations", p.Name())
+		default:
+			return p, pol, nil
+		}
+		if i+1 < len(providers) {
+			slog.Warn("Pollution provider failed; trying the next provider", "provider", p.Name(), "next", providers[i+1].Name(), "error", lastErr)
+		}
+	}
+	return nil, nil, lastErr
+}
+
+// providerAttribution returns the attribution the given provider requires when displaying
+// its data.
+func providerAttribution(name string) string {
+	switch name {
+	case owmconnector.ProviderOpenMeteo:
+		// See https://open-meteo.com/en/license
+		return "Weather data by Open-Meteo.com (https://open-meteo.com/), licensed under CC BY 4.0"
+	case owmconnector.ProviderNWS:
+		return "Weather data provided by the US National Weather Service (https://www.weather.gov/)"
+	case owmconnector.ProviderTomorrowIO:
+		return "Powered by Tomorrow.io (https://www.tomorrow.io/)"
+	case owmconnector.ProviderWeatherAPI:
+		return "Powered by WeatherAPI.com (https://www.weatherapi.com/)"
+	default:
+		return sourceAttribution
+	}
+}
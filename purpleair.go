@@ -0,0 +1,268 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// PurpleAirModeAlongside writes PurpleAir readings as separate purpleair_* fields.
+	PurpleAirModeAlongside = "alongside"
+	// PurpleAirModeBlend also blends PurpleAir's PM readings into the pm25 and pm10 fields
+	// before any AQI is calculated.
+	PurpleAirModeBlend = "blend"
+
+	defaultPurpleAirMaxDistanceKm = 5.0
+	defaultPurpleAirBlendWeight   = 0.5
+	// purpleAirMaxAge is the maximum age of a public sensor's latest reading for it to be
+	// considered when searching for the nearest sensor.
+	purpleAirMaxAge = time.Hour
+
+	earthRadiusKm = 6371.0
+)
+
+// PurpleAirConfig describes the configuration for the PurpleAir air quality input.
+type PurpleAirConfig struct {
+	APIKey        string   `json:"api_key"`
+	SensorIndex   int      `json:"sensor_index,omitempty"`
+	MaxDistanceKm float64  `json:"max_distance_km,omitempty"`
+	Mode          string   `json:"mode,omitempty"`
+	BlendWeight   *float64 `json:"blend_weight,omitempty"`
+}
+
+// Validate checks the PurpleAir configuration.
+func (c PurpleAirConfig) Validate() error {
+	if c.APIKey == "" {
+		return errors.New("api_key must be set")
+	}
+	if c.SensorIndex < 0 || c.MaxDistanceKm < 0 {
+		return errors.New("sensor_index and max_distance_km may not be negative")
+	}
+	switch c.Mode {
+	case "", PurpleAirModeAlongside, PurpleAirModeBlend:
+	default:
+		return fmt.Errorf("mode must be '%s' or '%s'", PurpleAirModeAlongside, PurpleAirModeBlend)
+	}
+	if w := c.Weight(); w < 0 || w > 1 {
+		return errors.New("blend_weight must be between 0 and 1")
+	}
+	return nil
+}
+
+// MaxDistance returns the maximum distance (km) to search for the nearest public sensor.
+func (c PurpleAirConfig) MaxDistance() float64 {
+	if c.MaxDistanceKm == 0 {
+		return defaultPurpleAirMaxDistanceKm
+	}
+	return c.MaxDistanceKm
+}
+
+// Weight returns the weight given to PurpleAir readings when blending.
+func (c PurpleAirConfig) Weight() float64 {
+	if c.BlendWeight == nil {
+		return defaultPurpleAirBlendWeight
+	}
+	return *c.BlendWeight
+}
+
+// PurpleAirReading is the latest reading from a PurpleAir sensor.
+type PurpleAirReading struct {
+	SensorIndex int
+	DistanceKm  float64
+	// PM25CF1 is the sensor's raw PM2.5 (CF=1) concentration, averaged across channels.
+	PM25CF1 float64
+	// PM25 is the EPA-corrected PM2.5 concentration.
+	PM25 float64
+	// PM10 is the sensor's PM10 concentration, or nil if it didn't report one.
+	PM10 *float64
+	// Humidity is the sensor's relative humidity, or nil if it didn't report one.
+	Humidity *float64
+}
+
+var purpleAirFields = []string{"latitude", "longitude", "pm2.5_cf_1", "pm10.0_atm", "humidity", "last_seen"}
+
+// FetchPurpleAir fetches the latest reading from the configured sensor, or the nearest
+// public outdoor sensor within the configured distance of the given location. rh is used
+// for the EPA correction if the sensor doesn't report humidity.
+// See https://api.purpleair.com
+func FetchPurpleAir(c PurpleAirConfig, lat, lon, rh float64) (*PurpleAirReading, error) {
+	var values map[string]*float64
+	var err error
+	if c.SensorIndex != 0 {
+		values, err = fetchPurpleAirSensor(c, c.SensorIndex)
+	} else {
+		values, err = fetchNearestPurpleAirSensor(c, lat, lon)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if values["pm2.5_cf_1"] == nil {
+		return nil, errors.New("PurpleAir sensor didn't report PM2.5")
+	}
+
+	r := &PurpleAirReading{
+		SensorIndex: int(valueOrZero(values["sensor_index"])),
+		PM25CF1:     *values["pm2.5_cf_1"],
+		PM10:        values["pm10.0_atm"],
+		Humidity:    values["humidity"],
+	}
+	if values["latitude"] != nil && values["longitude"] != nil {
+		r.DistanceKm = distanceKm(lat, lon, *values["latitude"], *values["longitude"])
+	}
+	if r.Humidity != nil {
+		rh = *r.Humidity
+	}
+	r.PM25 = EPACorrectedPurpleAirPM25(r.PM25CF1, rh)
+	return r, nil
+}
+
+func valueOrZero(v *float64) float64 {
+	if v == nil {
+		return 0
+	}
+	return *v
+}
+
+func doPurpleAirRequest(c PurpleAirConfig, path string, q url.Values, v interface{}) error {
+	req, err := http.NewRequest(http.MethodGet, "https://api.purpleair.com/v1/"+path+"?"+q.Encode(), nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("X-API-Key", c.APIKey)
+	resp, err := owmHTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("PurpleAir API returned %s", resp.Status)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("failed to decode PurpleAir response: %w", err)
+	}
+	return nil
+}
+
+// fetchPurpleAirSensor fetches the given sensor's latest values, keyed by field name.
+func fetchPurpleAirSensor(c PurpleAirConfig, index int) (map[string]*float64, error) {
+	q := url.Values{}
+	q.Set("fields", strings.Join(purpleAirFields, ","))
+	var body struct {
+		Sensor map[string]*float64 `json:"sensor"`
+	}
+	if err := doPurpleAirRequest(c, "sensors/"+strconv.Itoa(index), q, &body); err != nil {
+		return nil, err
+	}
+	if body.Sensor == nil {
+		return nil, fmt.Errorf("PurpleAir didn't return sensor %d", index)
+	}
+	return body.Sensor, nil
+}
+
+// fetchNearestPurpleAirSensor fetches the latest values from the nearest public outdoor
+// sensor within the configured distance, keyed by field name.
+func fetchNearestPurpleAirSensor(c PurpleAirConfig, lat, lon float64) (map[string]*float64, error) {
+	// search a bounding box around the location, then pick the nearest sensor within it
+	dLat := c.MaxDistance() / earthRadiusKm * 180 / math.Pi
+	dLon := dLat / math.Cos(lat*math.Pi/180)
+	q := url.Values{}
+	q.Set("fields", strings.Join(purpleAirFields, ","))
+	q.Set("location_type", "0") // outdoor
+	q.Set("max_age", strconv.Itoa(int(purpleAirMaxAge.Seconds())))
+	q.Set("nwlat", strconv.FormatFloat(lat+dLat, 'f', 5, 64))
+	q.Set("selat", strconv.FormatFloat(lat-dLat, 'f', 5, 64))
+	q.Set("nwlng", strconv.FormatFloat(lon-dLon, 'f', 5, 64))
+	q.Set("selng", strconv.FormatFloat(lon+dLon, 'f', 5, 64))
+	var body struct {
+		Fields []string     `json:"fields"`
+		Data   [][]*float64 `json:"data"`
+	}
+	if err := doPurpleAirRequest(c, "sensors", q, &body); err != nil {
+		return nil, err
+	}
+
+	var nearest map[string]*float64
+	nearestDist := math.Inf(1)
+	for _, row := range body.Data {
+		values := make(map[string]*float64, len(body.Fields))
+		for i, f := range body.Fields {
+			if i < len(row) {
+				values[f] = row[i]
+			}
+		}
+		if values["latitude"] == nil || values["longitude"] == nil || values["pm2.5_cf_1"] == nil {
+			continue
+		}
+		d := distanceKm(lat, lon, *values["latitude"], *values["longitude"])
+		if d <= c.MaxDistance() && d < nearestDist {
+			nearest, nearestDist = values, d
+		}
+	}
+	if nearest == nil {
+		return nil, fmt.Errorf("no public PurpleAir sensor reported within %.1f km", c.MaxDistance())
+	}
+	return nearest, nil
+}
+
+// EPACorrectedPurpleAirPM25 applies the US EPA's nationwide correction for PurpleAir
+// sensors to a raw PM2.5 (CF=1) concentration (ug/m^3), given relative humidity (%).
+// See https://www.airnow.gov/sites/default/files/2021-10/Fire-and-Smoke-Map-Sensor-Data-Correction.pdf (Barkjohn et al., 2021)
+func EPACorrectedPurpleAirPM25(pa, rh float64) float64 {
+	var v float64
+	switch {
+	case pa < 30:
+		v = 0.524*pa - 0.0862*rh + 5.75
+	case pa < 50:
+		w := pa/20 - 3.0/2
+		v = (0.786*w+0.524*(1-w))*pa - 0.0862*rh + 5.75
+	case pa < 210:
+		v = 0.786*pa - 0.0862*rh + 5.75
+	case pa < 260:
+		w := pa/50 - 21.0/5
+		v = (0.69*w+0.786*(1-w))*pa - 0.0862*rh*(1-w) + 2.966*w + 5.75*(1-w) + 8.84e-4*pa*pa*w
+	default:
+		v = 2.966 + 0.69*pa + 8.84e-4*pa*pa
+	}
+	return math.Max(0, v)
+}
+
+// distanceKm returns the great-circle distance between two points, in km.
+func distanceKm(lat1, lon1, lat2, lon2 float64) float64 {
+	toRad := math.Pi / 180
+	dLat := (lat2 - lat1) * toRad
+	dLon := (lon2 - lon1) * toRad
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(lat1*toRad)*math.Cos(lat2*toRad)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadiusKm * math.Asin(math.Sqrt(a))
+}
+
+// Apply writes the reading's purpleair_* fields to the pollution fields, and in blend mode,
+// blends its PM concentrations into the pollution reading's components. A concentration
+// the sensor didn't report is neither written nor blended.
+func (c PurpleAirConfig) Apply(r *PurpleAirReading, components map[string]float64, fields map[string]interface{}) {
+	pm := map[string]float64{"pm25": r.PM25}
+	fields["purpleair_pm25"] = r.PM25
+	fields["purpleair_pm25_cf1"] = r.PM25CF1
+	if r.PM10 != nil {
+		pm["pm10"] = *r.PM10
+		fields["purpleair_pm10"] = *r.PM10
+	}
+	fields["purpleair_sensor_index"] = r.SensorIndex
+	fields["purpleair_distance_km"] = r.DistanceKm
+	if c.Mode != PurpleAirModeBlend {
+		return
+	}
+	w := c.Weight()
+	for field, v := range pm {
+		if orig, ok := components[field]; ok {
+			v = w*v + (1-w)*orig
+		}
+		components[field] = v
+	}
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestEPACorrectedPurpleAirPM25(t *testing.T) {
+	tests := []struct {
+		pa, rh float64
+		want   float64
+	}{
+		{10, 50, 6.68},
+		{0, 100, 0},
+		{40, 50, 27.64},
+		{100, 40, 80.902},
+		{235, 40, 200.47345},
+		{300, 40, 289.526},
+	}
+	for _, tt := range tests {
+		if got := EPACorrectedPurpleAirPM25(tt.pa, tt.rh); math.Abs(got-tt.want) > 1e-6 {
+			t.Errorf("EPACorrectedPurpleAirPM25(%v, %v) = %v; want %v", tt.pa, tt.rh, got, tt.want)
+		}
+	}
+
+	// the piecewise correction is continuous at each breakpoint
+	for _, pa := range []float64{30, 50, 210, 260} {
+		below, at := EPACorrectedPurpleAirPM25(pa-1e-9, 40), EPACorrectedPurpleAirPM25(pa, 40)
+		if math.Abs(below-at) > 1e-6 {
+			t.Errorf("EPACorrectedPurpleAirPM25 is discontinuous at %v: %v just below; %v at", pa, below, at)
+		}
+	}
+}
+
+func TestPurpleAirApply(t *testing.T) {
+	reading := &PurpleAirReading{SensorIndex: 1234, DistanceKm: 1.5, PM25CF1: 20, PM25: 12, PM10: ptr(30.0)}
+	tests := []struct {
+		name           string
+		c              PurpleAirConfig
+		reading        *PurpleAirReading
+		wantComponents map[string]float64
+	}{
+		{
+			name:           "alongside",
+			c:              PurpleAirConfig{},
+			reading:        reading,
+			wantComponents: map[string]float64{"pm25": 20, "pm10": 40},
+		},
+		{
+			name:           "blend",
+			c:              PurpleAirConfig{Mode: PurpleAirModeBlend, BlendWeight: ptr(0.25)},
+			reading:        reading,
+			wantComponents: map[string]float64{"pm25": 18, "pm10": 37.5},
+		},
+		{
+			name:           "blend without PM10",
+			c:              PurpleAirConfig{Mode: PurpleAirModeBlend},
+			reading:        &PurpleAirReading{SensorIndex: 1234, PM25CF1: 20, PM25: 12},
+			wantComponents: map[string]float64{"pm25": 16, "pm10": 40},
+		},
+	}
+	for _, tt := range tests {
+		components := map[string]float64{"pm25": 20, "pm10": 40}
+		fields := make(map[string]interface{})
+		tt.c.Apply(tt.reading, components, fields)
+		for k, want := range tt.wantComponents {
+			if got := components[k]; math.Abs(got-want) > 1e-9 {
+				t.Errorf("%s: components[%q] = %v; want %v", tt.name, k, got, want)
+			}
+		}
+		if fields["purpleair_pm25"] != tt.reading.PM25 || fields["purpleair_sensor_index"] != 1234 {
+			t.Errorf("%s: fields = %v; want the reading's purpleair_* fields", tt.name, fields)
+		}
+		if _, ok := fields["purpleair_pm10"]; ok != (tt.reading.PM10 != nil) {
+			t.Errorf("%s: purpleair_pm10 present = %t; want %t", tt.name, ok, tt.reading.PM10 != nil)
+		}
+	}
+}
+
+func TestDistanceKm(t *testing.T) {
+	// Ann Arbor to Detroit
+	if got := distanceKm(42.2808, -83.743, 42.3314, -83.0458); math.Abs(got-57.5) > 0.5 {
+		t.Errorf("distanceKm() = %v; want about 57.5 km", got)
+	}
+	if got := distanceKm(42.28, -83.74, 42.28, -83.74); got != 0 {
+		t.Errorf("distanceKm() between the same point = %v; want 0", got)
+	}
+}
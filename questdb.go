@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	influxdb2 "github.com/influxdata/influxdb-client-go/v2"
+	"github.com/influxdata/influxdb-client-go/v2/api/write"
+)
+
+const (
+	defaultQuestDBName = "questdb"
+	questDBTimeout     = 10 * time.Second
+)
+
+// QuestDBConfig describes the configuration for the QuestDB output, which writes points
+// in InfluxDB line protocol to QuestDB's ILP-over-HTTP endpoint.
+type QuestDBConfig struct {
+	Name  string `json:"name,omitempty"`
+	URL   string `json:"url"`
+	User  string `json:"user,omitempty"`
+	Pass  string `json:"password,omitempty"`
+	Token string `json:"token,omitempty"`
+}
+
+// Validate checks the QuestDB configuration.
+func (c QuestDBConfig) Validate() error {
+	if c.URL == "" {
+		return errors.New("url must be set")
+	}
+	u, err := url.Parse(c.URL)
+	if err != nil {
+		return fmt.Errorf("invalid url: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return errors.New("url must be an http or https URL")
+	}
+	return nil
+}
+
+// OutputName returns the configured output name, or the default "questdb".
+func (c QuestDBConfig) OutputName() string {
+	if c.Name == "" {
+		return defaultQuestDBName
+	}
+	return c.Name
+}
+
+// questDBOutput writes points to QuestDB. Each measurement is written to the table of
+// the same name, which QuestDB creates automatically.
+type questDBOutput struct {
+	config QuestDBConfig
+	client *http.Client
+}
+
+// newQuestDBOutput returns a QuestDB output for the given configuration.
+func newQuestDBOutput(c QuestDBConfig) *questDBOutput {
+	return &questDBOutput{config: c, client: &http.Client{Timeout: questDBTimeout}}
+}
+
+// Name returns the output's name, for output routing.
+func (o *questDBOutput) Name() string {
+	return o.config.OutputName()
+}
+
+// WritePoint writes a single point to QuestDB.
+func (o *questDBOutput) WritePoint(ctx context.Context, measurement string, tags map[string]string, fields map[string]interface{}, ts time.Time) error {
+	line := write.PointToLineProtocol(influxdb2.NewPoint(measurement, tags, fields, ts), time.Nanosecond)
+
+	ctx, cancel := context.WithTimeout(ctx, questDBTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSuffix(o.config.URL, "/")+"/write?precision=n", strings.NewReader(line))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	if o.config.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+o.config.Token)
+	} else if o.config.User != "" || o.config.Pass != "" {
+		req.SetBasicAuth(o.config.User, o.config.Pass)
+	}
+
+	resp, err := o.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("QuestDB returned %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
+
+// Close is a no-op; each point is written with its own request.
+func (o *questDBOutput) Close() {}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const recordManifestFile = "manifest.json"
+
+// recordedHeaders are the only response headers recorded. Others, like cookies, may be
+// credentials, and nothing reads them.
+var recordedHeaders = []string{"Content-Type"}
+
+// replaying is true if this run is replaying recorded responses; nothing is sent to
+// notification services or by e-mail while replaying.
+var replaying bool
+
+// recordManifest describes the raw API responses recorded during a single run.
+type recordManifest struct {
+	// Time is the run's start time; a replay pretends it's the current time.
+	Time      time.Time        `json:"time"`
+	Responses []recordResponse `json:"responses"`
+}
+
+// recordResponse is a recorded response, and the request it was returned for. URL has any
+// API key and credentials redacted, and only recordedHeaders are kept, so recordings can
+// be shared and replayed with a different key.
+type recordResponse struct {
+	Method string      `json:"method"`
+	URL    string      `json:"url"`
+	Status int         `json:"status"`
+	Header http.Header `json:"header,omitempty"`
+	File   string      `json:"file"`
+}
+
+// recordTransport is an http.RoundTripper that saves every response to a run directory,
+// for later replay.
+type recordTransport struct {
+	next     http.RoundTripper
+	dir      string
+	mu       sync.Mutex
+	manifest recordManifest
+}
+
+func (t *recordTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		return resp, err
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	u := redactedURL(req)
+	name := fmt.Sprintf("%02d-%s.body", len(t.manifest.Responses)+1, strings.Trim(strings.ReplaceAll(u.Path, "/", "_"), "_"))
+	if err := os.WriteFile(filepath.Join(t.dir, name), body, 0o644); err != nil {
+		return nil, fmt.Errorf("failed to record response: %w", err)
+	}
+	t.manifest.Responses = append(t.manifest.Responses, recordResponse{
+		Method: req.Method,
+		URL:    u.String(),
+		Status: resp.StatusCode,
+		Header: recordHeader(resp.Header),
+		File:   name,
+	})
+	// nb. the manifest is rewritten after every response, so a run that exits early
+	// still leaves a usable recording
+	b, err := json.MarshalIndent(t.manifest, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(filepath.Join(t.dir, recordManifestFile), b, 0o644); err != nil {
+		return nil, fmt.Errorf("failed to record response: %w", err)
+	}
+	return resp, nil
+}
+
+// recordHeader returns the subset of the given response headers that's recorded.
+func recordHeader(h http.Header) http.Header {
+	recorded := make(http.Header)
+	for _, k := range recordedHeaders {
+		if v := h.Values(k); len(v) > 0 {
+			recorded[k] = v
+		}
+	}
+	return recorded
+}
+
+// enableRecording makes the API HTTP client save every raw response to a new run directory
+// in dir, named for the run's start time.
+func enableRecording(dir string, runTime time.Time) (string, error) {
+	runDir := filepath.Join(dir, runTime.UTC().Format("20060102T150405Z"))
+	if err := os.MkdirAll(runDir, 0o755); err != nil {
+		return "", err
+	}
+	next := owmHTTPClient.Transport
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	owmHTTPClient.Transport = &recordTransport{next: next, dir: runDir, manifest: recordManifest{Time: runTime}}
+	return runDir, nil
+}
+
+// replayTransport is an http.RoundTripper that returns recorded responses instead of
+// making requests. Requests are matched to responses by method and (redacted) URL, in the
+// order they were recorded.
+type replayTransport struct {
+	dir       string
+	mu        sync.Mutex
+	responses []recordResponse
+	used      []bool
+}
+
+func (t *replayTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body != nil {
+		req.Body.Close()
+	}
+	u := redactedURL(req)
+	key := u.String()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for i, r := range t.responses {
+		if t.used[i] || r.Method != req.Method || r.URL != key {
+			continue
+		}
+		t.used[i] = true
+		body, err := os.ReadFile(filepath.Join(t.dir, r.File))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read recorded response: %w", err)
+		}
+		return &http.Response{
+			Status:        fmt.Sprintf("%d %s", r.Status, http.StatusText(r.Status)),
+			StatusCode:    r.Status,
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        r.Header,
+			Body:          io.NopCloser(bytes.NewReader(body)),
+			ContentLength: int64(len(body)),
+			Request:       req,
+		}, nil
+	}
+	return nil, fmt.Errorf("no recorded response for %s %s", req.Method, key)
+}
+
+// enableReplay makes the API HTTP client return the responses recorded in the given run
+// directory instead of making requests, and returns the recorded run's start time.
+func enableReplay(runDir string) (time.Time, error) {
+	b, err := os.ReadFile(filepath.Join(runDir, recordManifestFile))
+	if err != nil {
+		return time.Time{}, err
+	}
+	var m recordManifest
+	if err := json.Unmarshal(b, &m); err != nil {
+		return time.Time{}, fmt.Errorf("failed to parse %s: %w", recordManifestFile, err)
+	}
+	owmHTTPClient.Transport = &replayTransport{
+		dir:       runDir,
+		responses: m.Responses,
+		used:      make([]bool, len(m.Responses)),
+	}
+	replaying = true
+	return m.Time, nil
+}
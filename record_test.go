@@ -0,0 +1,65 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRecordingHasNoCredentials(t *testing.T) {
+	const body = `{"lat": 42.28, "lon": -83.74}`
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Set-Cookie", "session=cookie-secret")
+		w.Header().Set("WWW-Authenticate", `Basic realm="auth-secret"`)
+		io.WriteString(w, body)
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	recorder := &recordTransport{next: http.DefaultTransport, dir: dir, manifest: recordManifest{Time: time.Now()}}
+	u := strings.Replace(srv.URL, "http://", "http://owntracks:password-secret@", 1) + "/api/0/last?user=me&appid=key-secret&token=token-secret"
+	resp, err := (&http.Client{Transport: recorder}).Get(u)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range files {
+		b, err := os.ReadFile(filepath.Join(dir, f.Name()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, secret := range []string{"owntracks", "password-secret", "key-secret", "token-secret", "cookie-secret", "auth-secret"} {
+			if strings.Contains(string(b), secret) {
+				t.Errorf("%s contains %q", f.Name(), secret)
+			}
+		}
+	}
+
+	replayer := &replayTransport{dir: dir, responses: recorder.manifest.Responses, used: make([]bool, len(recorder.manifest.Responses))}
+	resp, err = (&http.Client{Transport: replayer}).Get(u)
+	if err != nil {
+		t.Fatalf("replaying the recording: %s", err)
+	}
+	defer resp.Body.Close()
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != body {
+		t.Errorf("replayed body = %q; want %q", b, body)
+	}
+	if ct := resp.Header.Get("Content-Type"); ct != "application/json" {
+		t.Errorf("replayed Content-Type = %q; want application/json", ct)
+	}
+}
@@ -0,0 +1,632 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	owm "github.com/briandowns/openweathermap"
+
+	"github.com/cdzombak/openweather-influxdb-connector/pkg/owmconnector"
+)
+
+// run is a single run of the program: it fetches current data, writes it to the
+// configured outputs, and sends any notifications, e-mail, and uploads.
+type run struct {
+	config Config
+	opts   options
+	state  *State
+	writer *influxWriter
+	// printReports is set if the weather and pollution reports should be printed, i.e.
+	// with -printData in the table format.
+	printReports bool
+
+	providers []owmconnector.Provider
+	// time is the wall clock time of the run, set once the observation is fetched.
+	time      time.Time
+	forecast5 *owm.Forecast5WeatherData
+	summary   []interface{}
+	// energyPrices are the prices fetched for this run, in energyUnit, if energy_prices
+	// is configured.
+	energyPrices []EnergyPrice
+	energyArea   string
+	energyUnit   string
+}
+
+// run fetches and writes everything, then pings the heartbeat URL and exits with an
+// error if the observation was stale.
+func (r *run) run() {
+	r.providers = []owmconnector.Provider{r.config.newProvider(r.config.providerName())}
+	for _, name := range r.config.FallbackProviders {
+		r.providers = append(r.providers, r.config.newProvider(name))
+	}
+	provider, obs, err := fetchObservationWithFailover(r.providers, r.config.maxDataAge())
+	if err != nil {
+		fatalf("Failed to get weather: %s", err)
+	}
+	r.time = now()
+	r.config.Calibration.ApplyObservation(obs)
+	weatherTime := inLocal(obs.Time)
+
+	stale := false
+	if maxDataAge := r.config.maxDataAge(); maxDataAge > 0 && now().Sub(weatherTime) > maxDataAge {
+		if r.config.StaleDataAction != StaleDataActionTag {
+			fatalf("Observation from %s is older than max_data_age (%s); not writing it.", weatherTime.Format(time.RFC3339), r.config.MaxDataAge)
+		}
+		slog.Warn("Observation is older than max_data_age; tagging it stale", "time", weatherTime, "max_data_age", r.config.MaxDataAge)
+		stale = true
+	}
+
+	r.fetchEnergyPrices()
+	wxFields, wxReport := r.writeWeather(provider, obs, stale)
+	for _, name := range r.config.ComparisonProviders {
+		r.writeComparisonObservation(name)
+	}
+	polFields, polReport := r.writePollution(obs)
+	r.writeAirNow()
+	r.writeOpenAQ()
+	notificationData := map[string]map[string]interface{}{
+		schemaWeather:   wxFields,
+		schemaPollution: polFields,
+	}
+	if pollenFields := r.writePollen(); pollenFields != nil {
+		notificationData[schemaPollen] = pollenFields
+	}
+	r.writeMETAR()
+	r.writeMarine()
+	r.writeOWMPaid()
+	r.writeClimateForecast()
+	r.writeEnergyPrices()
+	if freezeFields := r.writeFreezeRisk(weatherTime); freezeFields != nil {
+		notificationData[schemaFreezeRisk] = freezeFields
+	}
+
+	r.send(notificationData, weatherTime, wxReport+"\n"+polReport, wxFields)
+	r.finish()
+
+	if stale {
+		msg := fmt.Sprintf("Observation from %s is older than max_data_age (%s)", weatherTime.Format(time.RFC3339), r.config.MaxDataAge)
+		pingHeartbeat(true, msg)
+		slog.Error(msg)
+		os.Exit(1)
+	}
+	if n := r.writer.Failures(); n > 0 {
+		pingHeartbeat(true, fmt.Sprintf("%d point(s) failed to write to InfluxDB", n))
+	} else {
+		pingHeartbeat(false, "")
+	}
+}
+
+// writePoint validates the given point against schema, if validate_output is set, and
+// writes it. A validation failure is fatal; a write failure is logged. It reports whether
+// the point was written.
+func (r *run) writePoint(schema, measurement string, tags map[string]string, fields map[string]interface{}, ts time.Time) bool {
+	if r.config.ValidateOutput {
+		if err := ValidateFields(schema, fields); err != nil {
+			fatal(err)
+		}
+	}
+	if err := r.writer.WritePoint(measurement, tags, fields, ts); err != nil {
+		slog.Error("Failed to write to influx", "measurement", measurement, "tags", tags, "error", err)
+		return false
+	}
+	return true
+}
+
+// writeObservation writes an observation taken at obsTime, like writePoint, unless
+// skip_unchanged_observations is set and it's already been written. If the schema uses
+// wall clock timestamps, the point is timestamped with the run's time and the observation
+// time is written to the observation_time field.
+func (r *run) writeObservation(schema, measurement string, tags map[string]string, fields map[string]interface{}, obsTime time.Time) {
+	if r.config.SkipUnchangedObservations && r.state.ObservationUnchanged(measurement, obsTime) {
+		slog.Info("Skipping unchanged observation", "measurement", measurement, "time", obsTime)
+		return
+	}
+	ts := obsTime
+	if r.config.wallClock(schema) {
+		ts = r.time
+		fields["observation_time"] = obsTime.Unix()
+	}
+	if r.writePoint(schema, measurement, tags, fields, ts) {
+		r.state.RecordObservation(measurement, obsTime)
+	}
+}
+
+// getForecast5 fetches the 5-day forecast at most once per run, for the features that use it.
+func (r *run) getForecast5() (*owm.Forecast5WeatherData, error) {
+	if r.forecast5 != nil {
+		return r.forecast5, nil
+	}
+	var err error
+	r.forecast5, err = fetchForecast5(r.config.APIKey, r.config.coords())
+	return r.forecast5, err
+}
+
+// writeWeather writes the observation to the weather measurement, the ecobee-compatible
+// measurements, and the degree day measurements. It returns the weather fields and a
+// human-readable report.
+func (r *run) writeWeather(provider owmconnector.Provider, obs *owmconnector.Observation, stale bool) (map[string]interface{}, string) {
+	source := provider.Name()
+	weatherTime := inLocal(obs.Time)
+
+	report := fmt.Sprintf("Conditions at %s:\n", weatherTime) +
+		fmt.Sprintf("\ttemperature: %.1f degF\n\tpressure: %.0f mb\n\thumidity: %d%%\n\tdew point: %.1f degF\n\twind: %.0f at %.1f mph\n\tvisibility: %.1f miles\n\tcloud cover: %d%%\n",
+			obs.Temp, obs.Pressure, obs.Humidity, obs.DewPoint, obs.WindBearing, obs.WindSpeed, obs.VisibilityMiles, obs.CloudCoverPercent)
+	if r.printReports {
+		fmt.Print(report)
+	}
+
+	fields := obs.Fields()
+	if nws, ok := provider.(*owmconnector.NWSClient); ok {
+		if alerts, err := nws.FetchAlerts(); err != nil {
+			slog.Error("Failed to get active alerts from NWS", "error", err)
+		} else {
+			nwsAlertFields(fields, alerts)
+		}
+	}
+	if r.config.StateDir != "" {
+		if trend, ok := r.state.PressureTrend3h(weatherTime, obs.Pressure.Unwrap()); ok {
+			fields["pressure_trend_3h_mb"] = trend
+			fields["pressure_trend"] = PressureTrendCategory(trend)
+		}
+		r.state.RecordPressure(weatherTime, obs.Pressure.Unwrap())
+	}
+
+	tags := r.config.locationTags(source)
+	if r.config.Climatology != nil {
+		clim, ok, err := r.config.Climatology.Compare(r.writer, r.config.WeatherMeasurementName, tags, weatherTime, obs.Temp.Unwrap())
+		if err != nil {
+			slog.Warn("Failed to query historical temperatures", "error", err)
+		} else if ok {
+			fields["temp_normal_f"] = clim.Normal
+			fields["temp_departure_from_normal"] = clim.Departure
+			fields["temp_percentile"] = clim.Percentile
+			fields["climatology_samples"] = clim.Samples
+		}
+	}
+	if !r.config.AttributionDisabled {
+		fields["attribution"] = providerAttribution(source)
+	}
+	if price, ok := CurrentEnergyPrice(r.energyPrices, r.time); ok {
+		fields["energy_price"] = price
+	}
+	r.config.Smoothing.Apply(r.state, r.config.WeatherMeasurementName, fields, weatherTime)
+
+	if r.config.DegreeDays != nil || r.config.GrowingDegreeDays != nil {
+		r.writeDegreeDays(source, weatherTime, obs.Temp.Unwrap())
+	}
+
+	if stale {
+		tags[staleTag] = "true"
+	}
+	if r.config.WriteEcobeeWeatherMeasurement && !stale {
+		r.writeObservation(schemaEcobee, r.config.ecobeeMeasurement(), r.config.ecobeeTags(source), ecobeeFields(obs), weatherTime)
+	}
+	if r.config.WriteEcobeeForecast {
+		r.writeEcobeeForecast()
+	}
+	r.writeObservation(schemaWeather, r.config.WeatherMeasurementName, tags, fields, weatherTime)
+	return fields, report
+}
+
+// writeDegreeDays records the temperature, and if that completes a day, writes that day's
+// degree days and growing degree days.
+func (r *run) writeDegreeDays(source string, t time.Time, tempF float64) {
+	day := r.state.RecordDailyTemp(t, tempF)
+	if day == nil {
+		return
+	}
+	dayStart := day.Start(t.Location())
+
+	if dd := r.config.DegreeDays; dd != nil {
+		base := dd.BaseTemp()
+		r.writePoint(schemaDegreeDays, dd.Measurement(), r.config.locationTags(source), map[string]interface{}{
+			"hdd":         HeatingDegreeDays(day.MeanF(), base),
+			"cdd":         CoolingDegreeDays(day.MeanF(), base),
+			"base_temp_f": base,
+			"temp_min_f":  day.MinF,
+			"temp_max_f":  day.MaxF,
+			"temp_mean_f": day.MeanF(),
+		}, dayStart)
+	}
+
+	if gddConfig := r.config.GrowingDegreeDays; gddConfig != nil {
+		base := gddConfig.BaseTemp()
+		upper := gddConfig.UpperTemp()
+		gdd := GrowingDegreeDays(day.MinF, day.MaxF, base, upper)
+		r.writePoint(schemaGDD, gddConfig.Measurement(), r.config.locationTags(source), map[string]interface{}{
+			"gdd":              gdd,
+			"gdd_season_total": r.state.AddGrowingDegreeDays(gddConfig.SeasonStartFor(dayStart), gdd),
+			"base_temp_f":      base,
+			"upper_temp_f":     upper,
+			"temp_min_f":       day.MinF,
+			"temp_max_f":       day.MaxF,
+		}, dayStart)
+	}
+}
+
+// writeEcobeeForecast writes the ecobee-compatible daily forecast.
+func (r *run) writeEcobeeForecast() {
+	forecast, err := r.getForecast5()
+	if err != nil {
+		slog.Error("Failed to fetch forecast for ecobee forecast", "error", err)
+		return
+	}
+	for _, day := range ecobeeForecastDays(forecast, now(), localTZ) {
+		r.writePoint(schemaEcobeeForecast, ecobeeForecastMeasurementName, r.config.ecobeeTags(owmconnector.ProviderOpenWeatherMap), day.Fields, day.Start)
+	}
+}
+
+// pollutionCategoryTags maps the AQI category name fields to the tags they're also written
+// as if pollution_category_tags is set.
+var pollutionCategoryTags = map[string]string{
+	"aqi_us_name":  "aqi_us_category",
+	"aqi_eu_name":  "aqi_eu_category",
+	"aqi_uk_name":  "aqi_uk_category",
+	"aqhi_ca_name": "aqhi_ca_category",
+}
+
+// writePollution fetches current air pollution, with failover, and writes it to the
+// pollution measurement. It returns the pollution fields and a human-readable report.
+func (r *run) writePollution(obs *owmconnector.Observation) (map[string]interface{}, string) {
+	provider, pollution, err := fetchPollutionWithFailover(r.providers)
+	if err != nil {
+		fatalf("Failed to get pollution: %s", err)
+	}
+	polTime := inLocal(pollution.Time())
+	for k, v := range pollution.Components {
+		pollution.Components[k] = r.config.Calibration.Apply(k, v)
+	}
+
+	paFields := make(map[string]interface{})
+	if r.config.PurpleAir != nil {
+		if pa, err := FetchPurpleAir(*r.config.PurpleAir, r.config.Latitude, r.config.Longitude, obs.Humidity.UnwrapFloat64()); err != nil {
+			slog.Error("Failed to get PurpleAir reading", "error", err)
+		} else {
+			r.config.PurpleAir.Apply(pa, pollution.Components, paFields)
+		}
+	}
+
+	opts := owmconnector.PollutionOptions{Standards: r.config.AQIStandards}
+	if r.config.StateDir != "" {
+		pm25, hasPm25 := pollution.Components["pm25"]
+		pm10, hasPm10 := pollution.Components["pm10"]
+		if hasPm25 && hasPm10 {
+			r.state.RecordPM(polTime, pm25, pm10)
+			opts.PMHistory = r.state.PMHistory
+		}
+	}
+	fields, err := pollution.Fields(opts)
+	if err != nil {
+		fatalf("Failed to calculate AQI: %s", err)
+	}
+	for k, v := range paFields {
+		fields[k] = v
+	}
+
+	report := pollutionReport(polTime, pollution, fields)
+	if r.printReports {
+		fmt.Print(report)
+	}
+
+	tags := r.config.locationTags(provider.Name())
+	if r.config.PollutionCategoryTags {
+		for field, tag := range pollutionCategoryTags {
+			if name, ok := fields[field].(string); ok {
+				tags[tag] = name
+			}
+		}
+	}
+	if !r.config.AttributionDisabled {
+		fields["attribution"] = providerAttribution(provider.Name())
+	}
+	r.config.Smoothing.Apply(r.state, r.config.PollutionMeasurementName, fields, polTime)
+
+	r.writeObservation(schemaPollution, r.config.PollutionMeasurementName, tags, fields, polTime)
+	return fields, report
+}
+
+// pollutionReport returns a human-readable report of the given pollution reading and its
+// fields.
+func pollutionReport(t time.Time, pollution *owmconnector.Pollution, fields map[string]interface{}) string {
+	report := fmt.Sprintf("Pollution at %s:\n", t)
+	if missing := pollution.Missing(); len(missing) > 0 {
+		report += fmt.Sprintf("\tmissing components: %s\n", strings.Join(missing, ", "))
+	}
+	lines := []struct {
+		field  string
+		format string
+	}{
+		{"dominant_pollutant", "\tdominant pollutant: %s\n"},
+		{"aqi_us", "\tAQI (US EPA): %.1f\n"},
+		{"aqi_us_pm", "\tAQI (US EPA, particulates): %.1f\n"},
+		{"aqi_us_nowcast", "\tAQI (US EPA NowCast): %.1f\n"},
+		{"aqi_eu", "\tCAQI (EU): %.1f\n"},
+		{"aqi_uk", "\tDAQI (UK): %d\n"},
+		{"aqhi_ca", "\tAQHI (Canada): %.1f\n"},
+	}
+	for _, l := range lines {
+		if v, ok := fields[l.field]; ok {
+			report += fmt.Sprintf(l.format, v)
+		}
+	}
+	for _, c := range owmconnector.PollutionComponents {
+		if v, ok := pollution.Components[c.Field]; ok {
+			report += fmt.Sprintf("\t%s: %.2f\n", c.Label, v)
+		}
+	}
+	return report
+}
+
+// writeAirNow writes the current AQI from AirNow to the pollution measurement, if configured.
+func (r *run) writeAirNow() {
+	if r.config.AirNow == nil {
+		return
+	}
+	an, err := FetchAirNow(*r.config.AirNow, r.config.Latitude, r.config.Longitude)
+	if err != nil {
+		slog.Error("Failed to get AQI from AirNow", "error", err)
+		return
+	}
+	r.writePoint(schemaPollution, r.config.PollutionMeasurementName, r.config.locationTags(airNowSource), an.Fields(), an.Time)
+}
+
+// writeOpenAQ writes the latest measurements from nearby OpenAQ stations to the pollution
+// measurement, if configured.
+func (r *run) writeOpenAQ() {
+	if r.config.OpenAQ == nil {
+		return
+	}
+	stations, err := FetchOpenAQ(*r.config.OpenAQ, r.config.Latitude, r.config.Longitude)
+	if err != nil {
+		slog.Error("Failed to get measurements from OpenAQ", "error", err)
+		return
+	}
+	for _, st := range stations {
+		tags := r.config.locationTags(openAQSource)
+		tags[stationIDTag] = strconv.Itoa(st.ID)
+		r.writePoint(schemaPollution, r.config.PollutionMeasurementName, tags, st.Fields(), inLocal(st.Time))
+	}
+}
+
+// writePollen writes the current pollen levels, if configured, and returns the pollen
+// fields, or nil if none were written.
+func (r *run) writePollen() map[string]interface{} {
+	if r.config.Pollen == nil {
+		return nil
+	}
+	pollen, err := FetchPollen(*r.config.Pollen, r.config.TomorrowIO, r.config.Latitude, r.config.Longitude)
+	if err != nil {
+		slog.Error("Failed to get pollen", "provider", r.config.Pollen.Provider, "error", err)
+		return nil
+	}
+	if len(pollen.Fields) == 0 {
+		slog.Warn("Pollen provider didn't report any pollen types", "provider", r.config.Pollen.Provider)
+		return nil
+	}
+	r.writePoint(schemaPollen, r.config.Pollen.Measurement(), r.config.locationTags(r.config.Pollen.Provider), pollen.Fields, inLocal(pollen.Time))
+	return pollen.Fields
+}
+
+// writeMETAR writes the latest METAR from the configured station, if configured.
+func (r *run) writeMETAR() {
+	if r.config.METAR == nil {
+		return
+	}
+	metar, err := FetchMETAR(*r.config.METAR)
+	if err != nil {
+		slog.Error("Failed to get METAR", "station", r.config.METAR.Station, "error", err)
+		return
+	}
+	tags := map[string]string{
+		sourceTag:       metarSource,
+		metarStationTag: metar.Station,
+	}
+	r.writePoint(schemaMETAR, r.config.METAR.Measurement(), tags, metar.Fields(), inLocal(metar.Time))
+}
+
+// writeMarine writes the current marine conditions, if configured.
+func (r *run) writeMarine() {
+	if r.config.Marine == nil {
+		return
+	}
+	lat, lon := r.config.Marine.Location(r.config.Latitude, r.config.Longitude)
+	marine, err := FetchMarine(lat, lon)
+	if err != nil {
+		slog.Error("Failed to get marine conditions", "error", err)
+		return
+	}
+	tags := map[string]string{
+		sourceTag: marineSource,
+		latTag:    strconv.FormatFloat(lat, 'f', 3, 64),
+		lonTag:    strconv.FormatFloat(lon, 'f', 3, 64),
+	}
+	r.writePoint(schemaMarine, r.config.Marine.Measurement(), tags, marine.Fields, inLocal(marine.Time))
+}
+
+// writeOWMPaid writes the readings from the enabled OpenWeatherMap paid APIs, if configured.
+func (r *run) writeOWMPaid() {
+	if r.config.OWMPaid == nil {
+		return
+	}
+	c, lat, lon := r.config, r.config.Latitude, r.config.Longitude
+	paidAPIs := []struct {
+		enabled     bool
+		schema      string
+		measurement string
+		fetch       func() (*owmPaidReading, error)
+	}{
+		{c.OWMPaid.RoadRisk, schemaRoadRisk, c.OWMPaid.RoadRiskMeasurement(), func() (*owmPaidReading, error) {
+			return fetchRoadRisk(c.APIKey, lat, lon, now())
+		}},
+		{c.OWMPaid.SolarRadiation, schemaSolarRadiation, c.OWMPaid.SolarRadiationMeasurement(), func() (*owmPaidReading, error) {
+			return fetchSolarRadiation(c.APIKey, lat, lon)
+		}},
+		{c.OWMPaid.FireWeatherIndex, schemaFireWeather, c.OWMPaid.FireWeatherIndexMeasurement(), func() (*owmPaidReading, error) {
+			return fetchFireWeatherIndex(c.APIKey, lat, lon)
+		}},
+	}
+	for _, api := range paidAPIs {
+		if !api.enabled {
+			continue
+		}
+		reading, err := api.fetch()
+		if err != nil {
+			slog.Error("Failed to get data from OpenWeatherMap paid API", "api", api.schema, "error", err)
+			continue
+		}
+		r.writePoint(api.schema, api.measurement, c.locationTags(owmconnector.ProviderOpenWeatherMap), reading.Fields, inLocal(reading.Time))
+	}
+}
+
+// writeClimateForecast writes OpenWeatherMap's 30-day climate forecast, if configured.
+func (r *run) writeClimateForecast() {
+	if r.config.ClimateForecast == nil {
+		return
+	}
+	days, err := fetchClimateForecast(r.config.APIKey, r.config.Latitude, r.config.Longitude, localTZ)
+	if err != nil {
+		slog.Error("Failed to get climate forecast from OpenWeatherMap", "error", err)
+		return
+	}
+	for _, day := range days {
+		r.writePoint(schemaClimateFcst, r.config.ClimateForecast.Measurement(), r.config.locationTags(owmconnector.ProviderOpenWeatherMap), day.Fields, day.Start)
+	}
+}
+
+// fetchEnergyPrices fetches the current energy prices, if configured, for the weather
+// point's energy_price field and the energy price measurement.
+func (r *run) fetchEnergyPrices() {
+	if r.config.EnergyPrices == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), energyPriceTimeout)
+	defer cancel()
+	var err error
+	r.energyPrices, r.energyArea, r.energyUnit, err = FetchEnergyPrices(ctx, *r.config.EnergyPrices)
+	if err != nil {
+		slog.Error("Failed to fetch energy prices", "error", err)
+	}
+}
+
+// writeEnergyPrices writes the energy prices fetched for this run, if configured.
+func (r *run) writeEnergyPrices() {
+	if r.config.EnergyPrices == nil {
+		return
+	}
+	for _, p := range r.energyPrices {
+		tags := map[string]string{
+			energyProviderTag: r.config.EnergyPrices.Provider,
+			energyAreaTag:     r.energyArea,
+			energyUnitTag:     r.energyUnit,
+		}
+		r.writePoint(schemaEnergyPrice, r.config.EnergyPrices.Measurement(), tags, map[string]interface{}{"price": p.Price}, p.Time)
+	}
+}
+
+// writeFreezeRisk calculates and writes the pipe freeze risk as of t, if configured,
+// updating the freeze alert state. It returns the freeze risk fields, or nil if none were
+// written.
+func (r *run) writeFreezeRisk(t time.Time) map[string]interface{} {
+	if r.config.FreezeRisk == nil {
+		return nil
+	}
+	forecast, err := r.getForecast5()
+	if err != nil {
+		slog.Error("Failed to fetch forecast for freeze risk", "error", err)
+		return nil
+	}
+	risk, ok := CalculateFreezeRisk(forecast, t, r.config.FreezeRisk.Horizon())
+	if !ok {
+		return nil
+	}
+
+	threshold := r.config.FreezeRisk.ThresholdScore()
+	alert := risk.Score >= threshold
+	if alert {
+		if r.state.FreezeAlertSince == nil {
+			alertTime := now()
+			r.state.FreezeAlertSince = &alertTime
+		}
+		slog.Warn("Pipe freeze alert",
+			"since", r.state.FreezeAlertSince.Format(time.RFC3339),
+			"score", risk.Score,
+			"threshold", threshold,
+			"forecast_low_f", risk.MinTempF,
+			"forecast_low_time", risk.MinTempTime.Format(time.RFC3339),
+		)
+	} else if r.state.FreezeAlertSince != nil {
+		slog.Info("Pipe freeze alert cleared", "score", risk.Score, "threshold", threshold)
+		r.state.FreezeAlertSince = nil
+	}
+
+	fields := map[string]interface{}{
+		"score":         risk.Score,
+		"threshold":     threshold,
+		"horizon_hours": int(r.config.FreezeRisk.Horizon().Hours()),
+		"min_temp_f":    risk.MinTempF,
+		"max_wind_mph":  risk.MaxWindMph,
+		"alert":         alert,
+	}
+	r.writePoint(schemaFreezeRisk, r.config.FreezeRisk.Measurement(), r.config.locationTags(owmconnector.ProviderOpenWeatherMap), fields, t)
+	return fields
+}
+
+// send sends notifications, the per-run e-mail, and weather network uploads, if
+// configured and the run has side effects.
+func (r *run) send(notificationData map[string]map[string]interface{}, weatherTime time.Time, report string, wxFields map[string]interface{}) {
+	if !r.opts.sideEffects() {
+		return
+	}
+	if r.config.Notifications != nil {
+		sent, failed := r.config.Notifications.Notify(r.state, notificationData, now())
+		r.summary = append(r.summary, "notifications_sent", sent, "notifications_failed", failed)
+	}
+	if r.config.Email != nil && r.config.Email.Mode == EmailModePerRun {
+		if err := sendEmail(*r.config.Email, fmt.Sprintf("Weather at %s", weatherTime.Format("Jan 2 15:04")), report); err != nil {
+			slog.Error("Failed to send e-mail", "error", err)
+			r.summary = append(r.summary, "email", "failed")
+		} else {
+			r.summary = append(r.summary, "email", "sent")
+		}
+	}
+	if r.opts.lineProtocol {
+		return
+	}
+	if r.config.CWOP != nil {
+		if err := SubmitCWOP(*r.config.CWOP, r.config.Latitude, r.config.Longitude, wxFields, weatherTime); err != nil {
+			slog.Error("Failed to submit observation to CWOP", "station_id", r.config.CWOP.StationID, "error", err)
+			r.summary = append(r.summary, "cwop", "failed")
+		} else {
+			r.summary = append(r.summary, "cwop", "sent")
+		}
+	}
+	if r.config.Windy != nil {
+		if err := SubmitWindy(*r.config.Windy, wxFields, weatherTime); err != nil {
+			slog.Error("Failed to submit observation to Windy", "error", err)
+			r.summary = append(r.summary, "windy", "failed")
+		} else {
+			r.summary = append(r.summary, "windy", "sent")
+		}
+	}
+}
+
+// finish saves state, if the run has side effects, closes the outputs, and logs the run
+// summary.
+func (r *run) finish() {
+	if r.config.StateDir != "" && r.opts.sideEffects() {
+		if err := r.state.Save(r.config.StateDir); err != nil {
+			slog.Error("Failed to save state", "state_dir", r.config.StateDir, "error", err)
+		}
+	}
+	r.writer.Close()
+	r.summary = append(r.summary, "influx_points_written", r.writer.Writes(), "influx_points_failed", r.writer.Failures())
+	if disabled := r.writer.DisabledOutputs(); len(disabled) > 0 {
+		r.summary = append(r.summary, "outputs_disabled", strings.Join(disabled, ","))
+	}
+	slog.Info("Run complete", r.summary...)
+}
@@ -0,0 +1,422 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// FieldType is the Influx field type a field is expected to have.
+type FieldType string
+
+// Supported Influx field types.
+const (
+	FieldTypeFloat  FieldType = "float"
+	FieldTypeInt    FieldType = "int"
+	FieldTypeString FieldType = "string"
+	FieldTypeBool   FieldType = "bool"
+)
+
+const (
+	schemaWeather        = "weather"
+	schemaPollution      = "pollution"
+	schemaEcobee         = "ecobee_weather"
+	schemaEcobeeForecast = "ecobee_forecast"
+	schemaDegreeDays     = "degree_days"
+	schemaGDD            = "growing_degree_days"
+	schemaEnergyPrice    = "energy_price"
+	schemaFreezeRisk     = "freeze_risk"
+	schemaPollen         = "pollen"
+	schemaMETAR          = "metar"
+	schemaMarine         = "marine"
+	schemaRoadRisk       = "road_risk"
+	schemaSolarRadiation = "solar_radiation"
+	schemaFireWeather    = "fire_weather_index"
+	schemaClimateFcst    = "climate_forecast"
+	schemaStats          = "stats"
+	schemaLeader         = "connector_leader"
+)
+
+// outputSchemas describes the fields, and their types, that each measurement may contain.
+var outputSchemas = map[string]map[string]FieldType{
+	schemaWeather: {
+		"observation_time":                FieldTypeInt,
+		"temp_f":                          FieldTypeFloat,
+		"temp_c":                          FieldTypeFloat,
+		"rel_humidity":                    FieldTypeInt,
+		"feels_like_f":                    FieldTypeFloat,
+		"feels_like_c":                    FieldTypeFloat,
+		"barometric_pressure_mb":          FieldTypeFloat,
+		"barometric_pressure_inHg":        FieldTypeFloat,
+		"dew_point_f":                     FieldTypeFloat,
+		"dew_point_c":                     FieldTypeFloat,
+		"wind_speed_mph":                  FieldTypeFloat,
+		"wind_speed_kt":                   FieldTypeFloat,
+		"wind_bearing":                    FieldTypeFloat,
+		"visibility_mi":                   FieldTypeFloat,
+		"recommended_max_indoor_humidity": FieldTypeInt,
+		"cloud_cover":                     FieldTypeInt,
+		"apparent_temp_f":                 FieldTypeFloat,
+		"apparent_temp_c":                 FieldTypeFloat,
+		"vpd_kpa":                         FieldTypeFloat,
+		"precip_1h_mm":                    FieldTypeFloat,
+		"energy_price":                    FieldTypeFloat,
+		"frost_point_f":                   FieldTypeFloat,
+		"frost_point_c":                   FieldTypeFloat,
+		"frost_risk":                      FieldTypeBool,
+		"nws_alerts":                      FieldTypeInt,
+		"nws_alert_events":                FieldTypeString,
+		"nws_alert_severity":              FieldTypeString,
+		"pollen_tree_index":               FieldTypeInt,
+		"pollen_grass_index":              FieldTypeInt,
+		"pollen_weed_index":               FieldTypeInt,
+		"fire_index":                      FieldTypeFloat,
+		"heat_index_f":                    FieldTypeFloat,
+		"heat_index_c":                    FieldTypeFloat,
+		"wind_chill_f":                    FieldTypeFloat,
+		"wind_chill_c":                    FieldTypeFloat,
+		"wet_bulb_f":                      FieldTypeFloat,
+		"wet_bulb_c":                      FieldTypeFloat,
+		"humidex":                         FieldTypeFloat,
+		"wbgt_f":                          FieldTypeFloat,
+		"wbgt_c":                          FieldTypeFloat,
+		"wbgt_category":                   FieldTypeString,
+		"pressure_trend_3h_mb":            FieldTypeFloat,
+		"pressure_trend":                  FieldTypeString,
+		"station_pressure_mb":             FieldTypeFloat,
+		"station_pressure_inHg":           FieldTypeFloat,
+		"pressure_altitude_ft":            FieldTypeFloat,
+		"pressure_altitude_m":             FieldTypeFloat,
+		"density_altitude_ft":             FieldTypeFloat,
+		"density_altitude_m":              FieldTypeFloat,
+		"air_density_kg_m3":               FieldTypeFloat,
+		"temp_normal_f":                   FieldTypeFloat,
+		"attribution":                     FieldTypeString,
+		"temp_departure_from_normal":      FieldTypeFloat,
+		"temp_percentile":                 FieldTypeFloat,
+		"climatology_samples":             FieldTypeInt,
+		"solar_elevation":                 FieldTypeFloat,
+		"solar_azimuth":                   FieldTypeFloat,
+		"moon_phase":                      FieldTypeFloat,
+		"moon_illumination":               FieldTypeFloat,
+		"moon_phase_name":                 FieldTypeString,
+		"clear_sky_ghi_wm2":               FieldTypeFloat,
+		"ghi_estimate_wm2":                FieldTypeFloat,
+	},
+	schemaPollution: {
+		"missing_components":     FieldTypeString,
+		"observation_time":       FieldTypeInt,
+		"attribution":            FieldTypeString,
+		"aqi_1_5":                FieldTypeFloat,
+		"aqi_us_pm":              FieldTypeFloat,
+		"aqi_us_pm_name":         FieldTypeString,
+		"aqi_us":                 FieldTypeFloat,
+		"aqi_us_name":            FieldTypeString,
+		"dominant_pollutant":     FieldTypeString,
+		"aqi_us_nowcast":         FieldTypeFloat,
+		"aqi_us_nowcast_name":    FieldTypeString,
+		"pm25_nowcast":           FieldTypeFloat,
+		"pm10_nowcast":           FieldTypeFloat,
+		"aqi_eu":                 FieldTypeFloat,
+		"aqi_eu_name":            FieldTypeString,
+		"aqi_uk":                 FieldTypeInt,
+		"aqi_uk_name":            FieldTypeString,
+		"aqhi_ca":                FieldTypeFloat,
+		"aqhi_ca_name":           FieldTypeString,
+		"co":                     FieldTypeFloat,
+		"no":                     FieldTypeFloat,
+		"no2":                    FieldTypeFloat,
+		"o3":                     FieldTypeFloat,
+		"so2":                    FieldTypeFloat,
+		"pm25":                   FieldTypeFloat,
+		"pm10":                   FieldTypeFloat,
+		"nh3":                    FieldTypeFloat,
+		"purpleair_pm25":         FieldTypeFloat,
+		"purpleair_pm25_cf1":     FieldTypeFloat,
+		"purpleair_pm10":         FieldTypeFloat,
+		"purpleair_sensor_index": FieldTypeInt,
+		"purpleair_distance_km":  FieldTypeFloat,
+		"aqi_us_o3":              FieldTypeFloat,
+		"aqi_us_pm25":            FieldTypeFloat,
+		"aqi_us_pm10":            FieldTypeFloat,
+		"airnow_reporting_area":  FieldTypeString,
+		"station_name":           FieldTypeString,
+		"station_distance_km":    FieldTypeFloat,
+	},
+	schemaEcobee: {
+		"observation_time":                FieldTypeInt,
+		"outdoor_temp":                    FieldTypeFloat,
+		"outdoor_humidity":                FieldTypeInt,
+		"barometric_pressure_mb":          FieldTypeFloat,
+		"barometric_pressure_inHg":        FieldTypeFloat,
+		"dew_point":                       FieldTypeFloat,
+		"wind_speed":                      FieldTypeFloat,
+		"wind_bearing":                    FieldTypeFloat,
+		"visibility_mi":                   FieldTypeFloat,
+		"recommended_max_indoor_humidity": FieldTypeInt,
+		"wind_chill_f":                    FieldTypeFloat,
+		"weather_symbol":                  FieldTypeInt,
+		"condition":                       FieldTypeString,
+		"sky":                             FieldTypeInt,
+	},
+	schemaEcobeeForecast: {
+		"temp_high":              FieldTypeFloat,
+		"temp_low":               FieldTypeFloat,
+		"relative_humidity":      FieldTypeInt,
+		"barometric_pressure_mb": FieldTypeFloat,
+		"wind_speed":             FieldTypeFloat,
+		"wind_bearing":           FieldTypeFloat,
+		"weather_symbol":         FieldTypeInt,
+		"condition":              FieldTypeString,
+		"sky":                    FieldTypeInt,
+	},
+	schemaDegreeDays: {
+		"hdd":         FieldTypeFloat,
+		"cdd":         FieldTypeFloat,
+		"base_temp_f": FieldTypeFloat,
+		"temp_min_f":  FieldTypeFloat,
+		"temp_max_f":  FieldTypeFloat,
+		"temp_mean_f": FieldTypeFloat,
+	},
+	schemaGDD: {
+		"gdd":              FieldTypeFloat,
+		"gdd_season_total": FieldTypeFloat,
+		"base_temp_f":      FieldTypeFloat,
+		"upper_temp_f":     FieldTypeFloat,
+		"temp_min_f":       FieldTypeFloat,
+		"temp_max_f":       FieldTypeFloat,
+	},
+	schemaEnergyPrice: {
+		"price": FieldTypeFloat,
+	},
+	schemaFreezeRisk: {
+		"score":         FieldTypeFloat,
+		"threshold":     FieldTypeFloat,
+		"horizon_hours": FieldTypeInt,
+		"min_temp_f":    FieldTypeFloat,
+		"max_wind_mph":  FieldTypeFloat,
+		"alert":         FieldTypeBool,
+	},
+	schemaPollen: {
+		"tree_index":     FieldTypeInt,
+		"grass_index":    FieldTypeInt,
+		"weed_index":     FieldTypeInt,
+		"tree_category":  FieldTypeString,
+		"grass_category": FieldTypeString,
+		"weed_category":  FieldTypeString,
+		"tree_count":     FieldTypeFloat,
+		"grass_count":    FieldTypeFloat,
+		"weed_count":     FieldTypeFloat,
+	},
+	schemaMETAR: {
+		"raw_metar":       FieldTypeString,
+		"visibility_mi":   FieldTypeFloat,
+		"flight_category": FieldTypeString,
+		"ceiling_ft":      FieldTypeInt,
+		"temp_c":          FieldTypeFloat,
+		"temp_f":          FieldTypeFloat,
+		"dew_point_c":     FieldTypeFloat,
+		"dew_point_f":     FieldTypeFloat,
+		"wind_bearing":    FieldTypeFloat,
+		"wind_speed_kt":   FieldTypeFloat,
+		"wind_gust_kt":    FieldTypeFloat,
+		"altimeter_mb":    FieldTypeFloat,
+		"altimeter_inHg":  FieldTypeFloat,
+		"wx_string":       FieldTypeString,
+	},
+	schemaMarine: {
+		"wave_height_m":        FieldTypeFloat,
+		"wave_height_ft":       FieldTypeFloat,
+		"wave_period_s":        FieldTypeFloat,
+		"wave_direction":       FieldTypeFloat,
+		"swell_wave_height_m":  FieldTypeFloat,
+		"swell_wave_height_ft": FieldTypeFloat,
+		"swell_wave_period_s":  FieldTypeFloat,
+		"swell_wave_direction": FieldTypeFloat,
+		"sea_surface_temp_c":   FieldTypeFloat,
+		"sea_surface_temp_f":   FieldTypeFloat,
+	},
+	schemaRoadRisk: {
+		"air_temp_f":                  FieldTypeFloat,
+		"precipitation_intensity_mmh": FieldTypeFloat,
+		"road_state":                  FieldTypeInt,
+		"road_state_name":             FieldTypeString,
+		"road_temp_f":                 FieldTypeFloat,
+		"alerts":                      FieldTypeInt,
+		"alert_level":                 FieldTypeInt,
+		"alert_events":                FieldTypeString,
+	},
+	schemaSolarRadiation: {
+		"ghi":    FieldTypeFloat,
+		"dni":    FieldTypeFloat,
+		"dhi":    FieldTypeFloat,
+		"ghi_cs": FieldTypeFloat,
+		"dni_cs": FieldTypeFloat,
+		"dhi_cs": FieldTypeFloat,
+	},
+	schemaFireWeather: {
+		"fwi":                FieldTypeFloat,
+		"danger_rating":      FieldTypeInt,
+		"danger_rating_name": FieldTypeString,
+	},
+	schemaClimateFcst: {
+		"temp_max_f":         FieldTypeFloat,
+		"temp_min_f":         FieldTypeFloat,
+		"temp_day_f":         FieldTypeFloat,
+		"temp_night_f":       FieldTypeFloat,
+		"temp_mean_f":        FieldTypeFloat,
+		"temp_anomaly_f":     FieldTypeFloat,
+		"period_temp_mean_f": FieldTypeFloat,
+		"rel_humidity":       FieldTypeInt,
+		"pressure_mb":        FieldTypeFloat,
+		"wind_speed_mph":     FieldTypeFloat,
+		"wind_bearing":       FieldTypeFloat,
+		"cloud_cover":        FieldTypeInt,
+		"rain_mm":            FieldTypeFloat,
+		"snow_mm":            FieldTypeFloat,
+		"condition_id":       FieldTypeInt,
+		"condition":          FieldTypeString,
+	},
+	schemaStats: {
+		"temp_max_f":         FieldTypeFloat,
+		"temp_min_f":         FieldTypeFloat,
+		"temp_mean_f":        FieldTypeFloat,
+		"wind_speed_max_mph": FieldTypeFloat,
+		"precip_total_mm":    FieldTypeFloat,
+		"aqi_us_max":         FieldTypeFloat,
+		"hdd":                FieldTypeFloat,
+		"cdd":                FieldTypeFloat,
+		"gdd":                FieldTypeFloat,
+	},
+	schemaLeader: {
+		"node": FieldTypeString,
+	},
+}
+
+// fieldTypeOf returns the Influx field type the given value would be written as.
+func fieldTypeOf(v interface{}) (FieldType, bool) {
+	switch v.(type) {
+	case float32, float64:
+		return FieldTypeFloat, true
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		return FieldTypeInt, true
+	case string:
+		return FieldTypeString, true
+	case bool:
+		return FieldTypeBool, true
+	default:
+		return "", false
+	}
+}
+
+// ValidateFields checks the given fields against the named output schema, returning an
+// error describing every unknown field, type mismatch, and non-finite float value.
+func ValidateFields(schemaName string, fields map[string]interface{}) error {
+	schema, ok := outputSchemas[schemaName]
+	if !ok {
+		return fmt.Errorf("unknown output schema '%s'", schemaName)
+	}
+
+	keys := sortedKeys(fields)
+
+	var problems []string
+	for _, k := range keys {
+		v := fields[k]
+		expected, known := schema[k]
+		if !known && strings.HasSuffix(k, rawFieldSuffix) {
+			expected, known = schema[strings.TrimSuffix(k, rawFieldSuffix)]
+		}
+		if !known {
+			problems = append(problems, fmt.Sprintf("unknown field '%s'", k))
+			continue
+		}
+		actual, ok := fieldTypeOf(v)
+		if !ok {
+			problems = append(problems, fmt.Sprintf("field '%s' has unsupported type %T", k, v))
+			continue
+		}
+		if actual != expected {
+			problems = append(problems, fmt.Sprintf("field '%s' is %s; expected %s", k, actual, expected))
+			continue
+		}
+		if f, ok := v.(float64); ok && (math.IsNaN(f) || math.IsInf(f, 0)) {
+			problems = append(problems, fmt.Sprintf("field '%s' is not a finite number (%v)", k, f))
+		}
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("%s output failed validation: %s", schemaName, strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// CoerceField converts the given field value to the given Influx field type.
+// Numeric values may be converted between float and int (rounding to the nearest
+// integer), bools may be converted to ints, and any value may be converted to a string.
+func CoerceField(v interface{}, to FieldType) (interface{}, error) {
+	from, ok := fieldTypeOf(v)
+	if !ok {
+		return nil, fmt.Errorf("unsupported type %T", v)
+	}
+	if from == to {
+		return v, nil
+	}
+
+	switch to {
+	case FieldTypeString:
+		return fmt.Sprintf("%v", v), nil
+	case FieldTypeFloat:
+		switch x := v.(type) {
+		case float32:
+			return float64(x), nil
+		case bool:
+			return nil, fmt.Errorf("cannot convert bool to %s", to)
+		}
+		if i, ok := toInt64(v); ok {
+			return float64(i), nil
+		}
+	case FieldTypeInt:
+		switch x := v.(type) {
+		case float32:
+			return int64(math.Round(float64(x))), nil
+		case float64:
+			return int64(math.Round(x)), nil
+		case bool:
+			if x {
+				return int64(1), nil
+			}
+			return int64(0), nil
+		}
+	case FieldTypeBool:
+		// no lossless conversion to bool
+	default:
+		return nil, fmt.Errorf("unknown field type '%s'", to)
+	}
+	return nil, fmt.Errorf("cannot convert %s to %s", from, to)
+}
+
+func toInt64(v interface{}) (int64, bool) {
+	switch x := v.(type) {
+	case int:
+		return int64(x), true
+	case int8:
+		return int64(x), true
+	case int16:
+		return int64(x), true
+	case int32:
+		return int64(x), true
+	case int64:
+		return x, true
+	case uint:
+		return int64(x), true
+	case uint8:
+		return int64(x), true
+	case uint16:
+		return int64(x), true
+	case uint32:
+		return int64(x), true
+	case uint64:
+		return int64(x), true
+	default:
+		return 0, false
+	}
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestValidateFields(t *testing.T) {
+	tests := []struct {
+		name    string
+		schema  string
+		fields  map[string]interface{}
+		wantErr []string
+	}{
+		{
+			name:   "valid",
+			schema: schemaWeather,
+			fields: map[string]interface{}{"temp_f": 72.5, "rel_humidity": 55, "frost_risk": false, "nws_alert_events": "Wind Advisory"},
+		},
+		{
+			name:   "raw field for a smoothed field",
+			schema: schemaWeather,
+			fields: map[string]interface{}{"temp_f": 72.5, "temp_f_raw": 73.1},
+		},
+		{
+			name:    "unknown schema",
+			schema:  "nope",
+			fields:  map[string]interface{}{},
+			wantErr: []string{"unknown output schema 'nope'"},
+		},
+		{
+			name:    "unknown field",
+			schema:  schemaWeather,
+			fields:  map[string]interface{}{"temp_k": 295.0},
+			wantErr: []string{"unknown field 'temp_k'"},
+		},
+		{
+			name:    "type mismatch",
+			schema:  schemaWeather,
+			fields:  map[string]interface{}{"rel_humidity": 55.0},
+			wantErr: []string{"field 'rel_humidity' is float; expected int"},
+		},
+		{
+			name:    "unsupported type",
+			schema:  schemaWeather,
+			fields:  map[string]interface{}{"temp_f": []float64{72.5}},
+			wantErr: []string{"field 'temp_f' has unsupported type []float64"},
+		},
+		{
+			name:    "non-finite values",
+			schema:  schemaWeather,
+			fields:  map[string]interface{}{"temp_f": math.NaN(), "temp_c": math.Inf(1)},
+			wantErr: []string{"field 'temp_c' is not a finite number (+Inf)", "field 'temp_f' is not a finite number (NaN)"},
+		},
+	}
+	for _, tt := range tests {
+		err := ValidateFields(tt.schema, tt.fields)
+		if len(tt.wantErr) == 0 {
+			if err != nil {
+				t.Errorf("%s: ValidateFields() = %v", tt.name, err)
+			}
+			continue
+		}
+		if err == nil {
+			t.Errorf("%s: ValidateFields() succeeded; want an error", tt.name)
+			continue
+		}
+		// nb. problems are reported in field name order
+		if got, want := err.Error(), strings.Join(tt.wantErr, "; "); !strings.HasSuffix(got, want) {
+			t.Errorf("%s: ValidateFields() = %q; want it to end with %q", tt.name, got, want)
+		}
+	}
+}
+
+func TestCoerceField(t *testing.T) {
+	tests := []struct {
+		v       interface{}
+		to      FieldType
+		want    interface{}
+		wantErr bool
+	}{
+		{v: 72.5, to: FieldTypeFloat, want: 72.5},
+		{v: 55, to: FieldTypeFloat, want: 55.0},
+		{v: int64(-3), to: FieldTypeFloat, want: -3.0},
+		{v: float32(1.5), to: FieldTypeFloat, want: float32(1.5)},
+		{v: 72.5, to: FieldTypeInt, want: int64(73)},
+		{v: -72.5, to: FieldTypeInt, want: int64(-73)},
+		{v: float32(2.4), to: FieldTypeInt, want: int64(2)},
+		{v: 55, to: FieldTypeInt, want: 55},
+		{v: true, to: FieldTypeInt, want: int64(1)},
+		{v: false, to: FieldTypeInt, want: int64(0)},
+		{v: 72.5, to: FieldTypeString, want: "72.5"},
+		{v: 55, to: FieldTypeString, want: "55"},
+		{v: true, to: FieldTypeString, want: "true"},
+		{v: "Clear", to: FieldTypeString, want: "Clear"},
+		{v: true, to: FieldTypeBool, want: true},
+		{v: true, to: FieldTypeFloat, wantErr: true},
+		{v: 1, to: FieldTypeBool, wantErr: true},
+		{v: "72.5", to: FieldTypeFloat, wantErr: true},
+		{v: "72", to: FieldTypeInt, wantErr: true},
+		{v: []int{1}, to: FieldTypeString, wantErr: true},
+		{v: 1.0, to: "decimal", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := CoerceField(tt.v, tt.to)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("CoerceField(%#v, %s) = %#v; want an error", tt.v, tt.to, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("CoerceField(%#v, %s) = %v", tt.v, tt.to, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("CoerceField(%#v, %s) = %#v; want %#v", tt.v, tt.to, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// Service managers the service subcommands can generate files for.
+const (
+	ServiceTypeSystemd = "systemd"
+	ServiceTypeLaunchd = "launchd"
+)
+
+const (
+	serviceName        = "openweather-influxdb-connector"
+	launchdLabel       = "com.dzombak." + serviceName
+	systemdUnitDir     = "/etc/systemd/system"
+	defaultServiceType = ServiceTypeSystemd
+)
+
+// serviceFile is a file generated by the service subcommands.
+type serviceFile struct {
+	Path    string
+	Content string
+}
+
+// serviceFiles returns the files that run the program with the given config file every
+// interval, for the given service manager. If serviceType is empty, launchd is used on
+// macOS and systemd elsewhere.
+func serviceFiles(serviceType, configPath string, interval time.Duration) ([]serviceFile, string, error) {
+	if serviceType == "" {
+		serviceType = defaultServiceType
+		if runtime.GOOS == "darwin" {
+			serviceType = ServiceTypeLaunchd
+		}
+	}
+	if interval < time.Minute {
+		return nil, "", errors.New("-serviceInterval must be at least 1m")
+	}
+	bin, err := os.Executable()
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to find this program's path: %w", err)
+	}
+	if resolved, err := filepath.EvalSymlinks(bin); err == nil {
+		bin = resolved
+	}
+	configPath, err = filepath.Abs(configPath)
+	if err != nil {
+		return nil, "", err
+	}
+
+	switch serviceType {
+	case ServiceTypeSystemd:
+		return systemdServiceFiles(bin, configPath, interval), "systemctl daemon-reload && systemctl enable --now " + serviceName + ".timer", nil
+	case ServiceTypeLaunchd:
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, "", err
+		}
+		f := launchdServiceFile(home, bin, configPath, interval)
+		return []serviceFile{f}, "launchctl load -w " + f.Path, nil
+	default:
+		return nil, "", fmt.Errorf("-serviceType must be '%s' or '%s'", ServiceTypeSystemd, ServiceTypeLaunchd)
+	}
+}
+
+// systemdQuote quotes a systemd command line argument, if necessary.
+func systemdQuote(s string) string {
+	if !strings.ContainsAny(s, " \t\"'\\") {
+		return s
+	}
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+// systemdServiceFiles returns a oneshot service running the program, and a timer that
+// starts it every interval.
+func systemdServiceFiles(bin, configPath string, interval time.Duration) []serviceFile {
+	secs := int(interval.Seconds())
+	// nb. a run that hangs is stopped before the next one is due
+	service := fmt.Sprintf(`[Unit]
+Description=Log weather and pollution to InfluxDB from OpenWeatherMap
+Wants=network-online.target
+After=network-online.target
+
+[Service]
+Type=oneshot
+ExecStart=%s -config %s
+TimeoutStartSec=%ds
+`, systemdQuote(bin), systemdQuote(configPath), secs)
+	timer := fmt.Sprintf(`[Unit]
+Description=Run %s every %s
+
+[Timer]
+OnBootSec=1min
+OnUnitActiveSec=%ds
+AccuracySec=1s
+
+[Install]
+WantedBy=timers.target
+`, serviceName, serviceIntervalString(interval), secs)
+	return []serviceFile{
+		{Path: filepath.Join(systemdUnitDir, serviceName+".service"), Content: service},
+		{Path: filepath.Join(systemdUnitDir, serviceName+".timer"), Content: timer},
+	}
+}
+
+// serviceIntervalString formats an interval without zero trailing units, e.g. "10m"
+// rather than "10m0s".
+func serviceIntervalString(d time.Duration) string {
+	s := d.String()
+	if strings.HasSuffix(s, "m0s") {
+		s = strings.TrimSuffix(s, "0s")
+	}
+	if strings.HasSuffix(s, "h0m") {
+		s = strings.TrimSuffix(s, "0m")
+	}
+	return s
+}
+
+func xmlEscape(s string) string {
+	var b bytes.Buffer
+	_ = xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
+
+// launchdServiceFile returns a per-user launch agent running the program every interval.
+func launchdServiceFile(home, bin, configPath string, interval time.Duration) serviceFile {
+	logPath := filepath.Join(home, "Library", "Logs", serviceName+".log")
+	plist := fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+	<key>Label</key>
+	<string>%s</string>
+	<key>ProgramArguments</key>
+	<array>
+		<string>%s</string>
+		<string>-config</string>
+		<string>%s</string>
+	</array>
+	<key>StartInterval</key>
+	<integer>%d</integer>
+	<key>RunAtLoad</key>
+	<true/>
+	<key>StandardOutPath</key>
+	<string>%s</string>
+	<key>StandardErrorPath</key>
+	<string>%s</string>
+</dict>
+</plist>
+`, launchdLabel, xmlEscape(bin), xmlEscape(configPath), int(interval.Seconds()), xmlEscape(logPath), xmlEscape(logPath))
+	return serviceFile{
+		Path:    filepath.Join(home, "Library", "LaunchAgents", launchdLabel+".plist"),
+		Content: plist,
+	}
+}
+
+// runService prints the service files for the given service manager, or, if install is
+// true, writes them to their standard locations, refusing to overwrite existing files.
+func runService(install bool, serviceType, configPath, interval string) error {
+	d, err := time.ParseDuration(interval)
+	if err != nil {
+		return fmt.Errorf("invalid -serviceInterval '%s': %w", interval, err)
+	}
+	if _, err := os.Stat(configPath); err != nil {
+		return fmt.Errorf("config file '%s' must exist: %w", configPath, err)
+	}
+	files, next, err := serviceFiles(serviceType, configPath, d)
+	if err != nil {
+		return err
+	}
+
+	if !install {
+		for _, f := range files {
+			fmt.Printf("# %s\n%s\n", f.Path, f.Content)
+		}
+		fmt.Printf("# then run: %s\n", next)
+		return nil
+	}
+	for _, f := range files {
+		if _, err := os.Stat(f.Path); err == nil {
+			return fmt.Errorf("'%s' already exists", f.Path)
+		} else if !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	for _, f := range files {
+		if err := os.MkdirAll(filepath.Dir(f.Path), 0o755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(f.Path, []byte(f.Content), 0o644); err != nil {
+			return err
+		}
+		fmt.Printf("Wrote %s.\n", f.Path)
+	}
+	fmt.Printf("To start the service, run: %s\n", next)
+	return nil
+}
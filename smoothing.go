@@ -0,0 +1,67 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	// rawFieldSuffix is appended to a smoothed field's name to preserve its unsmoothed value.
+	rawFieldSuffix = "_raw"
+	// smoothingMaxGap is the longest gap between readings over which smoothing continues;
+	// after a longer gap, smoothing restarts from the current reading.
+	smoothingMaxGap = 3 * time.Hour
+)
+
+// SmoothingConfig maps field names to the alpha (0 < alpha <= 1) used to exponentially
+// smooth them across runs. Smaller alphas smooth more heavily.
+type SmoothingConfig map[string]float64
+
+// Validate checks that each smoothed field is a known float field and its alpha is in range.
+func (c SmoothingConfig) Validate() error {
+	for field, alpha := range c {
+		if alpha <= 0 || alpha > 1 {
+			return fmt.Errorf("alpha for '%s' must be greater than 0 and at most 1", field)
+		}
+		if outputSchemas[schemaWeather][field] != FieldTypeFloat && outputSchemas[schemaPollution][field] != FieldTypeFloat {
+			return fmt.Errorf("'%s' is not a float weather or pollution field", field)
+		}
+	}
+	return nil
+}
+
+// SmoothedReading is the exponentially smoothed value of a field as of Time.
+type SmoothedReading struct {
+	Time  time.Time `json:"time"`
+	Value float64   `json:"value"`
+}
+
+// Apply replaces each configured field in fields with its exponentially smoothed value,
+// preserving the unsmoothed value under the field's name plus "_raw". Smoothed values
+// are persisted in the state, keyed by measurement and field.
+func (c SmoothingConfig) Apply(s *State, measurement string, fields map[string]interface{}, t time.Time) {
+	for field, alpha := range c {
+		v, ok := fields[field].(float64)
+		if !ok {
+			continue
+		}
+		key := measurement + "." + field
+		prev, hasPrev := s.Smoothed[key]
+		smoothed := v
+		switch {
+		case hasPrev && !t.After(prev.Time):
+			// nb. this observation was already smoothed by a previous run
+			smoothed = prev.Value
+		case hasPrev && t.Sub(prev.Time) <= smoothingMaxGap:
+			smoothed = alpha*v + (1-alpha)*prev.Value
+		}
+		if !hasPrev || t.After(prev.Time) {
+			if s.Smoothed == nil {
+				s.Smoothed = make(map[string]SmoothedReading)
+			}
+			s.Smoothed[key] = SmoothedReading{Time: t, Value: smoothed}
+		}
+		fields[field+rawFieldSuffix] = v
+		fields[field] = smoothed
+	}
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestSmoothingApply(t *testing.T) {
+	start := time.Date(2024, 7, 4, 12, 0, 0, 0, time.UTC)
+	c := SmoothingConfig{"temp_f": 0.5}
+	steps := []struct {
+		name    string
+		t       time.Time
+		temp    float64
+		want    float64
+		wantRaw float64
+	}{
+		{"first reading", start, 70, 70, 70},
+		{"smoothed", start.Add(10 * time.Minute), 80, 75, 80},
+		{"smoothed again", start.Add(20 * time.Minute), 75, 75, 75},
+		{"same observation again", start.Add(20 * time.Minute), 90, 75, 90},
+		{"older observation", start.Add(15 * time.Minute), 60, 75, 60},
+		{"up to the max gap", start.Add(20*time.Minute + smoothingMaxGap), 85, 80, 85},
+		{"after the max gap", start.Add(20*time.Minute + 2*smoothingMaxGap + time.Minute), 50, 50, 50},
+	}
+	s := &State{}
+	for _, step := range steps {
+		fields := map[string]interface{}{"temp_f": step.temp, "rel_humidity": 55}
+		c.Apply(s, "weather", fields, step.t)
+		if got := fields["temp_f"].(float64); math.Abs(got-step.want) > 1e-9 {
+			t.Errorf("%s: temp_f = %v; want %v", step.name, got, step.want)
+		}
+		if got := fields["temp_f"+rawFieldSuffix]; got != step.wantRaw {
+			t.Errorf("%s: temp_f_raw = %v; want %v", step.name, got, step.wantRaw)
+		}
+		if _, ok := fields["rel_humidity"+rawFieldSuffix]; ok {
+			t.Errorf("%s: unsmoothed field rel_humidity has a raw value", step.name)
+		}
+	}
+}
+
+func TestSmoothingApplyMissingField(t *testing.T) {
+	s := &State{}
+	fields := map[string]interface{}{"rel_humidity": 55}
+	SmoothingConfig{"temp_f": 0.5}.Apply(s, "weather", fields, time.Now())
+	if len(fields) != 1 || len(s.Smoothed) != 0 {
+		t.Errorf("Apply() with the smoothed field missing changed fields to %v and state to %v", fields, s.Smoothed)
+	}
+}
+
+func TestSmoothingConfigValidate(t *testing.T) {
+	tests := []struct {
+		c       SmoothingConfig
+		wantErr bool
+	}{
+		{SmoothingConfig{"temp_f": 0.3, "aqi_us": 1}, false},
+		{SmoothingConfig{"temp_f": 0}, true},
+		{SmoothingConfig{"temp_f": 1.5}, true},
+		{SmoothingConfig{"rel_humidity": 0.5}, true},
+		{SmoothingConfig{"nope": 0.5}, true},
+	}
+	for _, tt := range tests {
+		if err := tt.c.Validate(); (err != nil) != tt.wantErr {
+			t.Errorf("%v.Validate() = %v; want error: %t", tt.c, err, tt.wantErr)
+		}
+	}
+}
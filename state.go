@@ -0,0 +1,188 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/cdzombak/openweather-influxdb-connector/pkg/owmconnector"
+)
+
+const stateFileName = "state.json"
+
+// State is persisted between runs in the configured state directory.
+type State struct {
+	PressureHistory   []PressureReading               `json:"pressure_history,omitempty"`
+	DailyTemps        *DailyTempSummary               `json:"daily_temps,omitempty"`
+	GrowingSeason     *GrowingSeasonTotal             `json:"growing_season,omitempty"`
+	PMHistory         []owmconnector.PMReading        `json:"pm_history,omitempty"`
+	FreezeAlertSince  *time.Time                      `json:"freeze_alert_since,omitempty"`
+	FieldTypes        map[string]map[string]FieldType `json:"field_types,omitempty"`
+	NotificationsSent map[string]time.Time            `json:"notifications_sent,omitempty"`
+	Smoothed          map[string]SmoothedReading      `json:"smoothed,omitempty"`
+	LastObservations  map[string]time.Time            `json:"last_observations,omitempty"`
+}
+
+// PressureReading is a single historical sea-level pressure observation.
+type PressureReading struct {
+	Time       time.Time `json:"time"`
+	PressureMb float64   `json:"pressure_mb"`
+}
+
+// LoadState reads the state file from the given directory.
+// A missing state file results in an empty State.
+func LoadState(dir string) (*State, error) {
+	s := &State{}
+	b, err := os.ReadFile(filepath.Join(dir, stateFileName))
+	if errors.Is(err, fs.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, s); err != nil {
+		return nil, fmt.Errorf("failed to parse state file: %w", err)
+	}
+	return s, nil
+}
+
+// Save atomically writes the state file to the given directory, creating the directory if necessary.
+func (s *State) Save(dir string) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	b, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(dir, stateFileName+".*.tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(b); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), filepath.Join(dir, stateFileName))
+}
+
+const (
+	pressureTrendWindow    = 3 * time.Hour
+	pressureTrendTolerance = 30 * time.Minute
+	pressureTrendSteadyMb  = 1.0
+)
+
+// RecordPressure adds a pressure reading to the history and prunes readings too old
+// to be useful for calculating the 3-hour trend.
+func (s *State) RecordPressure(t time.Time, p float64) {
+	if n := len(s.PressureHistory); n == 0 || t.After(s.PressureHistory[n-1].Time) {
+		s.PressureHistory = append(s.PressureHistory, PressureReading{Time: t, PressureMb: p})
+	}
+	cutoff := t.Add(-(pressureTrendWindow + pressureTrendTolerance))
+	kept := s.PressureHistory[:0]
+	for _, r := range s.PressureHistory {
+		if !r.Time.Before(cutoff) {
+			kept = append(kept, r)
+		}
+	}
+	s.PressureHistory = kept
+}
+
+// PressureTrend3h returns the change in pressure (mb) over the 3 hours preceding t,
+// using the historical reading closest to 3 hours before t. The boolean return value
+// is false if no reading is available close enough to 3 hours before t.
+func (s *State) PressureTrend3h(t time.Time, p float64) (float64, bool) {
+	target := t.Add(-pressureTrendWindow)
+	var best *PressureReading
+	var bestDiff time.Duration
+	for i := range s.PressureHistory {
+		r := &s.PressureHistory[i]
+		diff := r.Time.Sub(target)
+		if diff < 0 {
+			diff = -diff
+		}
+		if diff <= pressureTrendTolerance && (best == nil || diff < bestDiff) {
+			best = r
+			bestDiff = diff
+		}
+	}
+	if best == nil {
+		return 0, false
+	}
+	return p - best.PressureMb, true
+}
+
+// PressureTrendCategory describes a 3-hour pressure change as "rising", "falling", or "steady".
+func PressureTrendCategory(trendMb float64) string {
+	switch {
+	case trendMb >= pressureTrendSteadyMb:
+		return "rising"
+	case trendMb <= -pressureTrendSteadyMb:
+		return "falling"
+	default:
+		return "steady"
+	}
+}
+
+// CheckFieldTypes returns an error describing any fields whose type differs from the type
+// previously recorded for the same field in the same measurement.
+func (s *State) CheckFieldTypes(measurement string, fields map[string]interface{}) error {
+	recorded := s.FieldTypes[measurement]
+	var problems []string
+	for k, v := range fields {
+		actual, ok := fieldTypeOf(v)
+		if !ok {
+			problems = append(problems, fmt.Sprintf("field '%s' has unsupported type %T", k, v))
+			continue
+		}
+		if expected, ok := recorded[k]; ok && expected != actual {
+			problems = append(problems, fmt.Sprintf("field '%s' is %s but was previously written as %s", k, actual, expected))
+		}
+	}
+	if len(problems) > 0 {
+		sort.Strings(problems)
+		return fmt.Errorf("refusing to write %s: %s", measurement, strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// RecordFieldTypes records the types of the given fields as written to the given measurement.
+func (s *State) RecordFieldTypes(measurement string, fields map[string]interface{}) {
+	if s.FieldTypes == nil {
+		s.FieldTypes = make(map[string]map[string]FieldType)
+	}
+	if s.FieldTypes[measurement] == nil {
+		s.FieldTypes[measurement] = make(map[string]FieldType)
+	}
+	for k, v := range fields {
+		if t, ok := fieldTypeOf(v); ok {
+			s.FieldTypes[measurement][k] = t
+		}
+	}
+}
+
+// ObservationUnchanged reports whether an observation with timestamp t was already
+// written to the given measurement by a previous run.
+func (s *State) ObservationUnchanged(measurement string, t time.Time) bool {
+	last, ok := s.LastObservations[measurement]
+	return ok && last.Equal(t)
+}
+
+// RecordObservation records that an observation with timestamp t was written to the given measurement.
+func (s *State) RecordObservation(measurement string, t time.Time) {
+	if s.LastObservations == nil {
+		s.LastObservations = make(map[string]time.Time)
+	}
+	s.LastObservations[measurement] = t
+}
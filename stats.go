@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	statsPeriodMonth = "month"
+	statsPeriodYear  = "year"
+
+	statsPeriodTag = "period"
+
+	// statsQueryTimeout is longer than influxTimeout, since a stats query may aggregate a
+	// year of data.
+	statsQueryTimeout = 2 * time.Minute
+)
+
+// ParseStatsPeriod parses a stats period specification: "YYYY-MM" for a month, "YYYY" for
+// a year, or "month"/"year" for the most recently completed month or year. It returns the
+// kind of period and its bounds, in now's location.
+func ParseStatsPeriod(spec string, now time.Time) (kind string, start, end time.Time, err error) {
+	loc := now.Location()
+	switch spec {
+	case statsPeriodMonth:
+		end = time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, loc)
+		return statsPeriodMonth, end.AddDate(0, -1, 0), end, nil
+	case statsPeriodYear:
+		end = time.Date(now.Year(), time.January, 1, 0, 0, 0, 0, loc)
+		return statsPeriodYear, end.AddDate(-1, 0, 0), end, nil
+	}
+	if t, err := time.ParseInLocation("2006-01", spec, loc); err == nil {
+		return statsPeriodMonth, t, t.AddDate(0, 1, 0), nil
+	}
+	if t, err := time.ParseInLocation("2006", spec, loc); err == nil {
+		return statsPeriodYear, t, t.AddDate(1, 0, 0), nil
+	}
+	return "", time.Time{}, time.Time{}, fmt.Errorf("invalid stats period '%s' (expected YYYY-MM, YYYY, 'month', or 'year')", spec)
+}
+
+// PeriodStats computes summary statistics for the given period from the data stored in
+// Influx. Only statistics for which data exists are included in the returned fields.
+func PeriodStats(config Config, writer *influxWriter, start, end time.Time) (map[string]interface{}, error) {
+	type stat struct {
+		key         string
+		measurement string
+		field       string
+		fn          string
+	}
+	stats := []stat{
+		{"temp_max_f", config.WeatherMeasurementName, "temp_f", "max"},
+		{"temp_min_f", config.WeatherMeasurementName, "temp_f", "min"},
+		{"temp_mean_f", config.WeatherMeasurementName, "temp_f", "mean"},
+		{"wind_speed_max_mph", config.WeatherMeasurementName, "wind_speed_mph", "max"},
+		{"precip_total_mm", config.WeatherMeasurementName, "precip_1h_mm", aggregateHourlyTotal},
+		{"aqi_us_max", config.PollutionMeasurementName, "aqi_us", "max"},
+	}
+	if config.DegreeDays != nil {
+		stats = append(stats,
+			stat{"hdd", config.DegreeDays.Measurement(), "hdd", "sum"},
+			stat{"cdd", config.DegreeDays.Measurement(), "cdd", "sum"},
+		)
+	}
+	if config.GrowingDegreeDays != nil {
+		stats = append(stats, stat{"gdd", config.GrowingDegreeDays.Measurement(), "gdd", "sum"})
+	}
+
+	fields := make(map[string]interface{})
+	for _, s := range stats {
+		ctx, cancel := context.WithTimeout(context.Background(), statsQueryTimeout)
+		v, ok, err := writer.QueryAggregate(ctx, s.measurement, s.field, s.fn, config.primarySources(), config.coordTags(), start, end)
+		cancel()
+		if err != nil {
+			return nil, fmt.Errorf("failed to query %s %s: %w", s.measurement, s.field, err)
+		}
+		if ok {
+			fields[s.key] = v
+		}
+	}
+	return fields, nil
+}
+
+// runStats computes statistics for the given period, prints a report, and writes them to
+// the configured stats measurement (if any).
+func runStats(config Config, writer *influxWriter, spec string) error {
+	kind, start, end, err := ParseStatsPeriod(spec, now())
+	if err != nil {
+		return err
+	}
+	fields, err := PeriodStats(config, writer, start, end)
+	if err != nil {
+		return err
+	}
+
+	label := start.Format("January 2006")
+	if kind == statsPeriodYear {
+		label = start.Format("2006")
+	}
+	var report strings.Builder
+	fmt.Fprintf(&report, "Stats for %s:\n", label)
+	lines := []struct {
+		label  string
+		key    string
+		format string
+	}{
+		{"high", "temp_max_f", "%.1f degF"},
+		{"low", "temp_min_f", "%.1f degF"},
+		{"average", "temp_mean_f", "%.1f degF"},
+		{"peak wind", "wind_speed_max_mph", "%.1f mph"},
+		{"precipitation", "precip_total_mm", "%.1f mm"},
+		{"worst AQI (US EPA)", "aqi_us_max", "%.0f"},
+		{"heating degree days", "hdd", "%.1f"},
+		{"cooling degree days", "cdd", "%.1f"},
+		{"growing degree days", "gdd", "%.1f"},
+	}
+	for _, l := range lines {
+		if v, ok := fields[l.key]; ok {
+			fmt.Fprintf(&report, "\t%s: "+l.format+"\n", l.label, v)
+		}
+	}
+	if len(fields) == 0 {
+		report.WriteString("\tno data\n")
+	}
+	fmt.Print(report.String())
+
+	if config.StatsMeasurementName == "" || len(fields) == 0 {
+		return nil
+	}
+	if config.ValidateOutput {
+		if err := ValidateFields(schemaStats, fields); err != nil {
+			return err
+		}
+	}
+	tags := config.locationTags(config.providerName())
+	tags[statsPeriodTag] = kind
+	return writer.WritePoint(config.StatsMeasurementName, tags, fields, start)
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseStatsPeriod(t *testing.T) {
+	now := time.Date(2024, 3, 15, 10, 0, 0, 0, time.UTC)
+	tests := []struct {
+		spec      string
+		wantKind  string
+		wantStart time.Time
+		wantEnd   time.Time
+		wantErr   bool
+	}{
+		{spec: "month", wantKind: statsPeriodMonth, wantStart: time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC), wantEnd: time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)},
+		{spec: "year", wantKind: statsPeriodYear, wantStart: time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC), wantEnd: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{spec: "2023-12", wantKind: statsPeriodMonth, wantStart: time.Date(2023, 12, 1, 0, 0, 0, 0, time.UTC), wantEnd: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{spec: "2022", wantKind: statsPeriodYear, wantStart: time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC), wantEnd: time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{spec: "2023-13", wantErr: true},
+		{spec: "last month", wantErr: true},
+	}
+	for _, tt := range tests {
+		kind, start, end, err := ParseStatsPeriod(tt.spec, now)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("ParseStatsPeriod(%q) succeeded; want an error", tt.spec)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseStatsPeriod(%q) = %v", tt.spec, err)
+			continue
+		}
+		if kind != tt.wantKind || !start.Equal(tt.wantStart) || !end.Equal(tt.wantEnd) {
+			t.Errorf("ParseStatsPeriod(%q) = %s, %s, %s; want %s, %s, %s", tt.spec, kind, start, end, tt.wantKind, tt.wantStart, tt.wantEnd)
+		}
+	}
+}
+
+func TestAggregateQuery(t *testing.T) {
+	start := time.Date(2024, 2, 1, 5, 0, 0, 0, time.UTC)
+	stop := time.Date(2024, 3, 1, 5, 0, 0, 0, time.UTC)
+	config := Config{Latitude: 42.2808, Longitude: -83.743}
+	tests := []struct {
+		name string
+		fn   string
+		want string
+	}{
+		{
+			name: "max",
+			fn:   "max",
+			want: `from(bucket: "weather")
+  |> range(start: 2024-02-01T05:00:00Z, stop: 2024-03-01T05:00:00Z)
+  |> filter(fn: (r) => r._measurement == "weather" and r._field == "temp_f")
+  |> filter(fn: (r) => contains(value: r["data_source"], set: ["nws", "openweathermap"]))
+  |> filter(fn: (r) => r["latitude"] == "42.281")
+  |> filter(fn: (r) => r["longitude"] == "-83.743")
+  |> group()
+  |> max()
+`,
+		},
+		{
+			name: "hourly total",
+			fn:   aggregateHourlyTotal,
+			want: `from(bucket: "weather")
+  |> range(start: 2024-02-01T05:00:00Z, stop: 2024-03-01T05:00:00Z)
+  |> filter(fn: (r) => r._measurement == "weather" and r._field == "temp_f")
+  |> filter(fn: (r) => contains(value: r["data_source"], set: ["nws", "openweathermap"]))
+  |> filter(fn: (r) => r["latitude"] == "42.281")
+  |> filter(fn: (r) => r["longitude"] == "-83.743")
+  |> group()
+  |> aggregateWindow(every: 1h, fn: mean, createEmpty: false)
+  |> sum()
+`,
+		},
+	}
+	for _, tt := range tests {
+		got := aggregateQuery("weather", "weather", "temp_f", tt.fn, []string{"nws", "openweathermap"}, config.coordTags(), start, stop)
+		if got != tt.want {
+			t.Errorf("%s: aggregateQuery() = %s; want %s", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultStatsdName   = "statsd"
+	defaultStatsdPrefix = "owm"
+	statsdTimeout       = 5 * time.Second
+	// statsdMaxPacketSize keeps each UDP packet under a typical 1500-byte MTU.
+	statsdMaxPacketSize = 1432
+)
+
+// StatsdConfig describes the configuration for the statsd output.
+type StatsdConfig struct {
+	Name      string `json:"name,omitempty"`
+	Address   string `json:"address"`
+	Prefix    string `json:"prefix,omitempty"`
+	DogStatsD bool   `json:"dogstatsd,omitempty"`
+}
+
+// Validate checks the statsd configuration.
+func (c StatsdConfig) Validate() error {
+	if c.Address == "" {
+		return errors.New("address must be set")
+	}
+	if _, _, err := net.SplitHostPort(c.Address); err != nil {
+		return fmt.Errorf("address must be in host:port form: %w", err)
+	}
+	return nil
+}
+
+// OutputName returns the configured output name, or the default "statsd".
+func (c StatsdConfig) OutputName() string {
+	if c.Name == "" {
+		return defaultStatsdName
+	}
+	return c.Name
+}
+
+// statsdOutput sends each numeric field of a point as a statsd gauge named
+// <prefix>.<measurement>.<field>. With DogStatsD enabled, the point's tags are attached
+// to each gauge.
+type statsdOutput struct {
+	config StatsdConfig
+	conn   net.Conn
+}
+
+// newStatsdOutput returns a statsd output sending to the configured address over UDP.
+func newStatsdOutput(c StatsdConfig) (*statsdOutput, error) {
+	conn, err := net.DialTimeout("udp", c.Address, statsdTimeout)
+	if err != nil {
+		return nil, err
+	}
+	return &statsdOutput{config: c, conn: conn}, nil
+}
+
+// Name returns the output's name, for output routing.
+func (o *statsdOutput) Name() string {
+	return o.config.OutputName()
+}
+
+// WritePoint sends the point's numeric fields as gauges. Non-numeric fields are ignored,
+// and statsd has no notion of timestamps, so the gauges are reported as of receipt.
+func (o *statsdOutput) WritePoint(_ context.Context, measurement string, tags map[string]string, fields map[string]interface{}, _ time.Time) error {
+	prefix := o.config.Prefix
+	if prefix == "" {
+		prefix = defaultStatsdPrefix
+	}
+	tagSuffix := ""
+	if o.config.DogStatsD && len(tags) > 0 {
+		tagSuffix = "|#" + dogStatsDTags(tags)
+	}
+
+	names := sortedKeys(fields)
+
+	var packet strings.Builder
+	for _, k := range names {
+		v, ok := statsdValue(fields[k])
+		if !ok {
+			continue
+		}
+		name := fmt.Sprintf("%s.%s.%s", prefix, statsdSanitize(measurement), statsdSanitize(k))
+		line := fmt.Sprintf("%s:%s|g%s", name, v, tagSuffix)
+		if !o.config.DogStatsD && strings.HasPrefix(v, "-") {
+			// nb. plain statsd reads a signed gauge value as a delta, so reset the gauge to 0
+			// first; both lines go in the same packet so they can't be reordered
+			line = fmt.Sprintf("%s:0|g\n%s", name, line)
+		}
+		if packet.Len() > 0 && packet.Len()+1+len(line) > statsdMaxPacketSize {
+			if err := o.send(packet.String()); err != nil {
+				return err
+			}
+			packet.Reset()
+		}
+		if packet.Len() > 0 {
+			packet.WriteByte('\n')
+		}
+		packet.WriteString(line)
+	}
+	if packet.Len() == 0 {
+		return nil
+	}
+	return o.send(packet.String())
+}
+
+func (o *statsdOutput) send(packet string) error {
+	if err := o.conn.SetWriteDeadline(time.Now().Add(statsdTimeout)); err != nil {
+		return err
+	}
+	_, err := o.conn.Write([]byte(packet))
+	return err
+}
+
+// Close closes the UDP socket.
+func (o *statsdOutput) Close() {
+	o.conn.Close()
+}
+
+// statsdValue formats a numeric field value for a gauge. The boolean return value is
+// false if the value isn't numeric.
+func statsdValue(v interface{}) (string, bool) {
+	switch n := v.(type) {
+	case float64:
+		return strconv.FormatFloat(n, 'f', -1, 64), true
+	case float32:
+		return strconv.FormatFloat(float64(n), 'f', -1, 32), true
+	case int:
+		return strconv.Itoa(n), true
+	case int64:
+		return strconv.FormatInt(n, 10), true
+	case int32:
+		return strconv.FormatInt(int64(n), 10), true
+	}
+	return "", false
+}
+
+// dogStatsDTags formats tags in DogStatsD's key:value,key:value form, sorted by key.
+func dogStatsDTags(tags map[string]string) string {
+	keys := sortedKeys(tags)
+	parts := make([]string, 0, len(keys))
+	for _, k := range keys {
+		parts = append(parts, statsdSanitize(k)+":"+statsdSanitize(tags[k]))
+	}
+	return strings.Join(parts, ",")
+}
+
+// statsdSanitize replaces characters that are reserved in the statsd protocol.
+func statsdSanitize(s string) string {
+	return strings.NewReplacer(":", "_", "|", "_", "@", "_", "#", "_", ",", "_", "\n", "_", " ", "_").Replace(s)
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestStatsdWritePoint(t *testing.T) {
+	tests := []struct {
+		name      string
+		dogStatsD bool
+		tags      map[string]string
+		fields    map[string]interface{}
+		want      string
+	}{
+		{
+			name:   "gauges sorted by field",
+			tags:   map[string]string{"location": "home"},
+			fields: map[string]interface{}{"temp_f": 72.5, "rel_humidity": 55, "condition": "Clear"},
+			want:   "owm.weather.rel_humidity:55|g\nowm.weather.temp_f:72.5|g",
+		},
+		{
+			name:   "negative gauge is reset to 0 first",
+			fields: map[string]interface{}{"temp_c": -5.25, "wind_speed_mph": 3.0},
+			want:   "owm.weather.temp_c:0|g\nowm.weather.temp_c:-5.25|g\nowm.weather.wind_speed_mph:3|g",
+		},
+		{
+			name:      "dogstatsd tags and negative gauge",
+			dogStatsD: true,
+			tags:      map[string]string{"location": "home", "data_source": "owm"},
+			fields:    map[string]interface{}{"temp_c": -5.25},
+			want:      "owm.weather.temp_c:-5.25|g|#data_source:owm,location:home",
+		},
+		{
+			name:   "reserved characters are replaced",
+			fields: map[string]interface{}{"a:b|c": 1},
+			want:   "owm.weather.a_b_c:1|g",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+			if err != nil {
+				t.Skipf("can't listen on UDP: %s", err)
+			}
+			defer pc.Close()
+			o, err := newStatsdOutput(StatsdConfig{Address: pc.LocalAddr().String(), DogStatsD: tt.dogStatsD})
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer o.Close()
+
+			if err := o.WritePoint(context.Background(), "weather", tt.tags, tt.fields, time.Time{}); err != nil {
+				t.Fatalf("WritePoint() = %v", err)
+			}
+			if err := pc.SetReadDeadline(time.Now().Add(time.Second)); err != nil {
+				t.Fatal(err)
+			}
+			buf := make([]byte, statsdMaxPacketSize)
+			n, _, err := pc.ReadFrom(buf)
+			if err != nil {
+				t.Fatalf("no packet received: %s", err)
+			}
+			if got := string(buf[:n]); got != tt.want {
+				t.Errorf("packet = %q; want %q", got, tt.want)
+			}
+		})
+	}
+}
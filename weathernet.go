@@ -0,0 +1,65 @@
+package main
+
+import (
+	"errors"
+
+	"github.com/cdzombak/openweather-influxdb-connector/pkg/owmconnector"
+)
+
+// validateWeatherNetworkUpload checks that the config may upload observations to a weather
+// network like CWOP or Windy. These networks are for data measured by a personal weather
+// station's own sensors, not model or interpolated data, so the user must opt in with
+// local_sensor_data, calibration must correct the uploaded values against the station's
+// sensors, and OpenWeatherMap data (whose terms restrict redistribution) is never uploaded.
+func (c Config) validateWeatherNetworkUpload(localSensorData bool) error {
+	if !localSensorData {
+		return errors.New("local_sensor_data must be set to true to confirm the uploaded observations reflect your own station's sensors; weather networks don't accept model or interpolated data")
+	}
+	if len(c.Calibration) == 0 {
+		return errors.New("calibration must be set, to correct the uploaded observations against your station's sensors")
+	}
+	for _, name := range c.primarySources() {
+		if name == owmconnector.ProviderOpenWeatherMap {
+			return errors.New("OpenWeatherMap data may not be uploaded to weather networks; use a different provider (and fallback_providers)")
+		}
+	}
+	return nil
+}
+
+// weatherNetworkObservation is the subset of a weather observation uploaded to weather
+// networks like CWOP and Windy. Values the observation didn't include are nil.
+type weatherNetworkObservation struct {
+	TempF        *float64
+	DewPointF    *float64
+	Humidity     *float64
+	PressureMb   *float64
+	WindSpeedMph *float64
+	WindBearing  *float64
+}
+
+// weatherNetworkObservationFromFields maps the weather measurement's fields to the values
+// uploaded to weather networks.
+func weatherNetworkObservationFromFields(fields map[string]interface{}) weatherNetworkObservation {
+	return weatherNetworkObservation{
+		TempF:        numericField(fields, "temp_f"),
+		DewPointF:    numericField(fields, "dew_point_f"),
+		Humidity:     numericField(fields, "rel_humidity"),
+		PressureMb:   numericField(fields, "barometric_pressure_mb"),
+		WindSpeedMph: numericField(fields, "wind_speed_mph"),
+		WindBearing:  numericField(fields, "wind_bearing"),
+	}
+}
+
+// numericField returns the named field as a float64, or nil if it's missing or not numeric.
+func numericField(fields map[string]interface{}, name string) *float64 {
+	var v float64
+	switch n := fields[name].(type) {
+	case float64:
+		v = n
+	case int:
+		v = float64(n)
+	default:
+		return nil
+	}
+	return &v
+}
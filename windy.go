@@ -0,0 +1,74 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/cdzombak/libwx"
+)
+
+// windyUpdatePath is the path of Windy's station update endpoint; the API key follows it.
+const windyUpdatePath = "/pws/update/"
+
+// WindyConfig describes the configuration for uploading observations to Windy's Stations API.
+type WindyConfig struct {
+	APIKey string `json:"api_key"`
+	// Station is the station's index among those registered with the API key.
+	Station int `json:"station,omitempty"`
+	// LocalSensorData confirms the uploaded observations reflect the station's own sensors.
+	LocalSensorData bool `json:"local_sensor_data,omitempty"`
+}
+
+// Validate checks the Windy configuration.
+func (c WindyConfig) Validate() error {
+	if c.APIKey == "" {
+		return errors.New("api_key must be set")
+	}
+	if c.Station < 0 {
+		return errors.New("station may not be negative")
+	}
+	return nil
+}
+
+// windyQuery returns the update parameters for the given observation, in Windy's units.
+func windyQuery(station int, o weatherNetworkObservation, ts time.Time) url.Values {
+	q := url.Values{}
+	q.Set("station", strconv.Itoa(station))
+	q.Set("ts", strconv.FormatInt(ts.Unix(), 10))
+	set := func(k string, v *float64, conv func(float64) float64) {
+		if v != nil {
+			q.Set(k, strconv.FormatFloat(conv(*v), 'f', 1, 64))
+		}
+	}
+	identity := func(v float64) float64 { return v }
+	set("temp", o.TempF, func(v float64) float64 { return libwx.TempF(v).C().Unwrap() })
+	set("dewpoint", o.DewPointF, func(v float64) float64 { return libwx.TempF(v).C().Unwrap() })
+	set("humidity", o.Humidity, identity)
+	set("mbar", o.PressureMb, identity)
+	set("wind", o.WindSpeedMph, func(v float64) float64 { return libwx.SpeedMph(v).KmH().Unwrap() / 3.6 })
+	set("winddir", o.WindBearing, identity)
+	return q
+}
+
+// SubmitWindy sends the given weather fields to Windy's Stations API.
+// See https://community.windy.com/topic/8168/report-your-weather-station-data-to-windy
+func SubmitWindy(c WindyConfig, fields map[string]interface{}, ts time.Time) error {
+	q := windyQuery(c.Station, weatherNetworkObservationFromFields(fields), ts)
+	req, err := http.NewRequest(http.MethodGet, "https://stations.windy.com"+windyUpdatePath+url.PathEscape(c.APIKey)+"?"+q.Encode(), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := owmHTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("Windy Stations API returned %s", resp.Status)
+	}
+	return nil
+}